@@ -2,12 +2,44 @@ package main
 
 import (
 	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	ksh "aigents-base/internal/auth-land/auth-signature/handlers"
+	"aigents-base/internal/auth-land/auth-signature/keyset"
+	c_at "aigents-base/internal/common/atoms"
 	db "aigents-base/internal/common/db"
+	dbh "aigents-base/internal/common/db/handlers"
+	"aigents-base/internal/common/health"
+	hh "aigents-base/internal/common/health/handlers"
+	"aigents-base/internal/common/logger"
+	mailer "aigents-base/internal/common/mailer"
+	"aigents-base/internal/common/server"
+	"context"
+	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
+	ad "aigents-base/internal/auth-land/auth/domain"
 	ah "aigents-base/internal/auth-land/auth/handlers"
 	ar "aigents-base/internal/auth-land/auth/repositories"
 	as "aigents-base/internal/auth-land/auth/services"
+	sr "aigents-base/internal/auth-land/sessions/repositories"
+	ss "aigents-base/internal/auth-land/sessions/services"
+	sh "aigents-base/internal/auth-land/sessions/handlers"
+	akd "aigents-base/internal/auth-land/api-keys/domain"
+	akh "aigents-base/internal/auth-land/api-keys/handlers"
+	akr "aigents-base/internal/auth-land/api-keys/repositories"
+	aks "aigents-base/internal/auth-land/api-keys/services"
+	mfh "aigents-base/internal/auth-land/mfa/handlers"
+	mfr "aigents-base/internal/auth-land/mfa/repositories"
+	mfs "aigents-base/internal/auth-land/mfa/services"
+	oih "aigents-base/internal/auth-land/oidc/handlers"
+	oir "aigents-base/internal/auth-land/oidc/repositories"
+	ois "aigents-base/internal/auth-land/oidc/services"
+	rd "aigents-base/internal/auth-land/roles/domain"
+	rlh "aigents-base/internal/auth-land/roles/handlers"
+	rlr "aigents-base/internal/auth-land/roles/repositories"
+	rls "aigents-base/internal/auth-land/roles/services"
 
 	agh "aigents-base/internal/agents/handlers"
 	agr "aigents-base/internal/agents/repositories"
@@ -25,21 +57,115 @@ import (
 
 
 func main() {
-	db.Init()
+	logger.Init()
 
-	authRepo := ar.NewAuthRepository(db.DB)
-	authSv := as.NewAuthService(authRepo)
-	authHdlr := ah.NewAuthHandler(authSv)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	keys := keyset.FromEnv()
+	m.UseKeySet(keys)
+	jwksHdlr := ksh.NewJWKSHandler(keys)
+
+	dbConn, err := db.Open(context.Background(), db.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	dbStatsHdlr := dbh.NewDBStatsHandler(dbConn)
+	txManager := db.NewTxManager(dbConn)
+
+	if db.AutoMigrateFromEnv() {
+		migrator, err := db.NewMigrator(dbConn)
+		if err == nil {
+			_, err = migrator.Up(context.Background())
+		}
+		if err != nil {
+			log.Fatalf("Error migrating database: %v", err)
+		}
+	}
+
+	mailQueue := mailer.NewQueue(mailer.NewFromEnv(), 100)
+
+	authRepo := ar.NewAuthRepository(dbConn)
+	authSv := as.NewAuthService(
+		authRepo,
+		mailQueue,
+		os.Getenv("APP_URL"),
+		c_at.ParseEnvMinutesAtom("EMAIL_VERIFY_TTL", 1440),
+		c_at.ParseEnvMinutesAtom("PASSWORD_RESET_TTL", 30),
+		ad.LoginPolicy{
+			MaxEmailFailures: c_at.ParseEnvIntAtom("LOGIN_MAX_EMAIL_FAILURES", 5),
+			MaxIPFailures:    c_at.ParseEnvIntAtom("LOGIN_MAX_IP_FAILURES", 50),
+			BaseDelay:        time.Second,
+			MaxDelay:         30 * time.Second,
+			Lockout:          c_at.ParseEnvMinutesAtom("LOGIN_LOCKOUT", 15),
+		},
+		ad.MailPolicy{
+			MaxPerEmail: c_at.ParseEnvIntAtom("MAIL_MAX_PER_EMAIL", 3),
+			MaxPerIP:    c_at.ParseEnvIntAtom("MAIL_MAX_PER_IP", 20),
+			Window:      c_at.ParseEnvMinutesAtom("MAIL_THROTTLE_WINDOW", 60),
+		},
+	)
+	sessionRepo := sr.NewSessionRepository(dbConn)
+	sessionSv := ss.NewSessionService(sessionRepo, m.RefreshTokenTTL)
+	authHdlr := ah.NewAuthHandler(authSv, sessionSv)
+	sessionHdlr := sh.NewSessionHandler(sessionSv)
+
+	apiKeyRepo := akr.NewAPIKeyRepository(dbConn)
+	apiKeySv := aks.NewAPIKeyService(apiKeyRepo)
+	apiKeyHdlr := akh.NewAPIKeyHandler(apiKeySv)
+
+	mfaRepo := mfr.NewMFARepository(dbConn)
+	mfaSv := mfs.NewMFAService(
+		mfaRepo,
+		mfs.EncryptionKeyFromEnv(),
+		"Aigents",
+		c_at.ParseEnvIntAtom("MFA_MAX_FAILURES", 5),
+		c_at.ParseEnvMinutesAtom("LOGIN_LOCKOUT", 15),
+	)
+	mfaHdlr := mfh.NewMFAHandler(mfaSv, sessionSv)
+
+	oidcRepo := oir.NewOIDCRepository(dbConn)
+	oidcSv := ois.NewOIDCService(oidcRepo, ois.ProvidersFromEnv())
+	oidcHdlr := oih.NewOIDCHandler(oidcSv, sessionSv, os.Getenv("APP_URL"))
 
-	agentRepo := agr.NewAgentRepository(db.DB)
-	agentSv := ags.NewAgentService(agentRepo)
+	roleRepo := rlr.NewRoleRepository(dbConn)
+	roleSv := rls.NewRoleService(roleRepo)
+	roleHdlr := rlh.NewRoleHandler(roleSv)
+
+	agentRepo := agr.NewAgentRepository(dbConn)
+	agentSv := ags.NewAgentService(agentRepo, txManager)
 	agentHdlr := agh.NewAgentHandler(agentSv)
 
-	chatRepo := chr.NewChatRepository(db.DB)
-	chatSv := chs.NewChatService(chatRepo, agentRepo, os.Getenv("WS_AI_MS_URL"), 20, 20)
+	chatRepo := chr.NewChatRepository(dbConn)
+	chatSv := chs.NewChatService(
+		chatRepo,
+		agentRepo,
+		txManager,
+		os.Getenv("WS_AI_MS_URL"),
+		20,
+		20,
+		c_at.ParseEnvMinutesAtom("CHAT_RETENTION_TTL", 43200),
+		c_at.ParseEnvMinutesAtom("CHAT_PURGE_INTERVAL", 60),
+	)
 	chatHdlr := chh.NewChatHandler(chatSv)
 
-	r := gin.Default()
+	srv := server.New(server.ConfigFromEnv())
+
+	checker := health.NewChecker(c_at.ParseEnvSecondsAtom("HEALTH_CHECK_TIMEOUT", 3), time.Second)
+	checker.Add("postgres", dbConn.PingContext)
+	checker.Add("ai_service", chatSv.Ping)
+	healthHdlr := hh.NewHealthHandler(checker, srv.Draining)
+
+	r := gin.New()
+
+	// Probes are mounted ahead of the middleware so they don't flood the
+	// request log; failed checks are logged by the checker itself
+	r.GET("/healthz", healthHdlr.Live)
+	r.GET("/readyz", healthHdlr.Ready)
+
+	r.Use(logger.RequestLogger(), logger.Recovery(), logger.ErrorHandler())
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:8080"},
@@ -50,11 +176,14 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	r.GET("/.well-known/jwks.json", jwksHdlr.Get)
+
 	public := r.Group("/api/v1")
 	{
 		agents := public.Group("/agents")
 		{
 			agents.POST("/all", agentHdlr.Fetch)
+			agents.GET("/search", agentHdlr.Search)
 			agents.GET("/:agent_uuid", agentHdlr.GetByID)
 		}
 
@@ -67,23 +196,128 @@ func main() {
 		auth.GET("/check", authHdlr.Check)
 		auth.POST("/logout", authHdlr.Logout)
 		auth.POST("/refresh", authHdlr.Refresh)
+		auth.POST("/verify", authHdlr.Verify)
+		auth.POST("/verify/resend", authHdlr.ResendVerification)
+		auth.POST("/forgot-password", authHdlr.ForgotPassword)
+		auth.POST("/reset-password", authHdlr.ResetPassword)
+		auth.POST("/mfa/verify", mfaHdlr.Verify)
+		auth.GET("/oidc/:provider/start", oidcHdlr.Start)
+		auth.GET("/oidc/:provider/callback", oidcHdlr.Callback)
 	}
 
-	api := r.Group("/api/v1", m.AuthMiddleware())
+	api := r.Group("/api/v1", m.AuthMiddleware(sessionSv, apiKeySv))
 	{
-		agents := api.Group("/agents")
+		me := api.Group("/auth/me", m.SessionOnly())
+		{
+			me.GET("", authHdlr.GetByID)
+			me.PATCH("", authHdlr.Update)
+			me.DELETE("", authHdlr.Delete)
+		}
+
+		mfa := api.Group("/auth/mfa", m.SessionOnly())
+		{
+			mfa.POST("/enroll", mfaHdlr.Enroll)
+			mfa.POST("/confirm", mfaHdlr.Confirm)
+			mfa.POST("/disable", mfaHdlr.Disable)
+			mfa.POST("/recovery-codes", mfaHdlr.RegenerateRecoveryCodes)
+		}
+
+		sessions := api.Group("/auth/sessions", m.SessionOnly())
+		{
+			sessions.GET("", sessionHdlr.Fetch)
+			sessions.DELETE("", sessionHdlr.DeleteOthers)
+			sessions.DELETE("/:session_uuid", sessionHdlr.Delete)
+		}
+
+		apiKeys := api.Group("/auth/api-keys", m.SessionOnly())
+		{
+			apiKeys.POST("", apiKeyHdlr.Create)
+			apiKeys.GET("", apiKeyHdlr.Fetch)
+			apiKeys.DELETE("/:api_key_uuid", apiKeyHdlr.Delete)
+		}
+
+		creatorRequests := api.Group("/auth/creator-requests", m.SessionOnly())
+		{
+			creatorRequests.POST("", roleHdlr.Create)
+			creatorRequests.DELETE("/:request_uuid", roleHdlr.Delete)
+		}
+
+		agents := api.Group("/agents", m.RequireScope(akd.ScopeAgentsRead))
 		{
-			agents.POST("/create", agentHdlr.Create)
 			agents.GET("/categories", agentHdlr.FetchCategories)
 			agents.POST("/my-projects", agentHdlr.FetchByLoggedAuth)
 		}
 
+		creators := api.Group("/agents", m.AuthorizeRole(map[string]bool{rd.RoleCreator: true, rd.RoleAdmin: true}))
+		{
+			creators.POST("/create", m.RequireScope(akd.ScopeAgentsWrite), agentHdlr.Create)
+			creators.GET("/:agent_uuid/system", m.RequireScope(akd.ScopeAgentsRead), agentHdlr.GetSystemPreset)
+			creators.PATCH("/:agent_uuid", m.RequireScope(akd.ScopeAgentsWrite), agentHdlr.Update)
+			creators.DELETE("/:agent_uuid", m.RequireScope(akd.ScopeAgentsWrite), agentHdlr.Delete)
+		}
+
+		admin := api.Group("/admin", m.SessionOnly(), m.AuthorizeRole(map[string]bool{rd.RoleAdmin: true}))
+		{
+			categories := admin.Group("/categories")
+			{
+				categories.POST("", agentHdlr.CreateCategory)
+				categories.GET("/:category_id", agentHdlr.GetCategory)
+				categories.PATCH("/:category_id", agentHdlr.UpdateCategory)
+				categories.DELETE("/:category_id", agentHdlr.DeleteCategory)
+			}
+
+			admin.GET("/db/stats", dbStatsHdlr.Get)
+
+			auths := admin.Group("/auths")
+			{
+				auths.GET("", authHdlr.Fetch)
+				auths.PATCH("/:auth_uuid/role", roleHdlr.UpdateRole)
+			}
+
+			reviews := admin.Group("/creator-requests")
+			{
+				reviews.GET("", roleHdlr.Fetch)
+				reviews.POST("/:request_uuid/approve", roleHdlr.Approve)
+				reviews.POST("/:request_uuid/reject", roleHdlr.Reject)
+			}
+		}
+
 		chat := api.Group("/chat")
 		{
-			chat.POST("/create", chatHdlr.Create)
-			chat.POST("/send-new-message", chatHdlr.SendMessage)
+			chat.POST("/create", srv.RejectWhileDraining(), m.RequireScope(akd.ScopeChatWrite), chatHdlr.Create)
+			chat.POST("/send-new-message", srv.RejectWhileDraining(), m.RequireScope(akd.ScopeChatWrite), chatHdlr.SendMessage)
+			chat.GET("", m.RequireScope(akd.ScopeChatRead), chatHdlr.Fetch)
+			chat.GET("/:chat_uuid/messages", m.RequireScope(akd.ScopeChatRead), chatHdlr.FetchMessages)
+			chat.PATCH("/:chat_uuid", m.RequireScope(akd.ScopeChatWrite), chatHdlr.Update)
+			chat.DELETE("/:chat_uuid", m.RequireScope(akd.ScopeChatWrite), chatHdlr.Delete)
+			chat.POST("/:chat_uuid/restore", m.RequireScope(akd.ScopeChatWrite), chatHdlr.Restore)
 		}
 	}
 
-	r.Run(":8000")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = srv.Run(ctx, r)
+
+	// Streams cut off by the drain deadline may still be saving their
+	// reply; give them a moment before pulling the AI pool from under them
+	drainCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := chatSv.Drain(drainCtx); err != nil {
+		slog.Warn("Chat streams still running, closing the AI pool under them")
+	}
+	cancel()
+
+	mailCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := mailQueue.Close(mailCtx); err != nil {
+		slog.Warn("Mail queue not flushed, dropping queued emails")
+	}
+	cancel()
+
+	chatSv.Cleanup()
+	dbConn.Close()
+
+	if err != nil {
+		log.Fatalf("Error serving HTTP: %v", err)
+	}
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	db "aigents-base/internal/common/db"
+
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: app migrate <command>
+
+commands:
+  up                  apply every pending migration
+  down [steps]        revert the latest steps migrations (default 1)
+  status              list migrations and when they were applied
+  baseline <version>  mark migrations up to version as applied without
+                      running them, for databases created by hand`
+
+// runMigrate implements the migrate subcommand and exits with its result.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dbConn, err := db.Open(ctx, db.ConfigFromEnv())
+	if err != nil {
+		slog.Error("Failed to connect to database.", "error", err)
+		os.Exit(1)
+	}
+	defer dbConn.Close()
+
+	migrator, err := db.NewMigrator(dbConn)
+	if err != nil {
+		slog.Error("Failed to load migrations.", "error", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		exitOnMigrateErr(err)
+		slog.Info("Migrations applied", "count", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				os.Exit(2)
+			}
+		}
+
+		n, err := migrator.Down(ctx, steps)
+		exitOnMigrateErr(err)
+		slog.Info("Migrations reverted", "count", n)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		exitOnMigrateErr(err)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if !st.AppliedAt.IsZero() {
+				appliedAt = st.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		w.Flush()
+
+	case "baseline":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+
+		n, err := migrator.Baseline(ctx, version)
+		exitOnMigrateErr(err)
+		slog.Info("Migrations marked as applied", "count", n)
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+func exitOnMigrateErr(err error) {
+	if err != nil {
+		slog.Error("Migration failed.", "error", err)
+		os.Exit(1)
+	}
+}
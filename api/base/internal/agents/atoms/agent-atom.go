@@ -1,83 +1,102 @@
 package atoms
 
 import (
-	c_at "aigents-base/internal/common/atoms"
 	d "aigents-base/internal/agents/domain"
-	"database/sql"
-	"github.com/gin-gonic/gin"
+
+	"fmt"
+	"regexp"
+)
+
+const (
+	MaxSystemPromptLen    = 8000
+	MaxPersonaLen         = 256
+	MaxGreetingMessageLen = 1000
+	MaxStopSequences      = 4
+	MaxStopSequenceLen    = 64
+	MaxTemperature        = 2.0
+	MaxTokensLimit        = 8192
 )
 
+var responseLanguageRe = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// DefaultSystemPromptAtom derives the system prompt used when the creator
+// did not author one.
+func DefaultSystemPromptAtom(description string) string {
+	return fmt.Sprintf("You're a helpful assistant and your job will be doing this description: %s", description)
+}
+
+// ValidateSystemPresetAtom checks a preset against the limits the AI service
+// accepts, returning the first violation found. An empty system_prompt is
+// valid, category presets often only carry settings.
+func ValidateSystemPresetAtom(p *d.SystemPreset) error {
+	if len(p.SystemPrompt) > MaxSystemPromptLen {
+		return fmt.Errorf("system_prompt must be at most %d characters", MaxSystemPromptLen)
+	}
+
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > MaxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %.1f", MaxTemperature)
+	}
+
+	if p.MaxTokens != nil && (*p.MaxTokens == 0 || *p.MaxTokens > MaxTokensLimit) {
+		return fmt.Errorf("max_tokens must be between 1 and %d", MaxTokensLimit)
+	}
 
-func GetAgentByUUID(gctx *gin.Context, agentUUID string) *d.Agent {
-	query := `
-	SELECT
-		a.agent_uuid,
-		a.name,
-		a.description,
-		a.image_url,
-		a.auth_uuid,
-		a.created_at,
-		a.updated_at,
-		COALESCE(a.deleted_at, TIMESTAMP '0001-01-01 00:00:00'),
-		ac.category_id,
-		ac.category_name,
-		acfg.agent_config_uuid,
-		acfg.category_preset_enabled,
-		asys.agent_system_uuid,
-		asys.system_preset
-	FROM agents a
-	INNER JOIN agents_config acfg ON a.agent_config_uuid = acfg.agent_config_uuid
-	INNER JOIN agent_categories ac ON acfg.category_id = ac.category_id
-	INNER JOIN agent_systems asys ON acfg.agent_system_uuid = asys.agent_system_uuid
-	WHERE a.agent_uuid = $1 AND a.deleted_at IS NULL;
-	`
-
-	var data d.Agent
-	var systemPresetJSON []byte
-
-	err := r.db.QueryRow(query, agentUUID).Scan(
-		&data.AgentUUID,
-		&data.Name,
-		&data.Description,
-		&data.ImageURL,
-		&data.AuthUUID,
-		&data.CreatedAt,
-		&data.UpdatedAt,
-		&data.DeletedAt,
-		&data.AgentConfig.Category.CategoryID,
-		&data.AgentConfig.Category.CategoryName,
-		&data.AgentConfig.AgentConfigUUID,
-		&data.AgentConfig.CategoryPresetEnabled,
-		&data.AgentConfig.AgentSystem.AgentSystemUUID,
-		&systemPresetJSON,
-	)
-
-	if err == sql.ErrNoRows {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusNotFound,
-			"(R) Agent not found.",
-			fmt.Sprintf("Agent with UUID %s not found", data.AgentUUID))
-		return err
+	if len(p.Persona) > MaxPersonaLen {
+		return fmt.Errorf("persona must be at most %d characters", MaxPersonaLen)
 	}
 
-	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
-			"(R) Could not get agent.",
-			fmt.Sprintf("Failed to get agent: %s", err.Error()))
-		return err
+	if len(p.GreetingMessage) > MaxGreetingMessageLen {
+		return fmt.Errorf("greeting_message must be at most %d characters", MaxGreetingMessageLen)
 	}
 
-	if err := json.Unmarshal(systemPresetJSON, &data.AgentConfig.AgentSystem.SystemPreset); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
-			"(R) Could not parse agent system preset.",
-			fmt.Sprintf("Failed to unmarshal system_preset: %s", err.Error()))
-		return err
+	if len(p.StopSequences) > MaxStopSequences {
+		return fmt.Errorf("stop_sequences must have at most %d entries", MaxStopSequences)
+	}
+
+	for _, seq := range p.StopSequences {
+		if seq == "" || len(seq) > MaxStopSequenceLen {
+			return fmt.Errorf("stop_sequences entries must have between 1 and %d characters", MaxStopSequenceLen)
+		}
+	}
+
+	if p.ResponseLanguage != "" && !responseLanguageRe.MatchString(p.ResponseLanguage) {
+		return fmt.Errorf("response_language must be a language tag such as \"en\" or \"pt-BR\"")
 	}
 
 	return nil
 }
+
+// MergeSystemPresetAtom layers an agent preset over its category preset. The
+// category prompt goes first with the agent prompt appended after it, and
+// every other setting the agent defines overrides the category one.
+func MergeSystemPresetAtom(category, agent d.SystemPreset) d.SystemPreset {
+	merged := category
+
+	switch {
+	case category.SystemPrompt == "":
+		merged.SystemPrompt = agent.SystemPrompt
+	case agent.SystemPrompt != "":
+		merged.SystemPrompt = category.SystemPrompt + "\n\n" + agent.SystemPrompt
+	}
+
+	if agent.Temperature != nil {
+		merged.Temperature = agent.Temperature
+	}
+	if agent.MaxTokens != nil {
+		merged.MaxTokens = agent.MaxTokens
+	}
+	if agent.Persona != "" {
+		merged.Persona = agent.Persona
+	}
+	if agent.GreetingMessage != "" {
+		merged.GreetingMessage = agent.GreetingMessage
+	}
+	if len(agent.StopSequences) > 0 {
+		merged.StopSequences = agent.StopSequences
+	}
+	if agent.ResponseLanguage != "" {
+		merged.ResponseLanguage = agent.ResponseLanguage
+	}
+
+	return merged
+}
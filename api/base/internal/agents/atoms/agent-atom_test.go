@@ -0,0 +1,173 @@
+package atoms
+
+import (
+	d "aigents-base/internal/agents/domain"
+
+	"strings"
+	"testing"
+)
+
+func f64(v float64) *float64 { return &v }
+
+func u64(v uint64) *uint64 { return &v }
+
+func TestValidateSystemPresetAtom(t *testing.T) {
+	tests := []struct {
+		name    string
+		preset  d.SystemPreset
+		wantErr string
+	}{
+		{name: "empty preset", preset: d.SystemPreset{}},
+		{
+			name: "every field at its limit",
+			preset: d.SystemPreset{
+				SystemPrompt:     strings.Repeat("a", MaxSystemPromptLen),
+				Temperature:      f64(MaxTemperature),
+				MaxTokens:        u64(MaxTokensLimit),
+				Persona:          strings.Repeat("a", MaxPersonaLen),
+				GreetingMessage:  strings.Repeat("a", MaxGreetingMessageLen),
+				StopSequences:    []string{"a", "b", "c", strings.Repeat("d", MaxStopSequenceLen)},
+				ResponseLanguage: "pt-BR",
+			},
+		},
+		{name: "zero temperature", preset: d.SystemPreset{Temperature: f64(0)}},
+		{name: "one token", preset: d.SystemPreset{MaxTokens: u64(1)}},
+		{name: "three letter language", preset: d.SystemPreset{ResponseLanguage: "fil"}},
+		{
+			name:    "system prompt too long",
+			preset:  d.SystemPreset{SystemPrompt: strings.Repeat("a", MaxSystemPromptLen+1)},
+			wantErr: "system_prompt",
+		},
+		{name: "negative temperature", preset: d.SystemPreset{Temperature: f64(-0.1)}, wantErr: "temperature"},
+		{name: "temperature too high", preset: d.SystemPreset{Temperature: f64(MaxTemperature + 0.1)}, wantErr: "temperature"},
+		{name: "zero tokens", preset: d.SystemPreset{MaxTokens: u64(0)}, wantErr: "max_tokens"},
+		{name: "too many tokens", preset: d.SystemPreset{MaxTokens: u64(MaxTokensLimit + 1)}, wantErr: "max_tokens"},
+		{
+			name:    "persona too long",
+			preset:  d.SystemPreset{Persona: strings.Repeat("a", MaxPersonaLen+1)},
+			wantErr: "persona",
+		},
+		{
+			name:    "greeting too long",
+			preset:  d.SystemPreset{GreetingMessage: strings.Repeat("a", MaxGreetingMessageLen+1)},
+			wantErr: "greeting_message",
+		},
+		{
+			name:    "too many stop sequences",
+			preset:  d.SystemPreset{StopSequences: []string{"a", "b", "c", "d", "e"}},
+			wantErr: "stop_sequences must have",
+		},
+		{name: "empty stop sequence", preset: d.SystemPreset{StopSequences: []string{""}}, wantErr: "stop_sequences entries"},
+		{
+			name:    "stop sequence too long",
+			preset:  d.SystemPreset{StopSequences: []string{strings.Repeat("a", MaxStopSequenceLen+1)}},
+			wantErr: "stop_sequences entries",
+		},
+		{name: "language name", preset: d.SystemPreset{ResponseLanguage: "english"}, wantErr: "response_language"},
+		{name: "uppercase language", preset: d.SystemPreset{ResponseLanguage: "EN"}, wantErr: "response_language"},
+		{name: "language with underscore", preset: d.SystemPreset{ResponseLanguage: "pt_BR"}, wantErr: "response_language"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSystemPresetAtom(&tt.preset)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("error = %v, want valid", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to start with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeSystemPresetAtom(t *testing.T) {
+	category := d.SystemPreset{
+		SystemPrompt:     "Category prompt.",
+		Temperature:      f64(0.2),
+		MaxTokens:        u64(500),
+		Persona:          "teacher",
+		GreetingMessage:  "Hello from the category.",
+		StopSequences:    []string{"END"},
+		ResponseLanguage: "en",
+	}
+
+	tests := []struct {
+		name     string
+		category d.SystemPreset
+		agent    d.SystemPreset
+		want     d.SystemPreset
+	}{
+		{
+			name:     "empty agent keeps the category",
+			category: category,
+			agent:    d.SystemPreset{},
+			want:     category,
+		},
+		{
+			name:     "agent fields override",
+			category: category,
+			agent: d.SystemPreset{
+				Temperature:      f64(0),
+				MaxTokens:        u64(100),
+				Persona:          "pirate",
+				GreetingMessage:  "Ahoy.",
+				StopSequences:    []string{"ARR", "STOP"},
+				ResponseLanguage: "pt-BR",
+			},
+			want: d.SystemPreset{
+				SystemPrompt:     "Category prompt.",
+				Temperature:      f64(0),
+				MaxTokens:        u64(100),
+				Persona:          "pirate",
+				GreetingMessage:  "Ahoy.",
+				StopSequences:    []string{"ARR", "STOP"},
+				ResponseLanguage: "pt-BR",
+			},
+		},
+		{
+			name:     "prompts are joined, category first",
+			category: d.SystemPreset{SystemPrompt: "Category prompt."},
+			agent:    d.SystemPreset{SystemPrompt: "Agent prompt."},
+			want:     d.SystemPreset{SystemPrompt: "Category prompt.\n\nAgent prompt."},
+		},
+		{
+			name:     "agent prompt alone",
+			category: d.SystemPreset{Persona: "teacher"},
+			agent:    d.SystemPreset{SystemPrompt: "Agent prompt."},
+			want:     d.SystemPreset{SystemPrompt: "Agent prompt.", Persona: "teacher"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeSystemPresetAtom(tt.category, tt.agent)
+
+			if got.SystemPrompt != tt.want.SystemPrompt || got.Persona != tt.want.Persona ||
+				got.GreetingMessage != tt.want.GreetingMessage || got.ResponseLanguage != tt.want.ResponseLanguage ||
+				strings.Join(got.StopSequences, ",") != strings.Join(tt.want.StopSequences, ",") {
+				t.Errorf("merged = %+v, want %+v", got, tt.want)
+			}
+
+			if (got.Temperature == nil) != (tt.want.Temperature == nil) ||
+				(got.Temperature != nil && *got.Temperature != *tt.want.Temperature) {
+				t.Errorf("temperature = %v, want %v", got.Temperature, tt.want.Temperature)
+			}
+
+			if (got.MaxTokens == nil) != (tt.want.MaxTokens == nil) ||
+				(got.MaxTokens != nil && *got.MaxTokens != *tt.want.MaxTokens) {
+				t.Errorf("max_tokens = %v, want %v", got.MaxTokens, tt.want.MaxTokens)
+			}
+		})
+	}
+
+	// merging must not write through to the category preset it was given
+	if category.Persona != "teacher" || *category.Temperature != 0.2 {
+		t.Errorf("category preset changed by the merge: %+v", category)
+	}
+}
@@ -4,9 +4,21 @@ import (
 	"time"
 )
 
+// SystemPreset is the schema of agent_systems.system_preset. Optional numeric
+// settings are pointers so "unset" can be told apart from zero.
+type SystemPreset struct {
+	SystemPrompt     string   `json:"system_prompt,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	MaxTokens        *uint64  `json:"max_tokens,omitempty"`
+	Persona          string   `json:"persona,omitempty"`
+	GreetingMessage  string   `json:"greeting_message,omitempty"`
+	StopSequences    []string `json:"stop_sequences,omitempty"`
+	ResponseLanguage string   `json:"response_language,omitempty"`
+}
+
 type AgentSystem struct {
 	AgentSystemUUID     string        `json:"agent_system_uuid"`
-	SystemPreset map[string]any   `json:"system_preset"`
+	SystemPreset SystemPreset   `json:"system_preset"`
 	UpdatedAt           time.Time        `json:"updated_at"`
 }
 
@@ -35,10 +47,37 @@ type Agent struct {
 	Description     string    `json:"description"`
 	ImageURL        string   `json:"image_url"`
 	AgentConfig     AgentConfig `json:"agent_config"`
-	AuthUUID        string `json:"auth_uuid",omitempty`
+	AuthUUID        string `json:"auth_uuid,omitempty"`
+	ChatCount       uint64 `json:"chat_count,omitempty"`
 	CreatedAt           time.Time `json:"created_at"`
 	UpdatedAt           time.Time `json:"updated_at"`
 	DeletedAt             time.Time `json:"deleted_at"`
 }
 
+// AgentPatch is a partial update of an agent; nil fields keep their value.
+type AgentPatch struct {
+	Name                  *string
+	Description           *string
+	ImageURL              *string
+	CategoryID            *uint64
+	CategoryPresetEnabled *bool
+	SystemPreset          *SystemPreset
+}
+
+const (
+	AgentSortNewest      = "newest"
+	AgentSortMostChatted = "most_chatted"
+)
+
+// AgentFilter narrows FetchWithFilter; zero-valued fields are ignored.
+type AgentFilter struct {
+	CategoryIDs []uint64
+	OwnerUUID   string
+	Query       string
+	Sort        string
+}
 
+type AgentSearchResult struct {
+	Agents []Agent `json:"agents"`
+	Total  uint64  `json:"total"`
+}
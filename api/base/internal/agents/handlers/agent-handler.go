@@ -5,7 +5,10 @@ import (
 	agitf "aigents-base/internal/agents/interfaces"
 	m "aigents-base/internal/auth-land/auth-signature/middleware"
 	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
 	"net/http"
+	"strconv"
+	"strings"
 	"github.com/google/uuid"
 	"github.com/gin-gonic/gin"
 )
@@ -21,12 +24,11 @@ func NewAgentHandler(sv agitf.AgentServiceITF) *AgentHandler {
 func (h *AgentHandler) Create(gctx *gin.Context) {
 	authUUID, ok := m.GetAuthUUID(gctx)
 	if !ok {
-		err := c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+		err := errs.New(
+			errs.Unauthorized,
 			"(H) Invalid context values.",
 			"Invalid auth_uuid in context!")
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -35,15 +37,16 @@ func (h *AgentHandler) Create(gctx *gin.Context) {
 		Description string `json:"description"`
 		ImageURL string `json:"image_url"`
 		CategoryID uint64 `json:"category_id" binding:"required"`
+		CategoryPresetEnabled *bool `json:"category_preset_enabled"`
+		SystemPreset *d.SystemPreset `json:"system_preset"`
 	}
 
 	if err := gctx.ShouldBindJSON(&req); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -54,12 +57,17 @@ func (h *AgentHandler) Create(gctx *gin.Context) {
 		AuthUUID: authUUID,
 	}
 	agent.AgentConfig.Category.CategoryID = req.CategoryID
-	// Temporaly
-	agent.AgentConfig.CategoryPresetEnabled = false
+	if req.SystemPreset != nil {
+		agent.AgentConfig.AgentSystem.SystemPreset = *req.SystemPreset
+	}
+	agent.AgentConfig.CategoryPresetEnabled = true
+	if req.CategoryPresetEnabled != nil {
+		agent.AgentConfig.CategoryPresetEnabled = *req.CategoryPresetEnabled
+	}
 
-	err := h.s.Create(gctx, agent)
+	err := h.s.Create(gctx.Request.Context(), agent)
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -74,12 +82,11 @@ func (h *AgentHandler) GetByID(gctx *gin.Context) {
 	param := gctx.Param("agent_uuid")
 	agentUUID, err := uuid.Parse(param)
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid URL parameter.",
-			"Invalid agent_uuid param")
-		c_at.FeedErrLogToFile(err)
+			"Invalid agent_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -87,9 +94,10 @@ func (h *AgentHandler) GetByID(gctx *gin.Context) {
 		AgentUUID: agentUUID.String(),
 	}
 
-	err = h.s.GetByID(gctx, agent)
+	err = h.s.GetByID(gctx.Request.Context(), agent)
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
+		return
 	}
 
 	data := d.Agent{
@@ -101,6 +109,7 @@ func (h *AgentHandler) GetByID(gctx *gin.Context) {
 
 	data.AgentConfig.Category.CategoryID = agent.AgentConfig.Category.CategoryID
 	data.AgentConfig.Category.CategoryName = agent.AgentConfig.Category.CategoryName
+	data.AgentConfig.AgentSystem.SystemPreset.GreetingMessage = agent.AgentConfig.AgentSystem.SystemPreset.GreetingMessage
 
 	c_at.RespAtom[d.Agent](
 		gctx,
@@ -118,18 +127,17 @@ func (h *AgentHandler) Fetch(gctx *gin.Context) {
 
 	err := gctx.ShouldBindJSON(&req)
 	if  err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	data, err := h.s.Fetch(gctx, req.PageSize, req.Page)
+	data, err := h.s.Fetch(gctx.Request.Context(), req.PageSize, req.Page)
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -142,12 +150,11 @@ func (h *AgentHandler) Fetch(gctx *gin.Context) {
 func (h *AgentHandler) FetchByLoggedAuth(gctx *gin.Context) {
 	authUUID, ok := m.GetAuthUUID(gctx)
 	if !ok {
-		err := c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+		err := errs.New(
+			errs.Unauthorized,
 			"(H) Invalid context values.",
 			"Invalid auth_uuid in context!")
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -158,18 +165,17 @@ func (h *AgentHandler) FetchByLoggedAuth(gctx *gin.Context) {
 
 	err := gctx.ShouldBindJSON(&req)
 	if  err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	data, err := h.s.FetchAgentsByLoggedAuth(gctx, authUUID, req.PageSize, req.Page)
+	data, err := h.s.FetchAgentsByLoggedAuth(gctx.Request.Context(), authUUID, req.PageSize, req.Page)
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -180,9 +186,9 @@ func (h *AgentHandler) FetchByLoggedAuth(gctx *gin.Context) {
 }
 
 func (h *AgentHandler) FetchCategories(gctx *gin.Context) {
-	data, err := h.s.FetchCategories(gctx)
+	data, err := h.s.FetchCategories(gctx.Request.Context())
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -191,3 +197,293 @@ func (h *AgentHandler) FetchCategories(gctx *gin.Context) {
 		"(*) Data retrivied",
 		data)
 }
+
+func (h *AgentHandler) Update(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	agentUUID, err := uuid.Parse(gctx.Param("agent_uuid"))
+	if err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid agent_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name" binding:"omitempty,min=1,max=128"`
+		Description *string `json:"description" binding:"omitempty,max=512"`
+		ImageURL    *string `json:"image_url" binding:"omitempty,max=512"`
+		CategoryID  *uint64 `json:"category_id" binding:"omitempty,min=1"`
+		CategoryPresetEnabled *bool `json:"category_preset_enabled"`
+		SystemPreset *d.SystemPreset `json:"system_preset"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	patch := &d.AgentPatch{
+		Name:                  req.Name,
+		Description:           req.Description,
+		ImageURL:              req.ImageURL,
+		CategoryID:            req.CategoryID,
+		CategoryPresetEnabled: req.CategoryPresetEnabled,
+		SystemPreset:          req.SystemPreset,
+	}
+
+	agent := &d.Agent{AgentUUID: agentUUID.String(), AuthUUID: authUUID}
+	if err := h.s.Patch(gctx.Request.Context(), agent, patch); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx,
+		http.StatusOK,
+		"(*) Agent updated",
+		nil)
+}
+
+func (h *AgentHandler) Delete(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	agentUUID, err := uuid.Parse(gctx.Param("agent_uuid"))
+	if err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid agent_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	agent := &d.Agent{AgentUUID: agentUUID.String(), AuthUUID: authUUID}
+	if err := h.s.Delete(gctx.Request.Context(), agent); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx,
+		http.StatusOK,
+		"(*) Agent deleted",
+		nil)
+}
+
+func (h *AgentHandler) Search(gctx *gin.Context) {
+	var req struct {
+		CategoryIDs []uint64 `form:"category_id" binding:"omitempty,dive,min=1"`
+		Owner       string   `form:"owner" binding:"omitempty,uuid"`
+		Query       string   `form:"q" binding:"max=256"`
+		Sort        string   `form:"sort" binding:"omitempty,oneof=newest most_chatted"`
+		Page        uint64   `form:"page" binding:"omitempty,max=10000"`
+		PageSize    uint64   `form:"page_size" binding:"omitempty,min=1,max=100"`
+	}
+
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid query parameters.",
+			"Invalid query parameters.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	filter := &d.AgentFilter{
+		CategoryIDs: req.CategoryIDs,
+		OwnerUUID:   req.Owner,
+		Query:       strings.TrimSpace(req.Query),
+		Sort:        req.Sort,
+	}
+
+	agents, total, err := h.s.FetchWithFilter(gctx.Request.Context(), filter, req.PageSize, req.Page*req.PageSize)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.AgentSearchResult](gctx,
+		http.StatusOK,
+		"(*) Data retrivied",
+		d.AgentSearchResult{Agents: agents, Total: total})
+}
+
+func (h *AgentHandler) GetSystemPreset(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	agentUUID, err := uuid.Parse(gctx.Param("agent_uuid"))
+	if err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid agent_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	agent := &d.Agent{AgentUUID: agentUUID.String(), AuthUUID: authUUID}
+	if err := h.s.GetSystemPreset(gctx.Request.Context(), agent); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.AgentSystem](gctx,
+		http.StatusOK,
+		"(*) Data retrivied",
+		agent.AgentConfig.AgentSystem)
+}
+
+func (h *AgentHandler) CreateCategory(gctx *gin.Context) {
+	var req struct {
+		CategoryName string `json:"category_name" binding:"required,max=32"`
+		SystemPreset d.SystemPreset `json:"system_preset"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	category := &d.AgentCategory{CategoryName: req.CategoryName}
+	category.AgentSystemPreset.SystemPreset = req.SystemPreset
+
+	if err := h.s.CreateCategory(gctx.Request.Context(), category); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*d.AgentCategory](gctx,
+		http.StatusCreated,
+		"(*) Category created",
+		category)
+}
+
+func (h *AgentHandler) GetCategory(gctx *gin.Context) {
+	categoryID, ok := categoryIDFromParam(gctx)
+	if !ok {
+		return
+	}
+
+	category := &d.AgentCategory{CategoryID: categoryID}
+	if err := h.s.GetCategoryByID(gctx.Request.Context(), category); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*d.AgentCategory](gctx,
+		http.StatusOK,
+		"(*) Data retrivied",
+		category)
+}
+
+func (h *AgentHandler) UpdateCategory(gctx *gin.Context) {
+	categoryID, ok := categoryIDFromParam(gctx)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		CategoryName *string `json:"category_name" binding:"omitempty,min=1,max=32"`
+		SystemPreset *d.SystemPreset `json:"system_preset"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	category := &d.AgentCategory{CategoryID: categoryID}
+	if err := h.s.GetCategoryByID(gctx.Request.Context(), category); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.CategoryName != nil {
+		category.CategoryName = *req.CategoryName
+	}
+	if req.SystemPreset != nil {
+		category.AgentSystemPreset.SystemPreset = *req.SystemPreset
+	}
+
+	if err := h.s.UpdateCategory(gctx.Request.Context(), category); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*d.AgentCategory](gctx,
+		http.StatusOK,
+		"(*) Category updated",
+		category)
+}
+
+func (h *AgentHandler) DeleteCategory(gctx *gin.Context) {
+	categoryID, ok := categoryIDFromParam(gctx)
+	if !ok {
+		return
+	}
+
+	if err := h.s.DeleteCategory(gctx.Request.Context(), &d.AgentCategory{CategoryID: categoryID}); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx,
+		http.StatusOK,
+		"(*) Category deleted",
+		nil)
+}
+
+func categoryIDFromParam(gctx *gin.Context) (uint64, bool) {
+	categoryID, err := strconv.ParseUint(gctx.Param("category_id"), 10, 64)
+	if err != nil || categoryID == 0 {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid category_id param.")
+		c_at.AbortErrAtom(gctx, err)
+		return 0, false
+	}
+
+	return categoryID, true
+}
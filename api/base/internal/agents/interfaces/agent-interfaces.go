@@ -4,20 +4,31 @@ import (
 	citf "aigents-base/internal/common/interfaces"
 	d "aigents-base/internal/agents/domain"
 
-	"github.com/gin-gonic/gin"
+	"context"
 )
 
 type AgentServiceITF interface {
 	citf.Common[d.Agent]
-	FetchWithFilter(gctx *gin.Context, flags []string, limit, offset uint64) ([]d.Agent, error)
-	FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID string, limit, offset uint64) ([]d.Agent, error)
-	FetchCategories(gctx *gin.Context) ([]d.AgentCategory, error)
+	FetchWithFilter(ctx context.Context, filter *d.AgentFilter, limit, offset uint64) ([]d.Agent, uint64, error)
+	FetchAgentsByLoggedAuth(ctx context.Context, authUUID string, limit, offset uint64) ([]d.Agent, error)
+	FetchCategories(ctx context.Context) ([]d.AgentCategory, error)
+	GetSystemPreset(ctx context.Context, data *d.Agent) error
+	Patch(ctx context.Context, data *d.Agent, patch *d.AgentPatch) error
+	CreateCategory(ctx context.Context, data *d.AgentCategory) error
+	GetCategoryByID(ctx context.Context, data *d.AgentCategory) error
+	UpdateCategory(ctx context.Context, data *d.AgentCategory) error
+	DeleteCategory(ctx context.Context, data *d.AgentCategory) error
 }
 
 type AgentRepositoryITF interface {
 	citf.Common[d.Agent]
-	FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID string, limit, offset uint64) ([]d.Agent, error)
-	FetchCategories(gctx *gin.Context) ([]d.AgentCategory, error)
-	FetchWithFilter(gctx *gin.Context, flags []string, limit, offset uint64) ([]d.Agent, error)
-	GetAgentByUUID(gctx *gin.Context, agentUUID string) (*d.Agent, error)
+	FetchAgentsByLoggedAuth(ctx context.Context, authUUID string, limit, offset uint64) ([]d.Agent, error)
+	FetchCategories(ctx context.Context) ([]d.AgentCategory, error)
+	FetchWithFilter(ctx context.Context, filter *d.AgentFilter, limit, offset uint64) ([]d.Agent, uint64, error)
+	GetAgentByUUID(ctx context.Context, agentUUID string) (*d.Agent, error)
+	GetByIDForUpdate(ctx context.Context, data *d.Agent) error
+	CreateCategory(ctx context.Context, data *d.AgentCategory) error
+	GetCategoryByID(ctx context.Context, data *d.AgentCategory) error
+	UpdateCategory(ctx context.Context, data *d.AgentCategory) error
+	DeleteCategory(ctx context.Context, data *d.AgentCategory) error
 }
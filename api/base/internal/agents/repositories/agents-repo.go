@@ -3,15 +3,16 @@ package repositories
 import (
 	d "aigents-base/internal/agents/domain"
 	agitf "aigents-base/internal/agents/interfaces"
-	c_at "aigents-base/internal/common/atoms"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+	"context"
 	"fmt"
 
 	"database/sql"
-	"net/http"
+	"strings"
 
 	"encoding/json"
-	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type AgentRepository struct {
@@ -22,15 +23,19 @@ func NewAgentRepository(db *sql.DB) agitf.AgentRepositoryITF {
 	return &AgentRepository{db: db}
 }
 
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *AgentRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
 
-func (r *AgentRepository) Create(gctx *gin.Context, data *d.Agent) error {
+func (r *AgentRepository) Create(ctx context.Context, data *d.Agent) error {
 	systemPresetJSON, err := json.Marshal(data.AgentConfig.AgentSystem.SystemPreset)
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not marshal system preset.",
-			fmt.Sprintf("Failed to marshal system_preset: %s", err.Error()))
+			"Failed to marshal system_preset.", "error", err)
 		return err
 	}
 
@@ -60,7 +65,7 @@ func (r *AgentRepository) Create(gctx *gin.Context, data *d.Agent) error {
 	RETURNING agent_uuid, created_at, updated_at, COALESCE(deleted_at,'0001-01-01 00:00:00');
 	`
 
-	err = r.db.QueryRow(
+	err = r.conn(ctx).QueryRowContext(ctx,
 		query,
 		systemPresetJSON, // $1
 		data.AgentConfig.Category.CategoryID,      // $2
@@ -77,11 +82,10 @@ func (r *AgentRepository) Create(gctx *gin.Context, data *d.Agent) error {
 	)
 
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not create agent.",
-			fmt.Sprintf("Failed to create agent: %s", err.Error()))
+			"Failed to create agent.", "error", err)
 
 		return err
 	}
@@ -90,7 +94,17 @@ func (r *AgentRepository) Create(gctx *gin.Context, data *d.Agent) error {
 }
 
 
-func (r *AgentRepository) GetByID(gctx *gin.Context, data *d.Agent) error {
+func (r *AgentRepository) GetByID(ctx context.Context, data *d.Agent) error {
+	return r.getByID(ctx, data, "")
+}
+
+// GetByIDForUpdate is GetByID locking the agent row until the unit of work
+// ctx belongs to ends, so a read-modify-write of it can't lose an update.
+func (r *AgentRepository) GetByIDForUpdate(ctx context.Context, data *d.Agent) error {
+	return r.getByID(ctx, data, "FOR UPDATE OF a")
+}
+
+func (r *AgentRepository) getByID(ctx context.Context, data *d.Agent, lock string) error {
 	query := `
 	SELECT
 		a.agent_uuid,
@@ -106,17 +120,20 @@ func (r *AgentRepository) GetByID(gctx *gin.Context, data *d.Agent) error {
 		acfg.agent_config_uuid,
 		acfg.category_preset_enabled,
 		asys.agent_system_uuid,
-		asys.system_preset
+		asys.system_preset,
+		csys.agent_system_uuid,
+		csys.system_preset
 	FROM agents a
 	INNER JOIN agents_config acfg ON a.agent_config_uuid = acfg.agent_config_uuid
 	INNER JOIN agent_categories ac ON acfg.category_id = ac.category_id
 	INNER JOIN agent_systems asys ON acfg.agent_system_uuid = asys.agent_system_uuid
-	WHERE a.agent_uuid = $1 AND a.deleted_at IS NULL;
-	`
+	INNER JOIN agent_systems csys ON ac.agent_system_uuid_preset = csys.agent_system_uuid
+	WHERE a.agent_uuid = $1 AND a.deleted_at IS NULL
+	` + lock + ";"
 
-	var systemPresetJSON []byte
+	var systemPresetJSON, categoryPresetJSON []byte
 
-	err := r.db.QueryRow(query, data.AgentUUID).Scan(
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.AgentUUID).Scan(
 		&data.AgentUUID,
 		&data.Name,
 		&data.Description,
@@ -131,32 +148,39 @@ func (r *AgentRepository) GetByID(gctx *gin.Context, data *d.Agent) error {
 		&data.AgentConfig.CategoryPresetEnabled,
 		&data.AgentConfig.AgentSystem.AgentSystemUUID,
 		&systemPresetJSON,
+		&data.AgentConfig.Category.AgentSystemPreset.AgentSystemUUID,
+		&categoryPresetJSON,
 	)
 
 	if err == sql.ErrNoRows {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusNotFound,
+		err = errs.New(
+			errs.NotFound,
 			"(R) Agent not found.",
-			fmt.Sprintf("Agent with UUID %s not found", data.AgentUUID))
+			"Agent not found.", "agent_uuid", data.AgentUUID)
 		return err
 	}
 
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not get agent.",
-			fmt.Sprintf("Failed to get agent: %s", err.Error()))
+			"Failed to get agent.", "error", err)
 		return err
 	}
 
 	if err := json.Unmarshal(systemPresetJSON, &data.AgentConfig.AgentSystem.SystemPreset); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not parse agent system preset.",
-			fmt.Sprintf("Failed to unmarshal system_preset: %s", err.Error()))
+			"Failed to unmarshal system_preset.", "error", err)
+		return err
+	}
+
+	if err := json.Unmarshal(categoryPresetJSON, &data.AgentConfig.Category.AgentSystemPreset.SystemPreset); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not parse category system preset.",
+			"Failed to unmarshal category system_preset.", "error", err)
 		return err
 	}
 
@@ -164,7 +188,7 @@ func (r *AgentRepository) GetByID(gctx *gin.Context, data *d.Agent) error {
 }
 
 // without system
-func (r *AgentRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Agent, error) {
+func (r *AgentRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Agent, error) {
 	query := `
 	SELECT
 		a.agent_uuid,
@@ -185,13 +209,12 @@ func (r *AgentRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Ag
 	LIMIT $1 OFFSET $2;
 	`
 
-	rows, err := r.db.Query(query, limit, offset)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, limit, offset)
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not fetch agents.",
-			fmt.Sprintf("Failed to fetch agents: %s", err.Error()))
+			"Failed to fetch agents.", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -214,11 +237,10 @@ func (r *AgentRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Ag
 			&agent.DeletedAt,
 		)
 		if err != nil {
-			err = c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusInternalServerError,
+			err = errs.New(
+				errs.Internal,
 				"(R) Could not fetch agents.",
-				fmt.Sprintf("Failed to scan agent: %s", err.Error()))
+				"Failed to scan agent.", "error", err)
 			return nil, err
 		}
 
@@ -226,11 +248,10 @@ func (r *AgentRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Ag
 	}
 
 	if err = rows.Err(); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusInternalServerError,
+		err = errs.New(
+				errs.Internal,
 				"(R) Could not fetch agents.",
-				fmt.Sprintf("Row iteration failed: %s", err.Error()))
+				"Row iteration failed.", "error", err)
 
 		return nil, err
 	}
@@ -238,7 +259,7 @@ func (r *AgentRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Ag
 	return agents, nil
 }
 
-func (r *AgentRepository) GetAgentByUUID(gctx *gin.Context, agentUUID string) (*d.Agent, error) {
+func (r *AgentRepository) GetAgentByUUID(ctx context.Context, agentUUID string) (*d.Agent, error) {
 	query := `
 	SELECT
 		a.agent_uuid,
@@ -254,18 +275,21 @@ func (r *AgentRepository) GetAgentByUUID(gctx *gin.Context, agentUUID string) (*
 		acfg.agent_config_uuid,
 		acfg.category_preset_enabled,
 		asys.agent_system_uuid,
-		asys.system_preset
+		asys.system_preset,
+		csys.agent_system_uuid,
+		csys.system_preset
 	FROM agents a
 	INNER JOIN agents_config acfg ON a.agent_config_uuid = acfg.agent_config_uuid
 	INNER JOIN agent_categories ac ON acfg.category_id = ac.category_id
 	INNER JOIN agent_systems asys ON acfg.agent_system_uuid = asys.agent_system_uuid
+	INNER JOIN agent_systems csys ON ac.agent_system_uuid_preset = csys.agent_system_uuid
 	WHERE a.agent_uuid = $1 AND a.deleted_at IS NULL;
 	`
 
 	var data d.Agent
-	var systemPresetJSON []byte
+	var systemPresetJSON, categoryPresetJSON []byte
 
-	err := r.db.QueryRow(query, agentUUID).Scan(
+	err := r.conn(ctx).QueryRowContext(ctx, query, agentUUID).Scan(
 		&data.AgentUUID,
 		&data.Name,
 		&data.Description,
@@ -280,39 +304,46 @@ func (r *AgentRepository) GetAgentByUUID(gctx *gin.Context, agentUUID string) (*
 		&data.AgentConfig.CategoryPresetEnabled,
 		&data.AgentConfig.AgentSystem.AgentSystemUUID,
 		&systemPresetJSON,
+		&data.AgentConfig.Category.AgentSystemPreset.AgentSystemUUID,
+		&categoryPresetJSON,
 	)
 
 	if err == sql.ErrNoRows {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusNotFound,
+		err = errs.New(
+			errs.NotFound,
 			"(R) Agent not found.",
-			fmt.Sprintf("Agent with UUID %s not found", data.AgentUUID))
+			"Agent not found.", "agent_uuid", data.AgentUUID)
 		return nil, err
 	}
 
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not get agent.",
-			fmt.Sprintf("Failed to get agent: %s", err.Error()))
+			"Failed to get agent.", "error", err)
 		return nil, err
 	}
 
 	if err := json.Unmarshal(systemPresetJSON, &data.AgentConfig.AgentSystem.SystemPreset); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not parse agent system preset.",
-			fmt.Sprintf("Failed to unmarshal system_preset: %s", err.Error()))
+			"Failed to unmarshal system_preset.", "error", err)
+		return nil, err
+	}
+
+	if err := json.Unmarshal(categoryPresetJSON, &data.AgentConfig.Category.AgentSystemPreset.SystemPreset); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not parse category system preset.",
+			"Failed to unmarshal category system_preset.", "error", err)
 		return nil, err
 	}
 
 	return &data, nil
 }
 
-func (r *AgentRepository) FetchCategories(gctx *gin.Context) ([]d.AgentCategory, error) {
+func (r *AgentRepository) FetchCategories(ctx context.Context) ([]d.AgentCategory, error) {
 	query := `
 	SELECT
 		category_id,
@@ -322,13 +353,12 @@ func (r *AgentRepository) FetchCategories(gctx *gin.Context) ([]d.AgentCategory,
 	ORDER BY category_name ASC;
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.conn(ctx).QueryContext(ctx, query)
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not fetch categories.",
-			fmt.Sprintf("Failed to fetch categories: %s", err.Error()))
+			"Failed to fetch categories.", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -344,11 +374,10 @@ func (r *AgentRepository) FetchCategories(gctx *gin.Context) ([]d.AgentCategory,
 			&category.CreatedAt,
 		)
 		if err != nil {
-			err = c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusInternalServerError,
+			err = errs.New(
+				errs.Internal,
 				"(R) Could not fetch categories.",
-				fmt.Sprintf("Failed to scan category: %s", err.Error()))
+				"Failed to scan category.", "error", err)
 			return nil, err
 		}
 
@@ -356,11 +385,10 @@ func (r *AgentRepository) FetchCategories(gctx *gin.Context) ([]d.AgentCategory,
 	}
 
 	if err = rows.Err(); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not fetch categories.",
-			fmt.Sprintf("Row iteration failed: %s", err.Error()))
+			"Row iteration failed.", "error", err)
 		return nil, err
 	}
 
@@ -368,7 +396,7 @@ func (r *AgentRepository) FetchCategories(gctx *gin.Context) ([]d.AgentCategory,
 }
 
 // FetchAgentsByLoggedAuth retrieves all agents created by a specific authenticated user
-func (r *AgentRepository) FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID string, limit, offset uint64) ([]d.Agent, error) {
+func (r *AgentRepository) FetchAgentsByLoggedAuth(ctx context.Context, authUUID string, limit, offset uint64) ([]d.Agent, error) {
 	query := `
 	SELECT
 		a.agent_uuid,
@@ -389,13 +417,12 @@ func (r *AgentRepository) FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID st
 	LIMIT $2 OFFSET $3;
 	`
 
-	rows, err := r.db.Query(query, authUUID, limit, offset)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, authUUID, limit, offset)
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not fetch user agents.",
-			fmt.Sprintf("Failed to fetch agents for auth_uuid %s: %s", authUUID, err.Error()))
+			"Failed to fetch agents of auth.", "auth_uuid", authUUID, "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -418,11 +445,10 @@ func (r *AgentRepository) FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID st
 			&agent.DeletedAt,
 		)
 		if err != nil {
-			err = c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusInternalServerError,
+			err = errs.New(
+				errs.Internal,
 				"(R) Could not fetch user agents.",
-				fmt.Sprintf("Failed to scan agent: %s", err.Error()))
+				"Failed to scan agent.", "error", err)
 			return nil, err
 		}
 
@@ -430,25 +456,448 @@ func (r *AgentRepository) FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID st
 	}
 
 	if err = rows.Err(); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not fetch user agents.",
-			fmt.Sprintf("Row iteration failed: %s", err.Error()))
+			"Row iteration failed.", "error", err)
 		return nil, err
 	}
 
 	return agents, nil
 }
 
-func (r *AgentRepository) FetchWithFilter(gctx *gin.Context, flags []string, limit, offset uint64) ([]d.Agent, error) {
-	return []d.Agent{}, nil
+// FetchWithFilter searches the public agents and also returns how many match
+// the filter in total, for pagination. Text search runs against the
+// agents.search_vector column.
+func (r *AgentRepository) FetchWithFilter(ctx context.Context, filter *d.AgentFilter, limit, offset uint64) ([]d.Agent, uint64, error) {
+	conds := []string{"a.deleted_at IS NULL"}
+	args := []any{}
+
+	if len(filter.CategoryIDs) > 0 {
+		ids := make([]int64, 0, len(filter.CategoryIDs))
+		for _, id := range filter.CategoryIDs {
+			ids = append(ids, int64(id))
+		}
+		args = append(args, pq.Array(ids))
+		conds = append(conds, fmt.Sprintf("acfg.category_id = ANY($%d)", len(args)))
+	}
+
+	if filter.OwnerUUID != "" {
+		args = append(args, filter.OwnerUUID)
+		conds = append(conds, fmt.Sprintf("a.auth_uuid = $%d", len(args)))
+	}
+
+	if filter.Query != "" {
+		args = append(args, filter.Query)
+		conds = append(conds, fmt.Sprintf("a.search_vector @@ websearch_to_tsquery('simple', $%d)", len(args)))
+	}
+
+	where := strings.Join(conds, " AND ")
+
+	countQuery := `
+	SELECT COUNT(*)
+	FROM agents a
+	INNER JOIN agents_config acfg ON a.agent_config_uuid = acfg.agent_config_uuid
+	WHERE ` + where + `;`
+
+	var total uint64
+	if err := r.conn(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not search agents.",
+			"Failed to count agents.", "error", err)
+		return nil, 0, err
+	}
+
+	orderBy := "a.created_at DESC, a.agent_uuid DESC"
+	if filter.Sort == d.AgentSortMostChatted {
+		orderBy = "chat_count DESC, a.created_at DESC, a.agent_uuid DESC"
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`
+	SELECT
+		a.agent_uuid,
+		a.name,
+		a.description,
+		COALESCE(a.image_url, '') AS image_url,
+		a.auth_uuid,
+		ac.category_id,
+		ac.category_name,
+		(SELECT COUNT(*) FROM chats c WHERE c.agent_uuid = a.agent_uuid AND c.deleted_at IS NULL) AS chat_count,
+		a.created_at,
+		a.updated_at,
+		COALESCE(a.deleted_at, TIMESTAMP '0001-01-01 00:00:00')
+	FROM agents a
+	INNER JOIN agents_config acfg ON a.agent_config_uuid = acfg.agent_config_uuid
+	INNER JOIN agent_categories ac ON acfg.category_id = ac.category_id
+	WHERE %s
+	ORDER BY %s
+	LIMIT $%d OFFSET $%d;
+	`, where, orderBy, len(args)-1, len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not search agents.",
+			"Failed to search agents.", "error", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	agents := []d.Agent{}
+
+	for rows.Next() {
+		var agent d.Agent
+
+		err := rows.Scan(
+			&agent.AgentUUID,
+			&agent.Name,
+			&agent.Description,
+			&agent.ImageURL,
+			&agent.AuthUUID,
+			&agent.AgentConfig.Category.CategoryID,
+			&agent.AgentConfig.Category.CategoryName,
+			&agent.ChatCount,
+			&agent.CreatedAt,
+			&agent.UpdatedAt,
+			&agent.DeletedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not search agents.",
+				"Failed to scan agent.", "error", err)
+			return nil, 0, err
+		}
+
+		agents = append(agents, agent)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not search agents.",
+			"Row iteration failed.", "error", err)
+		return nil, 0, err
+	}
+
+	return agents, total, nil
+}
+
+// Update writes agents, agents_config and agent_systems in one transaction.
+// Only agents owned by data.AuthUUID are touched.
+func (r *AgentRepository) Update(ctx context.Context, data *d.Agent) error {
+	systemPresetJSON, err := json.Marshal(data.AgentConfig.AgentSystem.SystemPreset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not marshal system preset.",
+			"Failed to marshal system_preset.", "error", err)
+		return err
+	}
+
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		agentSQL := `
+		UPDATE agents
+		SET name = $3, description = $4, image_url = $5
+		WHERE agent_uuid = $1 AND auth_uuid = $2 AND deleted_at IS NULL
+		RETURNING agent_config_uuid, updated_at;
+		`
+
+		err := r.conn(ctx).QueryRowContext(ctx,
+			agentSQL,
+			data.AgentUUID,   // $1
+			data.AuthUUID,    // $2
+			data.Name,        // $3
+			data.Description, // $4
+			data.ImageURL,    // $5
+		).Scan(
+			&data.AgentConfig.AgentConfigUUID,
+			&data.UpdatedAt,
+		)
+
+		if err == sql.ErrNoRows {
+			err = errs.New(
+				errs.NotFound,
+				"(R) Agent not found.",
+				"Agent not found for auth.", "agent_uuid", data.AgentUUID, "auth_uuid", data.AuthUUID)
+			return err
+		}
+
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update agent.",
+				"Failed to update agent.", "error", err)
+			return err
+		}
+
+		configSQL := `
+		UPDATE agents_config
+		SET category_id = $2, category_preset_enabled = $3
+		WHERE agent_config_uuid = $1
+		RETURNING agent_system_uuid;
+		`
+
+		err = r.conn(ctx).QueryRowContext(ctx,
+			configSQL,
+			data.AgentConfig.AgentConfigUUID,
+			data.AgentConfig.Category.CategoryID,
+			data.AgentConfig.CategoryPresetEnabled,
+		).Scan(&data.AgentConfig.AgentSystem.AgentSystemUUID)
+
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				err = errs.New(
+					errs.Validation,
+					"(R) Category not found.",
+					"Category does not exist.", "category_id", data.AgentConfig.Category.CategoryID)
+				return err
+			}
+
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update agent.",
+				"Failed to update agent config.", "error", err)
+			return err
+		}
+
+		systemSQL := `
+		UPDATE agent_systems
+		SET system_preset = $2
+		WHERE agent_system_uuid = $1;
+		`
+
+		_, err = r.conn(ctx).ExecContext(ctx, systemSQL, data.AgentConfig.AgentSystem.AgentSystemUUID, systemPresetJSON)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update agent.",
+				"Failed to update agent system.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Delete soft-deletes an agent owned by data.AuthUUID.
+func (r *AgentRepository) Delete(ctx context.Context, data *d.Agent) error {
+	query := `
+	UPDATE agents
+	SET deleted_at = NOW()
+	WHERE agent_uuid = $1 AND auth_uuid = $2 AND deleted_at IS NULL
+	RETURNING deleted_at;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.AgentUUID, data.AuthUUID).Scan(&data.DeletedAt)
+	if err == sql.ErrNoRows {
+		err = errs.New(
+			errs.NotFound,
+			"(R) Agent not found.",
+			"Agent not found for auth.", "agent_uuid", data.AgentUUID, "auth_uuid", data.AuthUUID)
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not delete agent.",
+			"Failed to delete agent.", "error", err)
+		return err
+	}
+
+	return nil
 }
 
-func (r *AgentRepository) Update(gctx *gin.Context, data *d.Agent) error {
+func (r *AgentRepository) CreateCategory(ctx context.Context, data *d.AgentCategory) error {
+	systemPresetJSON, err := json.Marshal(data.AgentSystemPreset.SystemPreset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not marshal system preset.",
+			"Failed to marshal system_preset.", "error", err)
+		return err
+	}
+
+	query := `
+	WITH ins_system AS (
+		INSERT INTO agent_systems (system_preset)
+		VALUES ($1)
+		RETURNING agent_system_uuid
+	)
+	INSERT INTO agent_categories (category_name, agent_system_uuid_preset)
+	VALUES ($2, (SELECT agent_system_uuid FROM ins_system))
+	RETURNING category_id, agent_system_uuid_preset, created_at;
+	`
+
+	err = r.conn(ctx).QueryRowContext(ctx, query, systemPresetJSON, data.CategoryName).Scan(
+		&data.CategoryID,
+		&data.AgentSystemPreset.AgentSystemUUID,
+		&data.CreatedAt,
+	)
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not create category.",
+			"Failed to create category.", "error", err)
+		return err
+	}
+
 	return nil
 }
 
-func (r *AgentRepository) Delete(gctx *gin.Context, data *d.Agent) error {
+func (r *AgentRepository) GetCategoryByID(ctx context.Context, data *d.AgentCategory) error {
+	query := `
+	SELECT
+		ac.category_id,
+		ac.category_name,
+		ac.created_at,
+		asys.agent_system_uuid,
+		asys.system_preset,
+		asys.updated_at
+	FROM agent_categories ac
+	INNER JOIN agent_systems asys ON ac.agent_system_uuid_preset = asys.agent_system_uuid
+	WHERE ac.category_id = $1;
+	`
+
+	var systemPresetJSON []byte
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.CategoryID).Scan(
+		&data.CategoryID,
+		&data.CategoryName,
+		&data.CreatedAt,
+		&data.AgentSystemPreset.AgentSystemUUID,
+		&systemPresetJSON,
+		&data.AgentSystemPreset.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(
+			errs.NotFound,
+			"(R) Category not found.",
+			"Category not found.", "category_id", data.CategoryID)
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not get category.",
+			"Failed to get category.", "error", err)
+		return err
+	}
+
+	if err := json.Unmarshal(systemPresetJSON, &data.AgentSystemPreset.SystemPreset); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not parse category system preset.",
+			"Failed to unmarshal system_preset.", "error", err)
+		return err
+	}
+
 	return nil
 }
+
+// UpdateCategory writes the category name and its preset in one transaction.
+func (r *AgentRepository) UpdateCategory(ctx context.Context, data *d.AgentCategory) error {
+	systemPresetJSON, err := json.Marshal(data.AgentSystemPreset.SystemPreset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not marshal system preset.",
+			"Failed to marshal system_preset.", "error", err)
+		return err
+	}
+
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		categorySQL := `
+		UPDATE agent_categories
+		SET category_name = $2
+		WHERE category_id = $1
+		RETURNING agent_system_uuid_preset;
+		`
+
+		err := r.conn(ctx).QueryRowContext(ctx, categorySQL, data.CategoryID, data.CategoryName).Scan(&data.AgentSystemPreset.AgentSystemUUID)
+		if err == sql.ErrNoRows {
+			err = errs.New(
+				errs.NotFound,
+				"(R) Category not found.",
+				"Category not found.", "category_id", data.CategoryID)
+			return err
+		}
+
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update category.",
+				"Failed to update category.", "error", err)
+			return err
+		}
+
+		systemSQL := `
+		UPDATE agent_systems
+		SET system_preset = $2
+		WHERE agent_system_uuid = $1
+		RETURNING updated_at;
+		`
+
+		err = r.conn(ctx).QueryRowContext(ctx, systemSQL, data.AgentSystemPreset.AgentSystemUUID, systemPresetJSON).Scan(&data.AgentSystemPreset.UpdatedAt)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update category.",
+				"Failed to update category system.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// DeleteCategory removes a category and its preset. Categories still used by
+// an agent are refused with a conflict.
+func (r *AgentRepository) DeleteCategory(ctx context.Context, data *d.AgentCategory) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		categorySQL := `
+		DELETE FROM agent_categories
+		WHERE category_id = $1
+		RETURNING agent_system_uuid_preset;
+		`
+
+		var systemUUID string
+		err := r.conn(ctx).QueryRowContext(ctx, categorySQL, data.CategoryID).Scan(&systemUUID)
+		if err == sql.ErrNoRows {
+			err = errs.New(
+				errs.NotFound,
+				"(R) Category not found.",
+				"Category not found.", "category_id", data.CategoryID)
+			return err
+		}
+
+		if err == nil {
+			_, err = r.conn(ctx).ExecContext(ctx, "DELETE FROM agent_systems WHERE agent_system_uuid = $1;", systemUUID)
+		}
+
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				err = errs.New(
+					errs.Conflict,
+					"(R) Category is still in use.",
+					"Category is still referenced.", "category_id", data.CategoryID, "detail", pgErr.Message)
+				return err
+			}
+
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not delete category.",
+				"Failed to delete category.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
@@ -1,55 +1,217 @@
 package services
 
 import (
+	ag_at "aigents-base/internal/agents/atoms"
 	d "aigents-base/internal/agents/domain"
 	agitf "aigents-base/internal/agents/interfaces"
+	errs "aigents-base/internal/common/errs"
+	citf "aigents-base/internal/common/interfaces"
 
-
-	"github.com/gin-gonic/gin"
+	"context"
 	"fmt"
 )
 
-
 type AgentService struct {
-	r agitf.AgentRepositoryITF
+	r  agitf.AgentRepositoryITF
+	tx citf.TxManagerITF
 }
 
-func NewAgentService(repo agitf.AgentRepositoryITF) agitf.AgentServiceITF {
-	return &AgentService{r: repo}
+func NewAgentService(repo agitf.AgentRepositoryITF, tx citf.TxManagerITF) agitf.AgentServiceITF {
+	return &AgentService{r: repo, tx: tx}
 }
 
-func (s *AgentService) Create(gctx *gin.Context, data *d.Agent) error {
-	data.AgentConfig.AgentSystem.SystemPreset = map[string]any{
-		"system_prompt": fmt.Sprintf("You're a helpful assistant and your job will be doing this description: %s", data.Description),
+// Create stores the preset authored by the creator, falling back to a prompt
+// derived from the description when none was given. The category is checked
+// in the same transaction the agent is written in.
+func (s *AgentService) Create(ctx context.Context, data *d.Agent) error {
+	preset := &data.AgentConfig.AgentSystem.SystemPreset
+	if preset.SystemPrompt == "" {
+		preset.SystemPrompt = ag_at.DefaultSystemPromptAtom(data.Description)
+	}
+
+	if err := s.validateSystemPreset(ctx, preset); err != nil {
+		return err
 	}
 
-	return s.r.Create(gctx, data)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		category := &d.AgentCategory{CategoryID: data.AgentConfig.Category.CategoryID}
+		if err := s.r.GetCategoryByID(ctx, category); err != nil {
+			return err
+		}
+		data.AgentConfig.Category.CategoryName = category.CategoryName
+
+		return s.r.Create(ctx, data)
+	})
 }
 
-func (s *AgentService) FetchAgentsByLoggedAuth(gctx *gin.Context, authUUID string, limit, offset uint64) ([]d.Agent, error) {
-	return s.r.FetchAgentsByLoggedAuth(gctx, authUUID, limit, offset)
+func (s *AgentService) FetchAgentsByLoggedAuth(ctx context.Context, authUUID string, limit, offset uint64) ([]d.Agent, error) {
+	return s.r.FetchAgentsByLoggedAuth(ctx, authUUID, limit, offset)
 }
 
-func (s *AgentService) FetchCategories(gctx *gin.Context) ([]d.AgentCategory, error) {
-	return s.r.FetchCategories(gctx)
+func (s *AgentService) FetchCategories(ctx context.Context) ([]d.AgentCategory, error) {
+	return s.r.FetchCategories(ctx)
 }
 
-func (s *AgentService) GetByID(gctx *gin.Context, data *d.Agent) error {
-	return s.r.GetByID(gctx, data)
+func (s *AgentService) GetByID(ctx context.Context, data *d.Agent) error {
+	return s.r.GetByID(ctx, data)
 }
 
-func (s *AgentService) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Agent, error) {
-	return s.r.Fetch(gctx, limit, offset)
+func (s *AgentService) Fetch(ctx context.Context, limit, offset uint64) ([]d.Agent, error) {
+	return s.r.Fetch(ctx, limit, offset)
 }
 
-func (s *AgentService) Update(gctx *gin.Context, data *d.Agent) error {
-	return s.r.Update(gctx, data)
+// Update expects data to hold the full new state of the agent and
+// data.AuthUUID the requesting auth.
+func (s *AgentService) Update(ctx context.Context, data *d.Agent) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current := &d.Agent{AgentUUID: data.AgentUUID}
+		if err := s.r.GetByIDForUpdate(ctx, current); err != nil {
+			return err
+		}
+
+		if err := s.authorizeOwner(ctx, current, data.AuthUUID); err != nil {
+			return err
+		}
+
+		return s.save(ctx, current, data)
+	})
 }
 
-func (s *AgentService) Delete(gctx *gin.Context, data *d.Agent) error {
-	return s.r.Delete(gctx, data)
+// Patch applies patch to the agent data.AgentUUID owned by data.AuthUUID,
+// leaving data with the updated agent. The agent stays locked from the read
+// to the write, so concurrent patches don't undo each other.
+func (s *AgentService) Patch(ctx context.Context, data *d.Agent, patch *d.AgentPatch) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		authUUID := data.AuthUUID
+		if err := s.r.GetByIDForUpdate(ctx, data); err != nil {
+			return err
+		}
+
+		if err := s.authorizeOwner(ctx, data, authUUID); err != nil {
+			return err
+		}
+
+		current := *data
+
+		if patch.Name != nil {
+			data.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			data.Description = *patch.Description
+		}
+		if patch.ImageURL != nil {
+			data.ImageURL = *patch.ImageURL
+		}
+		if patch.CategoryID != nil {
+			data.AgentConfig.Category.CategoryID = *patch.CategoryID
+		}
+		if patch.CategoryPresetEnabled != nil {
+			data.AgentConfig.CategoryPresetEnabled = *patch.CategoryPresetEnabled
+		}
+		if patch.SystemPreset != nil {
+			data.AgentConfig.AgentSystem.SystemPreset = *patch.SystemPreset
+		}
+
+		return s.save(ctx, &current, data)
+	})
 }
 
-func (a *AgentService) FetchWithFilter(gctx *gin.Context, flags []string, limit, offset uint64) ([]d.Agent, error) {
-	return []d.Agent{}, nil
+// save writes data over current. A system prompt that was derived from the
+// description (or left empty) follows description changes; an authored one
+// is kept as is.
+func (s *AgentService) save(ctx context.Context, current, data *d.Agent) error {
+	preset := &data.AgentConfig.AgentSystem.SystemPreset
+	currentPrompt := current.AgentConfig.AgentSystem.SystemPreset.SystemPrompt
+	if preset.SystemPrompt == "" ||
+		(preset.SystemPrompt == currentPrompt && currentPrompt == ag_at.DefaultSystemPromptAtom(current.Description)) {
+		preset.SystemPrompt = ag_at.DefaultSystemPromptAtom(data.Description)
+	}
+
+	if err := s.validateSystemPreset(ctx, preset); err != nil {
+		return err
+	}
+
+	return s.r.Update(ctx, data)
+}
+
+// GetSystemPreset loads an agent including its system preset, which only the
+// owner (data.AuthUUID) may read.
+func (s *AgentService) GetSystemPreset(ctx context.Context, data *d.Agent) error {
+	authUUID := data.AuthUUID
+	if err := s.r.GetByID(ctx, data); err != nil {
+		return err
+	}
+
+	return s.authorizeOwner(ctx, data, authUUID)
+}
+
+// Delete retires an agent owned by data.AuthUUID. Chats with it stay
+// readable, but no new messages can be sent to it.
+func (s *AgentService) Delete(ctx context.Context, data *d.Agent) error {
+	current := &d.Agent{AgentUUID: data.AgentUUID}
+	if err := s.r.GetByID(ctx, current); err != nil {
+		return err
+	}
+
+	if err := s.authorizeOwner(ctx, current, data.AuthUUID); err != nil {
+		return err
+	}
+
+	return s.r.Delete(ctx, data)
+}
+
+func (s *AgentService) CreateCategory(ctx context.Context, data *d.AgentCategory) error {
+	if err := s.validateSystemPreset(ctx, &data.AgentSystemPreset.SystemPreset); err != nil {
+		return err
+	}
+
+	return s.r.CreateCategory(ctx, data)
+}
+
+func (s *AgentService) GetCategoryByID(ctx context.Context, data *d.AgentCategory) error {
+	return s.r.GetCategoryByID(ctx, data)
+}
+
+func (s *AgentService) UpdateCategory(ctx context.Context, data *d.AgentCategory) error {
+	if err := s.validateSystemPreset(ctx, &data.AgentSystemPreset.SystemPreset); err != nil {
+		return err
+	}
+
+	return s.r.UpdateCategory(ctx, data)
+}
+
+func (s *AgentService) DeleteCategory(ctx context.Context, data *d.AgentCategory) error {
+	return s.r.DeleteCategory(ctx, data)
+}
+
+func (s *AgentService) validateSystemPreset(ctx context.Context, preset *d.SystemPreset) error {
+	if err := ag_at.ValidateSystemPresetAtom(preset); err != nil {
+		err = errs.New(
+			errs.Validation,
+			fmt.Sprintf("(S) Invalid system preset: %s.", err.Error()),
+			"Invalid system preset.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *AgentService) authorizeOwner(ctx context.Context, agent *d.Agent, authUUID string) error {
+	if agent.AuthUUID != authUUID {
+		err := errs.New(
+			errs.Forbidden,
+			"(S) Agent does not belong to this authentication.",
+			"Auth tried to modify an agent it does not own.", "auth_uuid", authUUID, "agent_uuid", agent.AgentUUID, "owner_uuid", agent.AuthUUID)
+		return err
+	}
+
+	return nil
+}
+
+func (s *AgentService) FetchWithFilter(ctx context.Context, filter *d.AgentFilter, limit, offset uint64) ([]d.Agent, uint64, error) {
+	if filter.Sort == "" {
+		filter.Sort = d.AgentSortNewest
+	}
+
+	return s.r.FetchWithFilter(ctx, filter, limit, offset)
 }
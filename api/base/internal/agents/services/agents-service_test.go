@@ -0,0 +1,202 @@
+package services
+
+import (
+	ag_at "aigents-base/internal/agents/atoms"
+	d "aigents-base/internal/agents/domain"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"testing"
+)
+
+type txKey struct{}
+
+// fakeTx marks the ctx handed to fn, so the repository can tell which calls
+// ran inside the unit of work.
+type fakeTx struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, txKey{}, true))
+}
+
+func inTx(ctx context.Context) bool {
+	v, _ := ctx.Value(txKey{}).(bool)
+	return v
+}
+
+// fakeAgentRepository holds a single agent and records whether it was
+// locked and written inside a transaction.
+type fakeAgentRepository struct {
+	agent       d.Agent
+	lockedInTx  bool
+	updated     *d.Agent
+	updatedInTx bool
+}
+
+func (r *fakeAgentRepository) Create(ctx context.Context, data *d.Agent) error {
+	return nil
+}
+
+func (r *fakeAgentRepository) GetByID(ctx context.Context, data *d.Agent) error {
+	if data.AgentUUID != r.agent.AgentUUID {
+		return errs.New(errs.NotFound, "(R) Agent not found.", "Agent not found.")
+	}
+
+	*data = r.agent
+	return nil
+}
+
+func (r *fakeAgentRepository) GetByIDForUpdate(ctx context.Context, data *d.Agent) error {
+	r.lockedInTx = inTx(ctx)
+	return r.GetByID(ctx, data)
+}
+
+func (r *fakeAgentRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Agent, error) {
+	return nil, nil
+}
+
+func (r *fakeAgentRepository) Update(ctx context.Context, data *d.Agent) error {
+	saved := *data
+	r.updated, r.updatedInTx = &saved, inTx(ctx)
+	return nil
+}
+
+func (r *fakeAgentRepository) Delete(ctx context.Context, data *d.Agent) error {
+	return nil
+}
+
+func (r *fakeAgentRepository) FetchAgentsByLoggedAuth(ctx context.Context, authUUID string, limit, offset uint64) ([]d.Agent, error) {
+	return nil, nil
+}
+
+func (r *fakeAgentRepository) FetchCategories(ctx context.Context) ([]d.AgentCategory, error) {
+	return nil, nil
+}
+
+func (r *fakeAgentRepository) FetchWithFilter(ctx context.Context, filter *d.AgentFilter, limit, offset uint64) ([]d.Agent, uint64, error) {
+	return nil, 0, nil
+}
+
+func (r *fakeAgentRepository) GetAgentByUUID(ctx context.Context, agentUUID string) (*d.Agent, error) {
+	return nil, nil
+}
+
+func (r *fakeAgentRepository) CreateCategory(ctx context.Context, data *d.AgentCategory) error {
+	return nil
+}
+
+func (r *fakeAgentRepository) GetCategoryByID(ctx context.Context, data *d.AgentCategory) error {
+	return nil
+}
+
+func (r *fakeAgentRepository) UpdateCategory(ctx context.Context, data *d.AgentCategory) error {
+	return nil
+}
+
+func (r *fakeAgentRepository) DeleteCategory(ctx context.Context, data *d.AgentCategory) error {
+	return nil
+}
+
+func testAgent() d.Agent {
+	agent := d.Agent{
+		AgentUUID:   "agent-1",
+		AuthUUID:    "owner",
+		Name:        "Tutor",
+		Description: "Teaches maths.",
+		ImageURL:    "https://example.com/tutor.png",
+	}
+	agent.AgentConfig.Category.CategoryID = 1
+	agent.AgentConfig.AgentSystem.SystemPreset.SystemPrompt = ag_at.DefaultSystemPromptAtom(agent.Description)
+
+	return agent
+}
+
+func TestPatchKeepsUnsetFields(t *testing.T) {
+	repo := &fakeAgentRepository{agent: testAgent()}
+	sv := &AgentService{r: repo, tx: fakeTx{}}
+
+	name, description := "Maths tutor", "Teaches algebra."
+	data := &d.Agent{AgentUUID: "agent-1", AuthUUID: "owner"}
+	err := sv.Patch(context.Background(), data, &d.AgentPatch{Name: &name, Description: &description})
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+
+	if !repo.lockedInTx || !repo.updatedInTx {
+		t.Errorf("locked in tx = %v, updated in tx = %v; want both in the same unit of work", repo.lockedInTx, repo.updatedInTx)
+	}
+
+	got := repo.updated
+	if got.Name != name || got.Description != description {
+		t.Errorf("saved %q/%q, want %q/%q", got.Name, got.Description, name, description)
+	}
+
+	if got.ImageURL != repo.agent.ImageURL || got.AgentConfig.Category.CategoryID != 1 {
+		t.Errorf("unset fields changed: %+v", got)
+	}
+
+	// the derived prompt follows the new description
+	if prompt := got.AgentConfig.AgentSystem.SystemPreset.SystemPrompt; prompt != ag_at.DefaultSystemPromptAtom(description) {
+		t.Errorf("prompt = %q", prompt)
+	}
+
+	if data.Name != name {
+		t.Errorf("data not left with the updated agent: %+v", data)
+	}
+}
+
+func TestPatchKeepsAuthoredPrompt(t *testing.T) {
+	agent := testAgent()
+	agent.AgentConfig.AgentSystem.SystemPreset.SystemPrompt = "Only answer in haiku."
+
+	repo := &fakeAgentRepository{agent: agent}
+	sv := &AgentService{r: repo, tx: fakeTx{}}
+
+	description := "Teaches algebra."
+	err := sv.Patch(context.Background(), &d.Agent{AgentUUID: "agent-1", AuthUUID: "owner"}, &d.AgentPatch{Description: &description})
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+
+	if prompt := repo.updated.AgentConfig.AgentSystem.SystemPreset.SystemPrompt; prompt != "Only answer in haiku." {
+		t.Errorf("prompt = %q, want the authored one", prompt)
+	}
+}
+
+func TestPatchRefuses(t *testing.T) {
+	name := "Stolen"
+	tooHot := ag_at.MaxTemperature + 1
+
+	tests := []struct {
+		name  string
+		data  d.Agent
+		patch d.AgentPatch
+		want  error
+	}{
+		{"foreign agent", d.Agent{AgentUUID: "agent-1", AuthUUID: "intruder"}, d.AgentPatch{Name: &name}, errs.Forbidden},
+		{"missing agent", d.Agent{AgentUUID: "agent-2", AuthUUID: "owner"}, d.AgentPatch{Name: &name}, errs.NotFound},
+		{
+			"invalid preset",
+			d.Agent{AgentUUID: "agent-1", AuthUUID: "owner"},
+			d.AgentPatch{SystemPreset: &d.SystemPreset{Temperature: &tooHot}},
+			errs.Validation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAgentRepository{agent: testAgent()}
+			sv := &AgentService{r: repo, tx: fakeTx{}}
+
+			err := sv.Patch(context.Background(), &tt.data, &tt.patch)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+
+			if repo.updated != nil {
+				t.Errorf("agent written: %+v", repo.updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package atoms
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// KeyMarker starts every API key, telling them apart from JWTs in the
+// Authorization header.
+const KeyMarker = "aig_"
+
+// NewAPIKeyAtom returns a key shaped aig_<prefix>_<secret> together with its
+// visible prefix and the hash to store.
+func NewAPIKeyAtom() (key, prefix, hash string, err error) {
+	buf := make([]byte, 28)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	encoded := hex.EncodeToString(buf)
+	prefix = KeyMarker + encoded[:8]
+	key = prefix + "_" + encoded[8:]
+
+	return key, prefix, HashAPIKeyAtom(key), nil
+}
+
+func HashAPIKeyAtom(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package atoms
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewAPIKeyAtom(t *testing.T) {
+	key, prefix, hash, err := NewAPIKeyAtom()
+	if err != nil {
+		t.Fatalf("NewAPIKeyAtom: %v", err)
+	}
+
+	if !regexp.MustCompile(`^aig_[0-9a-f]{8}_[0-9a-f]{48}$`).MatchString(key) {
+		t.Errorf("key %q is not aig_<8 hex>_<48 hex>", key)
+	}
+
+	if !strings.HasPrefix(key, prefix+"_") || !strings.HasPrefix(prefix, KeyMarker) {
+		t.Errorf("prefix %q does not start key %q", prefix, key)
+	}
+
+	// the stored hash is found again from the key the client sends
+	if hash != HashAPIKeyAtom(key) {
+		t.Errorf("hash %q differs from HashAPIKeyAtom(key)", hash)
+	}
+
+	if strings.Contains(hash, key[len(prefix)+1:]) {
+		t.Errorf("hash carries the secret part of the key")
+	}
+
+	other, _, otherHash, err := NewAPIKeyAtom()
+	if err != nil {
+		t.Fatalf("NewAPIKeyAtom: %v", err)
+	}
+
+	if other == key || otherHash == hash {
+		t.Errorf("two keys match, secret not random")
+	}
+
+	if HashAPIKeyAtom(key+"x") == hash {
+		t.Errorf("a different key hashes the same")
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	ScopeAgentsRead  = "agents:read"
+	ScopeAgentsWrite = "agents:write"
+	ScopeChatRead    = "chat:read"
+	ScopeChatWrite   = "chat:write"
+)
+
+// Scopes lists every scope a key can be granted.
+var Scopes = []string{ScopeAgentsRead, ScopeAgentsWrite, ScopeChatRead, ScopeChatWrite}
+
+// MaxActiveKeys caps how many usable keys an authentication can hold.
+const MaxActiveKeys = 20
+
+// APIKey is a personal key for programmatic access. The key itself is only
+// known at creation (Key); afterwards just its Prefix is shown.
+type APIKey struct {
+	APIKeyUUID string    `json:"api_key_uuid"`
+	AuthUUID   string    `json:"-"`
+	Role       string    `json:"-"`
+	Name       string    `json:"name"`
+	Key        string    `json:"key,omitempty"`
+	Prefix     string    `json:"prefix"`
+	KeyHash    string    `json:"-"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	RevokedAt  time.Time `json:"revoked_at"`
+}
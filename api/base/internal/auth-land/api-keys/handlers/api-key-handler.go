@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	d "aigents-base/internal/auth-land/api-keys/domain"
+	akitf "aigents-base/internal/auth-land/api-keys/interfaces"
+	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type APIKeyHandler struct {
+	s akitf.APIKeyServiceITF
+}
+
+func NewAPIKeyHandler(sv akitf.APIKeyServiceITF) *APIKeyHandler {
+	return &APIKeyHandler{s: sv}
+}
+
+// Create answers with the full key; it cannot be shown again afterwards.
+func (h *APIKeyHandler) Create(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name" binding:"required,max=100"`
+		Scopes        []string `json:"scopes" binding:"required,min=1,max=10"`
+		ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request or values.", "error", err)
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	key := d.APIKey{
+		AuthUUID: authUUID,
+		Name:     req.Name,
+		Scopes:   req.Scopes,
+	}
+
+	if req.ExpiresInDays > 0 {
+		key.ExpiresAt = time.Now().AddDate(0, 0, req.ExpiresInDays)
+	}
+
+	if err := h.s.Create(gctx.Request.Context(), &key); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.APIKey](gctx, http.StatusCreated, "(*) API key created.", key)
+}
+
+func (h *APIKeyHandler) Fetch(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	keys, err := h.s.FetchByAuth(gctx.Request.Context(), authUUID)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[[]d.APIKey](gctx, http.StatusOK, "(*) API keys found.", keys)
+}
+
+// Delete revokes one key of the logged auth.
+func (h *APIKeyHandler) Delete(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	keyUUID := gctx.Param("api_key_uuid")
+	if _, err := uuid.Parse(keyUUID); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid API key UUID.",
+			"Invalid api_key_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	err := h.s.Delete(gctx.Request.Context(), &d.APIKey{APIKeyUUID: keyUUID, AuthUUID: authUUID})
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) API key revoked.", nil)
+}
@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	d "aigents-base/internal/auth-land/api-keys/domain"
+
+	"context"
+)
+
+type APIKeyServiceITF interface {
+	Create(ctx context.Context, data *d.APIKey) error
+	FetchByAuth(ctx context.Context, authUUID string) ([]d.APIKey, error)
+	Delete(ctx context.Context, data *d.APIKey) error
+	Authenticate(ctx context.Context, key string) (*d.APIKey, error)
+}
+
+type APIKeyRepositoryITF interface {
+	Create(ctx context.Context, data *d.APIKey) error
+	FetchByAuth(ctx context.Context, authUUID string) ([]d.APIKey, error)
+	Delete(ctx context.Context, data *d.APIKey) error
+	Authenticate(ctx context.Context, data *d.APIKey) error
+}
@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/api-keys/domain"
+	akitf "aigents-base/internal/auth-land/api-keys/interfaces"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) akitf.APIKeyRepositoryITF {
+	return &APIKeyRepository{db: db}
+}
+
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *APIKeyRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
+// Create stores a key unless its auth already holds d.MaxActiveKeys usable
+// ones. The count and the insert run under a per auth lock, so concurrent
+// creates can't both pass the limit.
+func (r *APIKeyRepository) Create(ctx context.Context, data *d.APIKey) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		_, err := r.conn(ctx).ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1));", data.AuthUUID)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not create API key.",
+				"Failed to lock API keys of auth.", "auth_uuid", data.AuthUUID, "error", err)
+			return err
+		}
+
+		query := `
+		INSERT INTO api_keys (auth_uuid, name, prefix, key_hash, scopes, expires_at)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE (
+			SELECT COUNT(*) FROM api_keys
+			WHERE auth_uuid = $1
+			  AND revoked_at IS NULL
+			  AND (expires_at IS NULL OR expires_at > NOW())
+		) < $7
+		RETURNING api_key_uuid, created_at;
+		`
+
+		var expiresAt any
+		if !data.ExpiresAt.IsZero() {
+			expiresAt = data.ExpiresAt
+		}
+
+		err = r.conn(ctx).QueryRowContext(ctx,
+			query,
+			data.AuthUUID,         // $1
+			data.Name,             // $2
+			data.Prefix,           // $3
+			data.KeyHash,          // $4
+			pq.Array(data.Scopes), // $5
+			expiresAt,             // $6
+			d.MaxActiveKeys,       // $7
+		).Scan(
+			&data.APIKeyUUID,
+			&data.CreatedAt,
+		)
+
+		if err == sql.ErrNoRows {
+			err = errs.New(errs.Conflict, "(R) API key limit reached.", "API key limit reached.")
+			return err
+		}
+
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not create API key.",
+				"Failed to create API key.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// FetchByAuth lists the keys of authUUID that were not revoked, expired ones
+// included so their owner can see why they stopped working.
+func (r *APIKeyRepository) FetchByAuth(ctx context.Context, authUUID string) ([]d.APIKey, error) {
+	query := `
+	SELECT
+		api_key_uuid,
+		name,
+		prefix,
+		scopes,
+		created_at,
+		COALESCE(expires_at, TIMESTAMP '0001-01-01 00:00:00'),
+		COALESCE(last_used_at, TIMESTAMP '0001-01-01 00:00:00')
+	FROM api_keys
+	WHERE auth_uuid = $1
+	  AND revoked_at IS NULL
+	ORDER BY created_at DESC;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, authUUID)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch API keys.",
+			"Failed to fetch API keys.", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []d.APIKey{}
+
+	for rows.Next() {
+		var key d.APIKey
+
+		err := rows.Scan(
+			&key.APIKeyUUID,
+			&key.Name,
+			&key.Prefix,
+			pq.Array(&key.Scopes),
+			&key.CreatedAt,
+			&key.ExpiresAt,
+			&key.LastUsedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch API keys.",
+				"Failed to scan API key.", "error", err)
+			return nil, err
+		}
+
+		key.AuthUUID = authUUID
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch API keys.",
+			"Failed to iterate API keys.", "error", err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Delete revokes a key of data.AuthUUID.
+func (r *APIKeyRepository) Delete(ctx context.Context, data *d.APIKey) error {
+	query := `
+	UPDATE api_keys
+	SET revoked_at = NOW()
+	WHERE api_key_uuid = $1
+	  AND auth_uuid = $2
+	  AND revoked_at IS NULL
+	RETURNING revoked_at;
+	`
+
+	err := r.db.QueryRowContext(ctx, query, data.APIKeyUUID, data.AuthUUID).Scan(&data.RevokedAt)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) API key not found.", "API key not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not revoke API key.",
+			"Failed to revoke API key.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate resolves data.KeyHash to a usable key, stamping its last use
+// and loading the owner's current role.
+func (r *APIKeyRepository) Authenticate(ctx context.Context, data *d.APIKey) error {
+	query := `
+	UPDATE api_keys k
+	SET last_used_at = NOW()
+	FROM auths a
+	WHERE k.key_hash = $1
+	  AND k.revoked_at IS NULL
+	  AND (k.expires_at IS NULL OR k.expires_at > NOW())
+	  AND a.auth_uuid = k.auth_uuid
+	  AND a.deleted_at IS NULL
+	RETURNING k.api_key_uuid, k.auth_uuid, a.role, k.name, k.prefix, k.scopes, k.last_used_at;
+	`
+
+	err := r.db.QueryRowContext(ctx, query, data.KeyHash).Scan(
+		&data.APIKeyUUID,
+		&data.AuthUUID,
+		&data.Role,
+		&data.Name,
+		&data.Prefix,
+		pq.Array(&data.Scopes),
+		&data.LastUsedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) API key not found.", "API key not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not check API key.",
+			"Failed to authenticate API key.", "error", err)
+		return err
+	}
+
+	return nil
+}
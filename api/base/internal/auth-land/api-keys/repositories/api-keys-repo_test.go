@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	at "aigents-base/internal/auth-land/api-keys/atoms"
+	d "aigents-base/internal/auth-land/api-keys/domain"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openTestDB connects to the database in TEST_DB_URL and migrates it; these
+// tests run real SQL, so they are skipped when it isn't set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := c_db.NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return conn
+}
+
+func TestAuthenticateRefuses(t *testing.T) {
+	conn := openTestDB(t)
+	r := &APIKeyRepository{db: conn}
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		setup     func(t *testing.T, key *d.APIKey)
+		ok        bool
+	}{
+		{name: "usable key", ok: true},
+		{name: "key not yet expired", expiresAt: time.Now().Add(time.Hour), ok: true},
+		{name: "expired key", expiresAt: time.Now().Add(-time.Minute)},
+		{
+			name: "revoked key",
+			setup: func(t *testing.T, key *d.APIKey) {
+				if err := r.Delete(ctx, key); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "deleted owner",
+			setup: func(t *testing.T, key *d.APIKey) {
+				if _, err := conn.Exec("UPDATE auths SET deleted_at = NOW() WHERE auth_uuid = $1;", key.AuthUUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authUUID string
+			err := conn.QueryRow(
+				"INSERT INTO auths (email, password) VALUES ($1, '') RETURNING auth_uuid;",
+				uuid.NewString()+"@api-keys.test",
+			).Scan(&authUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw, prefix, hash, err := at.NewAPIKeyAtom()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key := &d.APIKey{
+				AuthUUID:  authUUID,
+				Name:      tt.name,
+				Prefix:    prefix,
+				KeyHash:   hash,
+				Scopes:    []string{d.ScopeChatRead},
+				ExpiresAt: tt.expiresAt,
+			}
+			if err := r.Create(ctx, key); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if tt.setup != nil {
+				tt.setup(t, key)
+			}
+
+			got := &d.APIKey{KeyHash: at.HashAPIKeyAtom(raw)}
+			err = r.Authenticate(ctx, got)
+
+			if !tt.ok {
+				if !errors.Is(err, errs.NotFound) {
+					t.Errorf("error = %v, want NotFound", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			if got.APIKeyUUID != key.APIKeyUUID || got.Role != "USER" || len(got.Scopes) != 1 {
+				t.Errorf("authenticated key = %+v", got)
+			}
+		})
+	}
+}
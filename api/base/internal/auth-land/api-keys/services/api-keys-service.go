@@ -0,0 +1,114 @@
+package services
+
+import (
+	at "aigents-base/internal/auth-land/api-keys/atoms"
+	d "aigents-base/internal/auth-land/api-keys/domain"
+	akitf "aigents-base/internal/auth-land/api-keys/interfaces"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type APIKeyService struct {
+	r akitf.APIKeyRepositoryITF
+}
+
+func NewAPIKeyService(repo akitf.APIKeyRepositoryITF) akitf.APIKeyServiceITF {
+	return &APIKeyService{r: repo}
+}
+
+// Create issues a key with data.Scopes and sets data.Key, the only time the
+// full key is ever available.
+func (s *APIKeyService) Create(ctx context.Context, data *d.APIKey) error {
+	scopes := []string{}
+	for _, scope := range data.Scopes {
+		if !slices.Contains(d.Scopes, scope) {
+			err := errs.New(
+				errs.Validation,
+				"(S) Unknown scope.",
+				"Unknown API key scope.", "scope", scope)
+			return err
+		}
+
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	data.Scopes = scopes
+
+	key, prefix, hash, err := at.NewAPIKeyAtom()
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(S) Could not create API key.",
+			"Failed to generate API key.", "error", err)
+		return err
+	}
+
+	data.Prefix = prefix
+	data.KeyHash = hash
+
+	err = s.r.Create(ctx, data)
+	if errors.Is(err, errs.Conflict) {
+		err = errs.New(
+			errs.Conflict,
+			fmt.Sprintf("(S) At most %d active API keys are allowed.", d.MaxActiveKeys),
+			"Auth reached the API key limit.", "auth_uuid", data.AuthUUID)
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	data.Key = key
+	return nil
+}
+
+func (s *APIKeyService) FetchByAuth(ctx context.Context, authUUID string) ([]d.APIKey, error) {
+	return s.r.FetchByAuth(ctx, authUUID)
+}
+
+func (s *APIKeyService) Delete(ctx context.Context, data *d.APIKey) error {
+	err := s.r.Delete(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.NotFound,
+			"(S) API key not found.",
+			"API key not found for auth.", "api_key_uuid", data.APIKeyUUID, "auth_uuid", data.AuthUUID)
+	}
+
+	return err
+}
+
+// Authenticate returns the usable key matching key, with its owner's role.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*d.APIKey, error) {
+	if !strings.HasPrefix(key, at.KeyMarker) {
+		err := errs.New(
+			errs.Unauthorized,
+			"(S) Invalid API key.",
+			"Bearer token is not an API key.")
+		return nil, err
+	}
+
+	data := &d.APIKey{KeyHash: at.HashAPIKeyAtom(key)}
+
+	err := s.r.Authenticate(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.Unauthorized,
+			"(S) Invalid API key.",
+			"API key unknown, revoked or expired.")
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
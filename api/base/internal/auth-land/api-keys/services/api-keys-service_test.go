@@ -0,0 +1,138 @@
+package services
+
+import (
+	at "aigents-base/internal/auth-land/api-keys/atoms"
+	d "aigents-base/internal/auth-land/api-keys/domain"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeKey struct {
+	key          d.APIKey
+	revoked      bool
+	ownerDeleted bool
+}
+
+// fakeAPIKeyRepository stores keys by hash and authenticates only the ones
+// the SQL would: not revoked, not expired and owned by a live auth.
+type fakeAPIKeyRepository struct {
+	keys map[string]*fakeKey
+}
+
+func (r *fakeAPIKeyRepository) Create(ctx context.Context, data *d.APIKey) error {
+	data.APIKeyUUID = "key-" + data.Prefix
+	r.keys[data.KeyHash] = &fakeKey{key: *data}
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) FetchByAuth(ctx context.Context, authUUID string) ([]d.APIKey, error) {
+	return nil, nil
+}
+
+func (r *fakeAPIKeyRepository) Delete(ctx context.Context, data *d.APIKey) error {
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) Authenticate(ctx context.Context, data *d.APIKey) error {
+	k, ok := r.keys[data.KeyHash]
+	expired := ok && !k.key.ExpiresAt.IsZero() && !k.key.ExpiresAt.After(time.Now())
+
+	if !ok || k.revoked || k.ownerDeleted || expired {
+		return errs.New(errs.NotFound, "(R) API key not found.", "API key not found.")
+	}
+
+	*data = k.key
+	data.Role = "USER"
+	return nil
+}
+
+func TestAPIKeyRoundTrip(t *testing.T) {
+	repo := &fakeAPIKeyRepository{keys: map[string]*fakeKey{}}
+	sv := NewAPIKeyService(repo)
+	ctx := context.Background()
+
+	created := &d.APIKey{
+		AuthUUID: "auth-1",
+		Name:     "ci",
+		Scopes:   []string{d.ScopeChatRead, d.ScopeChatRead, d.ScopeAgentsRead},
+	}
+	if err := sv.Create(ctx, created); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if !slices.Equal(created.Scopes, []string{d.ScopeChatRead, d.ScopeAgentsRead}) {
+		t.Errorf("scopes = %v, want duplicates dropped", created.Scopes)
+	}
+
+	if created.Key == "" || created.KeyHash != at.HashAPIKeyAtom(created.Key) {
+		t.Fatalf("created key %q does not match its hash", created.Key)
+	}
+
+	got, err := sv.Authenticate(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if got.APIKeyUUID != created.APIKeyUUID || got.AuthUUID != "auth-1" || !slices.Equal(got.Scopes, created.Scopes) {
+		t.Errorf("authenticated key = %+v, want the created one", got)
+	}
+}
+
+func TestAPIKeyCreateRejectsUnknownScope(t *testing.T) {
+	repo := &fakeAPIKeyRepository{keys: map[string]*fakeKey{}}
+	sv := NewAPIKeyService(repo)
+
+	err := sv.Create(context.Background(), &d.APIKey{AuthUUID: "auth-1", Scopes: []string{"admin:*"}})
+	if !errors.Is(err, errs.Validation) {
+		t.Fatalf("error = %v, want Validation", err)
+	}
+
+	if len(repo.keys) != 0 {
+		t.Errorf("key stored despite the unknown scope")
+	}
+}
+
+func TestAPIKeyAuthenticateRefuses(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(k *fakeKey)
+		key   func(key string) string
+	}{
+		{name: "expired", setup: func(k *fakeKey) { k.key.ExpiresAt = time.Now().Add(-time.Minute) }},
+		{name: "revoked", setup: func(k *fakeKey) { k.revoked = true }},
+		{name: "deleted owner", setup: func(k *fakeKey) { k.ownerDeleted = true }},
+		{name: "altered secret", key: func(key string) string { return key[:len(key)-8] + "00000000" }},
+		{name: "JWT instead of a key", key: func(key string) string { return "eyJhbGciOiJFZERTQSJ9.e30.sig" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAPIKeyRepository{keys: map[string]*fakeKey{}}
+			sv := NewAPIKeyService(repo)
+			ctx := context.Background()
+
+			created := &d.APIKey{AuthUUID: "auth-1", Scopes: []string{d.ScopeChatRead}}
+			if err := sv.Create(ctx, created); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if tt.setup != nil {
+				tt.setup(repo.keys[created.KeyHash])
+			}
+
+			key := created.Key
+			if tt.key != nil {
+				key = tt.key(key)
+			}
+
+			if _, err := sv.Authenticate(ctx, key); !errors.Is(err, errs.Unauthorized) {
+				t.Errorf("error = %v, want Unauthorized", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"aigents-base/internal/auth-land/auth-signature/keyset"
+
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	ks *keyset.KeySet
+}
+
+func NewJWKSHandler(ks *keyset.KeySet) *JWKSHandler {
+	return &JWKSHandler{ks: ks}
+}
+
+// Get publishes the token verification keys as a plain JWKS document, the
+// format other services expect, rather than the usual response envelope.
+func (h *JWKSHandler) Get(gctx *gin.Context) {
+	gctx.Header("Cache-Control", "public, max-age=300")
+	gctx.JSON(http.StatusOK, h.ks.JWKS())
+}
@@ -0,0 +1,212 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted for RS256.
+const minRSABits = 2048
+
+// Key is one signing key, identified in token headers by its kid.
+type Key struct {
+	KID     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+func (k *Key) Alg() string {
+	return k.method.Alg()
+}
+
+// JWK is the public half of a Key as published in the JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet signs with its active key and verifies with any of its keys, so a
+// new key can take over signing while tokens issued under the previous one
+// stay valid until they expire.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// Load reads every *.pem private key in dir, using the file name without
+// extension as kid. Ed25519 keys sign with EdDSA and RSA keys with RS256.
+// activeKID picks the signing key and may be empty when dir holds one key.
+func Load(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.pem signing key found in %q", dir)
+	}
+
+	ks := &KeySet{keys: make(map[string]*Key)}
+
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+
+		key.KID = kid
+		ks.keys[kid] = key
+	}
+
+	if activeKID == "" {
+		if len(ks.keys) > 1 {
+			return nil, fmt.Errorf("%d keys in %q, the active kid must be set", len(ks.keys), dir)
+		}
+
+		for kid := range ks.keys {
+			activeKID = kid
+		}
+	}
+
+	active, ok := ks.keys[activeKID]
+	if !ok {
+		return nil, fmt.Errorf("active kid %q not found in %q", activeKID, dir)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// FromEnv loads the keys from JWT_KEYS_DIR with JWT_ACTIVE_KID as signing
+// key. Running without a usable signing key is refused.
+func FromEnv() *KeySet {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		log.Fatalf("JWT_KEYS_DIR must point to a directory of PEM signing keys")
+	}
+
+	ks, err := Load(dir, os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		log.Fatalf("Error loading JWT signing keys: %v", err)
+	}
+
+	slog.Info("Loaded JWT signing keys", "active_kid", ks.active.KID, "alg", ks.active.Alg(), "keys", len(ks.keys))
+	return ks
+}
+
+func loadKey(path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("not a PEM file")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		return &Key{method: jwt.SigningMethodEdDSA, private: k}, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key of %d bits, at least %d required", k.N.BitLen(), minRSABits)
+		}
+		return &Key{method: jwt.SigningMethodRS256, private: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use Ed25519 or RSA", parsed)
+	}
+}
+
+// Sign signs claims with the active key, naming it in the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.KID
+
+	return token.SignedString(ks.active.private)
+}
+
+// Parse verifies tokenStr against the key its kid names, accepting only the
+// algorithm that key signs with.
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+
+		if t.Method.Alg() != key.Alg() {
+			return nil, fmt.Errorf("kid %q does not sign with %s", kid, t.Method.Alg())
+		}
+
+		return key.private.Public(), nil
+	}, append(opts, jwt.WithValidMethods([]string{"EdDSA", "RS256"}))...)
+}
+
+// JWKS returns the public keys of the set, ordered by kid.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.KID, Use: "sig", Alg: key.Alg()}
+
+		switch pub := key.private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
@@ -0,0 +1,259 @@
+package keyset
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeEd25519(t *testing.T, dir, kid string) ed25519.PrivateKey {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+	return priv
+}
+
+func writeRSA(t *testing.T, dir, kid string, bits int) *rsa.PrivateKey {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePEM(t, dir, kid, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))
+	return priv
+}
+
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "auth-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, dir string)
+		active  string
+		wantKID string
+		wantAlg string
+		wantErr string
+	}{
+		{
+			name:    "single Ed25519 key is active",
+			setup:   func(t *testing.T, dir string) { writeEd25519(t, dir, "k1") },
+			wantKID: "k1",
+			wantAlg: "EdDSA",
+		},
+		{
+			name:    "RSA key signs with RS256",
+			setup:   func(t *testing.T, dir string) { writeRSA(t, dir, "r1", 2048) },
+			wantKID: "r1",
+			wantAlg: "RS256",
+		},
+		{
+			name: "active kid picks among several",
+			setup: func(t *testing.T, dir string) {
+				writeEd25519(t, dir, "old")
+				writeEd25519(t, dir, "new")
+			},
+			active:  "new",
+			wantKID: "new",
+			wantAlg: "EdDSA",
+		},
+		{
+			name:    "empty dir",
+			setup:   func(t *testing.T, dir string) {},
+			wantErr: "no *.pem signing key",
+		},
+		{
+			name: "several keys without active kid",
+			setup: func(t *testing.T, dir string) {
+				writeEd25519(t, dir, "old")
+				writeEd25519(t, dir, "new")
+			},
+			wantErr: "the active kid must be set",
+		},
+		{
+			name:    "unknown active kid",
+			setup:   func(t *testing.T, dir string) { writeEd25519(t, dir, "k1") },
+			active:  "k2",
+			wantErr: "active kid \"k2\" not found",
+		},
+		{
+			name:    "short RSA key",
+			setup:   func(t *testing.T, dir string) { writeRSA(t, dir, "weak", 1024) },
+			wantErr: "at least 2048 required",
+		},
+		{
+			name: "not PEM",
+			setup: func(t *testing.T, dir string) {
+				os.WriteFile(filepath.Join(dir, "junk.pem"), []byte("junk"), 0600)
+			},
+			wantErr: "not a PEM file",
+		},
+		{
+			name: "public key instead of private",
+			setup: func(t *testing.T, dir string) {
+				pub, _, _ := ed25519.GenerateKey(rand.Reader)
+				der, _ := x509.MarshalPKIXPublicKey(pub)
+				writePEM(t, dir, "pub", "PUBLIC KEY", der)
+			},
+			wantErr: "unsupported PEM block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+
+			ks, err := Load(dir, tt.active)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			if ks.active.KID != tt.wantKID || ks.active.Alg() != tt.wantAlg {
+				t.Errorf("active = %s/%s, want %s/%s", ks.active.KID, ks.active.Alg(), tt.wantKID, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestRotationKeepsOldTokensValid(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519(t, dir, "2025-01")
+
+	before, err := Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	oldToken, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	writeRSA(t, dir, "2025-06", 2048)
+
+	after, err := Load(dir, "2025-06")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	newToken, err := after.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := after.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+			t.Errorf("%s token rejected after rotation: %v", name, err)
+		}
+	}
+
+	// a server still on the old set can't know the new key
+	if _, err := before.Parse(newToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Errorf("new token accepted by the old key set")
+	}
+
+	jwks := after.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "2025-01" || jwks.Keys[1].Kid != "2025-06" {
+		t.Fatalf("JWKS = %+v, want both keys ordered by kid", jwks.Keys)
+	}
+
+	if k := jwks.Keys[0]; k.Kty != "OKP" || k.Crv != "Ed25519" || k.X == "" || k.Alg != "EdDSA" {
+		t.Errorf("Ed25519 JWK = %+v", k)
+	}
+
+	if k := jwks.Keys[1]; k.Kty != "RSA" || k.N == "" || k.E != "AQAB" || k.Alg != "RS256" {
+		t.Errorf("RSA JWK = %+v", k)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	dir := t.TempDir()
+	edKey := writeEd25519(t, dir, "ed")
+	rsaKey := writeRSA(t, dir, "rsa", 2048)
+
+	ks, err := Load(dir, "ed")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		token := jwt.NewWithClaims(method, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	valid, err := ks.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	parts := strings.Split(valid, ".")
+
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := map[string]string{
+		"unknown kid":              sign(jwt.SigningMethodEdDSA, "gone", edKey),
+		"missing kid":              sign(jwt.SigningMethodEdDSA, "", edKey),
+		"foreign key under a kid":  sign(jwt.SigningMethodEdDSA, "ed", otherKey),
+		"RS256 under an EdDSA kid": sign(jwt.SigningMethodRS256, "ed", rsaKey),
+		"HS256 keyed with the public key": sign(jwt.SigningMethodHS256, "ed",
+			[]byte(edKey.Public().(ed25519.PublicKey))),
+		"alg none":         sign(jwt.SigningMethodNone, "ed", jwt.UnsafeAllowNoneSignatureType),
+		"tampered payload": parts[0] + "." + strings.ToUpper(parts[1][:4]) + parts[1][4:] + "." + parts[2],
+	}
+
+	for name, token := range tests {
+		if _, err := ks.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
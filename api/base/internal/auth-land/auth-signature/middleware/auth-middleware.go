@@ -2,10 +2,14 @@ package middleware
 
 import (
 	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
+	akitf "aigents-base/internal/auth-land/api-keys/interfaces"
+	"aigents-base/internal/auth-land/auth-signature/keyset"
 
-	"os"
-	"fmt"
-	"net/http"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 	"github.com/google/uuid"
 	"github.com/gin-gonic/gin"
@@ -15,57 +19,152 @@ import (
 type Claims struct {
 	UUID string `json:"auth_uuid"`
 	Role     string `json:"role"`
+	SessionUUID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 var (
-	JWTSecret = []byte(os.Getenv("JWT_SECRET"))
-	RefreshSecret = []byte(os.Getenv("REFRESH_SECRET"))
 	AccessTokenTTL  = c_at.ParseEnvMinutesAtom("ACCESS_TOKEN_TTL", 15)
 	RefreshTokenTTL = c_at.ParseEnvMinutesAtom("REFRESH_TOKEN_TTL", 10080)
+	MFAChallengeTTL = c_at.ParseEnvMinutesAtom("MFA_CHALLENGE_TTL", 5)
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// Every token carries one of these audiences so that, signed by the same
+// keys, an access token is never accepted as a refresh token, an MFA
+// challenge as an access token, and so on.
+const (
+	accessAudience       = "access"
+	refreshAudience      = "refresh"
+	mfaChallengeAudience = "mfa_challenge"
+)
+
+// keys signs and verifies every token; set once at startup by UseKeySet.
+var keys *keyset.KeySet
+
+func UseKeySet(ks *keyset.KeySet) {
+	keys = ks
+}
+
+// SignToken signs claims with the active key of the key set.
+func SignToken(claims jwt.Claims) (string, error) {
+	if keys == nil {
+		return "", errors.New("signing keys not loaded")
+	}
+
+	return keys.Sign(claims)
+}
+
+// ParseToken verifies tokenStr into claims and checks it was issued for
+// audience.
+func ParseToken(tokenStr string, claims jwt.Claims, audience string) bool {
+	if keys == nil {
+		return false
+	}
+
+	token, err := keys.Parse(tokenStr, claims, jwt.WithAudience(audience))
+	return err == nil && token.Valid
+}
+
+func ParseAccessToken(tokenStr string) (*Claims, bool) {
+	claims := &Claims{}
+	return claims, ParseToken(tokenStr, claims, accessAudience)
+}
+
+func ParseRefreshToken(tokenStr string) (*Claims, bool) {
+	claims := &Claims{}
+	return claims, ParseToken(tokenStr, claims, refreshAudience)
+}
+
+// AuthMiddleware accepts an access token only while the session it was
+// issued for is still active, so revoking a session cuts its access tokens
+// off without waiting for them to expire. A personal API key sent as
+// "Authorization: Bearer" is accepted instead of the cookie; the request is
+// then limited to the key's scopes (see RequireScope and SessionOnly).
+func AuthMiddleware(sessions ssitf.SessionServiceITF, apiKeys akitf.APIKeyServiceITF) gin.HandlerFunc {
 	return func(gctx *gin.Context) {
+		if header := gctx.GetHeader("Authorization"); header != "" {
+			bearer, found := strings.CutPrefix(header, "Bearer ")
+			if !found {
+				err := errs.New(
+					errs.Unauthorized,
+					"(M) Invalid authorization header.",
+					"Authorization header is not a bearer token.")
+				c_at.AbortErrAtom(gctx, err)
+				return
+			}
+
+			key, err := apiKeys.Authenticate(gctx.Request.Context(), strings.TrimSpace(bearer))
+			if err != nil {
+				c_at.AbortErrAtom(gctx, err)
+				return
+			}
+
+			gctx.Set("auth_uuid", key.AuthUUID)
+			gctx.Set("role", key.Role)
+			gctx.Set("api_key_uuid", key.APIKeyUUID)
+			gctx.Set("api_key_scopes", key.Scopes)
+
+			gctx.Next()
+			return
+		}
+
 		tokenStr, err := gctx.Cookie("access_token")
 		if err != nil {
-			err := c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusUnauthorized,
+			err := errs.New(
+				errs.Unauthorized,
 				"(M) Missing token.",
 				"Missing token.")
-			c_at.FeedErrLogToFile(err)
+			c_at.AbortErrAtom(gctx, err)
 			return
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (any, error) {
-			return JWTSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			err := c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusUnauthorized,
+		claims, valid := ParseAccessToken(tokenStr)
+		if !valid {
+			err := errs.New(
+				errs.Unauthorized,
 				"(M) Invalid token.",
 				"Invalid token.")
-			c_at.FeedErrLogToFile(err)
+			c_at.AbortErrAtom(gctx, err)
 			return
 		}
 
 
 		if _, err := uuid.Parse(claims.UUID); err != nil {
-			err := c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusUnauthorized,
+			err := errs.New(
+				errs.Unauthorized,
 				"(M) Invalid UUID in token.",
 				"Invalid UUID in token.")
-			c_at.FeedErrLogToFile(err)
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		if _, err := uuid.Parse(claims.SessionUUID); err != nil {
+			err := errs.New(
+				errs.Unauthorized,
+				"(M) Invalid session in token.",
+				"Invalid session UUID in token.")
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		active, err := sessions.IsActive(gctx.Request.Context(), claims.SessionUUID)
+		if err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		if !active {
+			err := errs.New(
+				errs.Unauthorized,
+				"(M) Session revoked.",
+				"Session is no longer active.", "session_uuid", claims.SessionUUID)
+			c_at.AbortErrAtom(gctx, err)
 			return
 		}
 
 		gctx.Set("auth_uuid", claims.UUID)
 		gctx.Set("role", claims.Role)
+		gctx.Set("session_uuid", claims.SessionUUID)
 
 		gctx.Next()
 	}
@@ -75,23 +174,56 @@ func AuthorizeRole(allowedRoles map[string]bool) gin.HandlerFunc {
 	return func(gctx *gin.Context) {
 		role, exists := gctx.Get("role")
 		if !exists {
-			err := c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusForbidden,
+			err := errs.New(
+				errs.Forbidden,
 				"(M) Insufficient role.",
 				"Insufficient role.")
-			c_at.FeedErrLogToFile(err)
+			c_at.AbortErrAtom(gctx, err)
 			return
 		}
 
 		roleStr, ok := role.(string)
 		if !ok || !allowedRoles[roleStr] {
-			err := c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusForbidden,
+			err := errs.New(
+				errs.Forbidden,
 				"(M) Insufficient role.",
 				"Insufficient role.")
-			c_at.FeedErrLogToFile(err)
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		gctx.Next()
+	}
+}
+
+// RequireScope lets API key requests through only when the key was granted
+// scope. Cookie sessions are not scoped and always pass.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		scopes, isKey := GetAPIKeyScopes(gctx)
+		if isKey && !slices.Contains(scopes, scope) {
+			err := errs.New(
+				errs.Forbidden,
+				"(M) API key lacks the required scope.",
+				"API key is missing scope.", "scope", scope)
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		gctx.Next()
+	}
+}
+
+// SessionOnly keeps API keys away from account management: keys cannot
+// mint other keys, change credentials or reach admin routes.
+func SessionOnly() gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		if _, isKey := GetAPIKeyScopes(gctx); isKey {
+			err := errs.New(
+				errs.Forbidden,
+				"(M) Not available to API keys.",
+				"API key used on a session only route.")
+			c_at.AbortErrAtom(gctx, err)
 			return
 		}
 
@@ -102,34 +234,94 @@ func AuthorizeRole(allowedRoles map[string]bool) gin.HandlerFunc {
 func GenerateJWT(gctx *gin.Context, c *Claims, useRefresh bool) (string, error) {
 	now := time.Now()
 
-	var ttl time.Duration
-	var secret []byte
+	ttl := AccessTokenTTL
+	audience := accessAudience
 
 	if useRefresh {
 		ttl = RefreshTokenTTL
-		secret = RefreshSecret
-	} else {
-		ttl = AccessTokenTTL
-		secret = JWTSecret
+		audience = refreshAudience
 	}
 
+	c.Audience = jwt.ClaimStrings{audience}
 	c.IssuedAt = jwt.NewNumericDate(now)
 	c.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
-	signedStr, err := token.SignedString(secret)
+	signedStr, err := SignToken(c)
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(M) Could not generate token.",
-			fmt.Sprintf("Could not generate token: %s", err.Error()))
+			"Could not generate token.", "error", err)
 		return "", err
 	}
 
 	return signedStr, nil
 }
 
+// GenerateMFAChallenge signs the short lived token a password login hands
+// out instead of cookies when the account has two-factor enabled.
+func GenerateMFAChallenge(gctx *gin.Context, authUUID string) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   authUUID,
+		Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+	}
+
+	signedStr, err := SignToken(claims)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(M) Could not generate token.",
+			"Could not generate MFA challenge.", "error", err)
+		return "", err
+	}
+
+	return signedStr, nil
+}
+
+// ParseMFAChallenge returns the auth UUID a valid challenge token was issued
+// for.
+func ParseMFAChallenge(tokenStr string) (string, bool) {
+	claims := &jwt.RegisteredClaims{}
+	if !ParseToken(tokenStr, claims, mfaChallengeAudience) {
+		return "", false
+	}
+
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return "", false
+	}
+
+	return claims.Subject, true
+}
+
+// SetAuthCookies signs an access token and a refresh token carrying
+// refreshJTI for claims and sets both cookies.
+func SetAuthCookies(gctx *gin.Context, claims *Claims, refreshJTI string) error {
+	accessToken, err := GenerateJWT(gctx, claims, false)
+	if err != nil {
+		return err
+	}
+
+	refreshClaims := *claims
+	refreshClaims.ID = refreshJTI
+	refreshToken, err := GenerateJWT(gctx, &refreshClaims, true)
+	if err != nil {
+		return err
+	}
+
+	gctx.SetCookie("access_token", accessToken, int(AccessTokenTTL.Seconds()), "/", "", false, true)
+	gctx.SetCookie("refresh_token", refreshToken, int(RefreshTokenTTL.Seconds()), "/", "", false, true)
+
+	return nil
+}
+
+func ClearAuthCookies(gctx *gin.Context) {
+	gctx.SetCookie("access_token", "", -1, "/", "", false, true)
+	gctx.SetCookie("refresh_token", "", -1, "/", "", false, true)
+}
+
 func GetAuthUUID(gctx *gin.Context) (string, bool) {
 	val, exists := gctx.Get("auth_uuid")
 	if !exists {
@@ -143,3 +335,33 @@ func GetAuthUUID(gctx *gin.Context) (string, bool) {
 
 	return uuidStr, true
 }
+
+func GetSessionUUID(gctx *gin.Context) (string, bool) {
+	val, exists := gctx.Get("session_uuid")
+	if !exists {
+		return "", false
+	}
+
+	sessionStr, ok := val.(string)
+	if !ok {
+		return "", false
+	}
+
+	return sessionStr, true
+}
+
+// GetAPIKeyScopes reports the scopes of the API key the request was
+// authenticated with; ok is false for cookie sessions.
+func GetAPIKeyScopes(gctx *gin.Context) ([]string, bool) {
+	val, exists := gctx.Get("api_key_scopes")
+	if !exists {
+		return nil, false
+	}
+
+	scopes, ok := val.([]string)
+	if !ok {
+		return nil, false
+	}
+
+	return scopes, true
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	akd "aigents-base/internal/auth-land/api-keys/domain"
+	"aigents-base/internal/auth-land/auth-signature/keyset"
+	sd "aigents-base/internal/auth-land/sessions/domain"
+	errs "aigents-base/internal/common/errs"
+	"aigents-base/internal/common/logger"
+
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeAPIKeyService knows a fixed set of keys and their scopes.
+type fakeAPIKeyService struct {
+	scopes map[string][]string
+}
+
+func (s *fakeAPIKeyService) Create(ctx context.Context, data *akd.APIKey) error {
+	return nil
+}
+
+func (s *fakeAPIKeyService) FetchByAuth(ctx context.Context, authUUID string) ([]akd.APIKey, error) {
+	return nil, nil
+}
+
+func (s *fakeAPIKeyService) Delete(ctx context.Context, data *akd.APIKey) error {
+	return nil
+}
+
+func (s *fakeAPIKeyService) Authenticate(ctx context.Context, key string) (*akd.APIKey, error) {
+	scopes, ok := s.scopes[key]
+	if !ok {
+		return nil, errs.New(errs.Unauthorized, "(S) Invalid API key.", "API key unknown, revoked or expired.")
+	}
+
+	return &akd.APIKey{APIKeyUUID: "key-1", AuthUUID: "auth-1", Role: "USER", Scopes: scopes}, nil
+}
+
+// fakeSessionService only answers IsActive, for cookie requests.
+type fakeSessionService struct{}
+
+func (s *fakeSessionService) Create(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) GetByID(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) Fetch(ctx context.Context, limit, offset uint64) ([]sd.Session, error) {
+	return nil, nil
+}
+
+func (s *fakeSessionService) Update(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) Delete(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) Rotate(ctx context.Context, data *sd.Session, presentedJTI string) error {
+	return nil
+}
+
+func (s *fakeSessionService) Revoke(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) FetchByAuth(ctx context.Context, authUUID, currentSessionUUID string) ([]sd.Session, error) {
+	return nil, nil
+}
+
+func (s *fakeSessionService) RevokeOthers(ctx context.Context, data *sd.Session) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeSessionService) IsActive(ctx context.Context, sessionUUID string) (bool, error) {
+	return true, nil
+}
+
+func useTestKeySet(t *testing.T) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "test.pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := keyset.Load(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	UseKeySet(ks)
+	t.Cleanup(func() { UseKeySet(nil) })
+}
+
+// testRouter mirrors how main wires the API: scoped chat routes and a
+// session only group behind AuthMiddleware.
+func testRouter() *gin.Engine {
+	ok := func(gctx *gin.Context) { gctx.Status(http.StatusOK) }
+
+	r := gin.New()
+	r.Use(logger.ErrorHandler())
+
+	api := r.Group("/api/v1", AuthMiddleware(&fakeSessionService{}, &fakeAPIKeyService{scopes: map[string][]string{
+		"aig_read":  {akd.ScopeChatRead},
+		"aig_write": {akd.ScopeChatRead, akd.ScopeChatWrite},
+		"aig_all":   akd.Scopes,
+	}}))
+
+	chat := api.Group("/chat")
+	chat.GET("", RequireScope(akd.ScopeChatRead), ok)
+	chat.POST("/send-new-message", RequireScope(akd.ScopeChatWrite), ok)
+
+	api.Group("/auth/api-keys", SessionOnly()).POST("", ok)
+
+	return r
+}
+
+func TestScopesAndSessionOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestKeySet(t)
+
+	access, err := GenerateJWT(nil, &Claims{UUID: uuid.NewString(), Role: "USER", SessionUUID: uuid.NewString()}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		bearer string
+		cookie string
+		want   int
+	}{
+		{"read key reads chats", http.MethodGet, "/api/v1/chat", "aig_read", "", http.StatusOK},
+		{"read key can't send", http.MethodPost, "/api/v1/chat/send-new-message", "aig_read", "", http.StatusForbidden},
+		{"write key sends", http.MethodPost, "/api/v1/chat/send-new-message", "aig_write", "", http.StatusOK},
+		{"key never reaches session only routes", http.MethodPost, "/api/v1/auth/api-keys", "aig_all", "", http.StatusForbidden},
+		{"unknown key", http.MethodGet, "/api/v1/chat", "aig_gone", "", http.StatusUnauthorized},
+		{"cookie session is not scoped", http.MethodPost, "/api/v1/chat/send-new-message", "", access, http.StatusOK},
+		{"cookie session reaches session only routes", http.MethodPost, "/api/v1/auth/api-keys", "", access, http.StatusOK},
+		{"no credentials", http.MethodGet, "/api/v1/chat", "", "", http.StatusUnauthorized},
+	}
+
+	r := testRouter()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package atoms
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,3 +21,45 @@ func HashPassAtom(pass string) (string, error) {
 func ComparePassAtom(hashedPass, tryPass string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPass), []byte(tryPass)) == nil
 }
+
+// dummyHash is compared against when a login names an unknown email, so that
+// the request costs the same bcrypt work as a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("aigents-dummy-password"), bcrypt.DefaultCost)
+
+func DummyComparePassAtom(tryPass string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(tryPass))
+}
+
+// BackoffDelayAtom is how long to wait after failures consecutive failed
+// attempts: base doubled per extra failure, capped at max.
+func BackoffDelayAtom(failures int, base, max time.Duration) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	return delay
+}
+
+// NewTokenAtom returns a random URL-safe token and the hash to store for it.
+func NewTokenAtom() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashTokenAtom(token), nil
+}
+
+func HashTokenAtom(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -5,11 +5,85 @@ import (
 )
 
 type Auth struct {
-	UUID string
-	Email string
-	Password string
-	Role string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt time.Time
+	UUID string `json:"auth_uuid"`
+	Email string `json:"email"`
+	PendingEmail string `json:"pending_email,omitempty"`
+	Password string `json:"-"`
+	Role string `json:"role"`
+	EmailVerifiedAt time.Time `json:"email_verified_at"`
+	MFAEnabled bool `json:"mfa_enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+const (
+	TokenVerifyEmail   = "VERIFY_EMAIL"
+	TokenResetPassword = "RESET_PASSWORD"
+)
+
+// AuthToken is a single-use token mailed to Email. Only its sha256 is stored.
+type AuthToken struct {
+	TokenHash string
+	AuthUUID  string
+	Purpose   string
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+const (
+	ThrottleScopeIP        = "IP"
+	ThrottleScopeEmail     = "EMAIL"
+	ThrottleScopeMailIP    = "MAIL_IP"
+	ThrottleScopeMailEmail = "MAIL_EMAIL"
+)
+
+// LoginThrottle tracks failed logins for one IP or email; the MAIL_ scopes
+// count the verification and reset emails asked for instead. Durations are
+// computed by the database so they don't depend on the server clock.
+type LoginThrottle struct {
+	Scope            string
+	Key              string
+	Failures         int
+	SinceLastFailure time.Duration
+	LockedFor        time.Duration
+}
+
+// LoginPolicy configures login throttling: each failure doubles the wait
+// before the next attempt, starting at BaseDelay and capped at MaxDelay, and
+// reaching the failure limit of a scope locks it for Lockout.
+type LoginPolicy struct {
+	MaxEmailFailures int
+	MaxIPFailures    int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Lockout          time.Duration
+}
+
+// MailPolicy caps the verification and reset emails one IP or address can
+// ask for: once a limit is reached the scope is refused until Window has
+// passed.
+type MailPolicy struct {
+	MaxPerEmail int
+	MaxPerIP    int
+	Window      time.Duration
+}
+
+const AuditLoginLockout = "LOGIN_LOCKOUT"
+
+type AuditEntry struct {
+	Event     string
+	AuthUUID  string
+	Email     string
+	ClientIP  string
+	UserAgent string
+	Detail    string
+}
+
+// LoginChallenge is the answer to a password login on an account with
+// two-factor enabled: the challenge token must be sent back with a code.
+type LoginChallenge struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
 }
@@ -4,19 +4,22 @@ import (
 	d "aigents-base/internal/auth-land/auth/domain"
 	auitf "aigents-base/internal/auth-land/auth/interfaces"
 	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
 	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	sd "aigents-base/internal/auth-land/sessions/domain"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
 
 	"net/http"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthHandler struct {
-	s auitf.AuthServiceITF
+	s  auitf.AuthServiceITF
+	ss ssitf.SessionServiceITF
 }
 
-func NewAuthHandler(sv auitf.AuthServiceITF) *AuthHandler {
-	return &AuthHandler{s: sv}
+func NewAuthHandler(sv auitf.AuthServiceITF, sessionSv ssitf.SessionServiceITF) *AuthHandler {
+	return &AuthHandler{s: sv, ss: sessionSv}
 }
 
 func (h *AuthHandler) Create(gctx *gin.Context) {
@@ -26,22 +29,21 @@ func (h *AuthHandler) Create(gctx *gin.Context) {
 	}
 
 	if err := gctx.ShouldBindJSON(&req); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	err := h.s.Create(gctx, &d.Auth{Email: req.Email, Password: req.Password})
+	err := h.s.Create(gctx.Request.Context(), &d.Auth{Email: req.Email, Password: req.Password})
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	c_at.RespAtom[*struct{}](gctx, http.StatusCreated, "(*) Authentication created.", nil)
+	c_at.RespAtom[*struct{}](gctx, http.StatusCreated, "(*) Authentication created. Check your email to verify it.", nil)
 }
 
 func (h *AuthHandler) Login(gctx *gin.Context) {
@@ -51,37 +53,48 @@ func (h *AuthHandler) Login(gctx *gin.Context) {
 	}
 
 	if err := gctx.ShouldBindJSON(&req); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
 	auth := &d.Auth{Email: req.Email, Password: req.Password}
-	err := h.s.Comparate(gctx, auth)
+	err := h.s.Comparate(gctx.Request.Context(), auth, gctx.ClientIP(), gctx.Request.UserAgent())
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	claims := &m.Claims{ UUID: auth.UUID, Role: auth.Role }
-	accessToken, err := m.GenerateJWT(gctx, claims, false)
-	if err != nil {
-		c_at.FeedErrLogToFile(err)
+	if auth.MFAEnabled {
+		challenge, err := m.GenerateMFAChallenge(gctx, auth.UUID)
+		if err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		c_at.RespAtom[d.LoginChallenge](gctx, http.StatusOK, "(*) Two-factor code required.",
+			d.LoginChallenge{MFARequired: true, ChallengeToken: challenge})
 		return
 	}
 
-	refreshToken, err := m.GenerateJWT(gctx, claims, true)
-	if err != nil {
-		c_at.FeedErrLogToFile(err)
+	session := &sd.Session{
+		AuthUUID:  auth.UUID,
+		ClientIP:  gctx.ClientIP(),
+		UserAgent: gctx.Request.UserAgent(),
+	}
+	if err := h.ss.Create(gctx.Request.Context(), session); err != nil {
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	gctx.SetCookie("access_token", accessToken, int(m.AccessTokenTTL.Seconds()), "/", "", false, true)
-	gctx.SetCookie("refresh_token", refreshToken, int(m.RefreshTokenTTL.Seconds()), "/", "", false, true)
+	claims := &m.Claims{ UUID: auth.UUID, Role: auth.Role, SessionUUID: session.SessionUUID }
+	if err := m.SetAuthCookies(gctx, claims, session.RefreshJTI); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
 
 	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Login successful.", nil)
 }
@@ -89,67 +102,88 @@ func (h *AuthHandler) Login(gctx *gin.Context) {
 func (h *AuthHandler) Refresh(gctx *gin.Context) {
 	refreshToken, err := gctx.Cookie("refresh_token")
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+		err = errs.New(
+			errs.Unauthorized,
 			"(H) Missing refresh token.",
-			"Missing refresh token")
-		c_at.FeedErrLogToFile(err)
+			"Missing refresh token.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	claims := &m.Claims{}
-	token, err := jwt.ParseWithClaims(refreshToken, claims, func(t *jwt.Token) (any, error) {
-		return m.RefreshSecret, nil
-	})
+	claims, valid := m.ParseRefreshToken(refreshToken)
+	if !valid {
+		err = errs.New(
+			errs.Unauthorized,
+			"(H) Invalid refresh token.",
+			"Invalid refresh token.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
 
-	if err != nil || !token.Valid {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+	if claims.SessionUUID == "" || claims.ID == "" {
+		m.ClearAuthCookies(gctx)
+		err = errs.New(
+			errs.Unauthorized,
 			"(H) Invalid refresh token.",
-			"Invalid refresh token")
-		c_at.FeedErrLogToFile(err)
+			"Refresh token without session or jti.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	newAccessToken, err := m.GenerateJWT(gctx, claims, false)
-	if err != nil {
-		c_at.FeedErrLogToFile(err)
+	session := &sd.Session{
+		SessionUUID: claims.SessionUUID,
+		ClientIP:    gctx.ClientIP(),
+		UserAgent:   gctx.Request.UserAgent(),
+	}
+	if err := h.ss.Rotate(gctx.Request.Context(), session, claims.ID); err != nil {
+		m.ClearAuthCookies(gctx)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	gctx.SetCookie("access_token", newAccessToken, int(m.AccessTokenTTL.Seconds()), "/", "", false, true)
+	newClaims := &m.Claims{ UUID: session.AuthUUID, Role: session.Role, SessionUUID: session.SessionUUID }
+	if err := m.SetAuthCookies(gctx, newClaims, session.RefreshJTI); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
 
-	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Access token refreshed.", nil)
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Tokens refreshed.", nil)
 }
 
 
 func (h *AuthHandler) Check(gctx *gin.Context) {
 	accessToken, err := gctx.Cookie("access_token")
 	if err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+		err = errs.New(
+			errs.Unauthorized,
 			"(H) Missing access token.",
-			"Missing access token",
-		)
-		c_at.FeedErrLogToFile(err)
+			"Missing access token.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	claims := &m.Claims{}
-	token, err := jwt.ParseWithClaims(accessToken, claims, func(t *jwt.Token) (interface{}, error) {
-		return m.JWTSecret, nil
-	})
-	if err != nil || !token.Valid {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+	claims, valid := m.ParseAccessToken(accessToken)
+	if !valid {
+		err = errs.New(
+			errs.Unauthorized,
 			"(H) Invalid access token.",
-			"Invalid access token",
-		)
-		c_at.FeedErrLogToFile(err)
+			"Invalid access token.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	active, err := h.ss.IsActive(gctx.Request.Context(), claims.SessionUUID)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if !active {
+		err = errs.New(
+			errs.Unauthorized,
+			"(H) Session revoked.",
+			"Access token of a revoked session.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -161,9 +195,19 @@ func (h *AuthHandler) Check(gctx *gin.Context) {
 	)
 }
 
+// Logout revokes the session behind the refresh cookie, so the token family
+// stops working even if a copy of it survives elsewhere. When the refresh
+// cookie is expired or unreadable, the access cookie names the session.
 func (h *AuthHandler) Logout(gctx *gin.Context) {
-	gctx.SetCookie("access_token", "", -1, "/", "", false, true)
-	gctx.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	if claims := logoutClaims(gctx); claims != nil {
+		session := &sd.Session{SessionUUID: claims.SessionUUID, AuthUUID: claims.UUID}
+		if err := h.ss.Revoke(gctx.Request.Context(), session); err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+	}
+
+	m.ClearAuthCookies(gctx)
 
 	c_at.RespAtom[*struct{}](
 		gctx,
@@ -173,10 +217,243 @@ func (h *AuthHandler) Logout(gctx *gin.Context) {
 	)
 }
 
-func (h *AuthHandler) GetByID(gctx *gin.Context) {}
+// logoutClaims returns the claims of the first auth cookie that names a
+// session, refresh before access, or nil when neither does.
+func logoutClaims(gctx *gin.Context) *m.Claims {
+	if refreshToken, err := gctx.Cookie("refresh_token"); err == nil {
+		if claims, valid := m.ParseRefreshToken(refreshToken); valid && claims.SessionUUID != "" {
+			return claims
+		}
+	}
+
+	if accessToken, err := gctx.Cookie("access_token"); err == nil {
+		if claims, valid := m.ParseAccessToken(accessToken); valid && claims.SessionUUID != "" {
+			return claims
+		}
+	}
+
+	return nil
+}
+
+func (h *AuthHandler) Verify(gctx *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required,max=128"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if err := h.s.VerifyEmail(gctx.Request.Context(), req.Token); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Email verified.", nil)
+}
+
+// ResendVerification answers the same whether or not the email exists.
+func (h *AuthHandler) ResendVerification(gctx *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if err := h.s.SendVerification(gctx.Request.Context(), req.Email, gctx.ClientIP()); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) If the email is pending verification, a link was sent.", nil)
+}
+
+// ForgotPassword answers the same whether or not the email exists.
+func (h *AuthHandler) ForgotPassword(gctx *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if err := h.s.ForgotPassword(gctx.Request.Context(), req.Email, gctx.ClientIP()); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) If the email is registered, a reset link was sent.", nil)
+}
+
+func (h *AuthHandler) ResetPassword(gctx *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required,max=128"`
+		Password string `json:"password" binding:"required,min=8,max=25"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if err := h.s.ResetPassword(gctx.Request.Context(), req.Token, req.Password); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	m.ClearAuthCookies(gctx)
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Password reset.", nil)
+}
+
+// GetByID returns the profile of the logged auth.
+func (h *AuthHandler) GetByID(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
 
-func (h *AuthHandler) Fetch(gctx *gin.Context) {}
+	auth := &d.Auth{UUID: authUUID}
+	if err := h.s.GetByID(gctx.Request.Context(), auth); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
 
-func (h *AuthHandler) Update(gctx *gin.Context) {}
+	c_at.RespAtom[d.Auth](gctx, http.StatusOK, "(*) Authentication found.", *auth)
+}
+
+// Fetch lists every authentication for admins, a page at a time.
+func (h *AuthHandler) Fetch(gctx *gin.Context) {
+	var req struct {
+		Page     uint64 `form:"page" binding:"omitempty,max=10000"`
+		PageSize uint64 `form:"page_size" binding:"omitempty,min=1,max=100"`
+	}
+
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid query parameters.",
+			"Invalid query parameters.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	auths, err := h.s.Fetch(gctx.Request.Context(), req.PageSize, req.Page*req.PageSize)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[[]d.Auth](gctx, http.StatusOK, "(*) Data retrivied", auths)
+}
 
-func (h *AuthHandler) Delete(gctx *gin.Context) {}
+// Update changes the email and/or password of the logged auth. Changing the
+// password signs out every other session.
+func (h *AuthHandler) Update(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		Email           string `json:"email" binding:"omitempty,email"`
+		Password        string `json:"password" binding:"omitempty,min=8,max=25"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil || (req.Email == "" && req.Password == "") {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	auth := &d.Auth{UUID: authUUID, Email: req.Email, Password: req.Password}
+	if err := h.s.UpdateCredentials(gctx.Request.Context(), auth, req.CurrentPassword); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.Password != "" {
+		sessionUUID, _ := m.GetSessionUUID(gctx)
+		if _, err := h.ss.RevokeOthers(gctx.Request.Context(), &sd.Session{SessionUUID: sessionUUID, AuthUUID: authUUID}); err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+	}
+
+	c_at.RespAtom[d.Auth](gctx, http.StatusOK, "(*) Authentication updated.", *auth)
+}
+
+// Delete soft-deletes the logged auth, its agents and chats, and signs it out.
+func (h *AuthHandler) Delete(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if err := h.s.DeleteWithPassword(gctx.Request.Context(), &d.Auth{UUID: authUUID}, req.CurrentPassword); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	m.ClearAuthCookies(gctx)
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Authentication deleted.", nil)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"aigents-base/internal/auth-land/auth-signature/keyset"
+	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	sd "aigents-base/internal/auth-land/sessions/domain"
+
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeSessionService records the sessions Logout revokes.
+type fakeSessionService struct {
+	revoked []string
+}
+
+func (s *fakeSessionService) Create(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) GetByID(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) Fetch(ctx context.Context, limit, offset uint64) ([]sd.Session, error) {
+	return nil, nil
+}
+
+func (s *fakeSessionService) Update(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) Delete(ctx context.Context, data *sd.Session) error {
+	return nil
+}
+
+func (s *fakeSessionService) Rotate(ctx context.Context, data *sd.Session, presentedJTI string) error {
+	return nil
+}
+
+func (s *fakeSessionService) Revoke(ctx context.Context, data *sd.Session) error {
+	s.revoked = append(s.revoked, data.SessionUUID)
+	return nil
+}
+
+func (s *fakeSessionService) FetchByAuth(ctx context.Context, authUUID, currentSessionUUID string) ([]sd.Session, error) {
+	return nil, nil
+}
+
+func (s *fakeSessionService) RevokeOthers(ctx context.Context, data *sd.Session) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeSessionService) IsActive(ctx context.Context, sessionUUID string) (bool, error) {
+	return true, nil
+}
+
+func useTestKeySet(t *testing.T) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "test.pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := keyset.Load(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.UseKeySet(ks)
+	t.Cleanup(func() { m.UseKeySet(nil) })
+}
+
+// token signs a token of sessionUUID for audience, expiring after ttl.
+func token(t *testing.T, audience, sessionUUID string, ttl time.Duration) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := &m.Claims{
+		UUID:        "auth-1",
+		Role:        "USER",
+		SessionUUID: sessionUUID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now.Add(-time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	signed, err := m.SignToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestKeySet(t)
+
+	access := token(t, "access", "from-access", time.Minute)
+	refresh := token(t, "refresh", "from-refresh", time.Hour)
+
+	tests := []struct {
+		name    string
+		cookies map[string]string
+		want    []string
+	}{
+		{
+			name:    "refresh cookie",
+			cookies: map[string]string{"refresh_token": refresh, "access_token": access},
+			want:    []string{"from-refresh"},
+		},
+		{
+			name: "expired refresh cookie",
+			cookies: map[string]string{
+				"refresh_token": token(t, "refresh", "from-refresh", -time.Minute),
+				"access_token":  access,
+			},
+			want: []string{"from-access"},
+		},
+		{
+			name:    "tampered refresh cookie",
+			cookies: map[string]string{"refresh_token": refresh + "x", "access_token": access},
+			want:    []string{"from-access"},
+		},
+		{
+			name:    "access token as refresh cookie",
+			cookies: map[string]string{"refresh_token": access},
+			want:    nil,
+		},
+		{
+			name:    "no cookies",
+			cookies: map[string]string{},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &fakeSessionService{}
+			h := NewAuthHandler(nil, sessions)
+
+			req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+
+			rec := httptest.NewRecorder()
+			gctx, _ := gin.CreateTestContext(rec)
+			gctx.Request = req
+
+			h.Logout(gctx)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+
+			if len(sessions.revoked) != len(tt.want) || (len(tt.want) > 0 && sessions.revoked[0] != tt.want[0]) {
+				t.Errorf("revoked = %v, want %v", sessions.revoked, tt.want)
+			}
+		})
+	}
+}
@@ -4,15 +4,30 @@ import (
 	citf "aigents-base/internal/common/interfaces"
 	d "aigents-base/internal/auth-land/auth/domain"
 
-	"github.com/gin-gonic/gin"
+	"context"
+	"time"
 )
 
 type AuthServiceITF interface {
 	citf.Common[d.Auth]
-	Comparate(gctx *gin.Context, data *d.Auth) error
+	Comparate(ctx context.Context, data *d.Auth, clientIP, userAgent string) error
+	UpdateCredentials(ctx context.Context, data *d.Auth, currentPassword string) error
+	DeleteWithPassword(ctx context.Context, data *d.Auth, currentPassword string) error
+	SendVerification(ctx context.Context, email, clientIP string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email, clientIP string) error
+	ResetPassword(ctx context.Context, token, password string) error
 }
 
 type AuthRepositoryITF interface {
 	citf.Common[d.Auth]
-	GetByEmail(gctx *gin.Context, data *d.Auth) error
+	GetByEmail(ctx context.Context, data *d.Auth) error
+	CreateToken(ctx context.Context, token *d.AuthToken, ttl time.Duration) error
+	VerifyEmail(ctx context.Context, token *d.AuthToken) error
+	ResetPassword(ctx context.Context, token *d.AuthToken, hashedPass string) error
+	GetThrottle(ctx context.Context, data *d.LoginThrottle) error
+	RecordFailure(ctx context.Context, data *d.LoginThrottle, window time.Duration) error
+	LockThrottle(ctx context.Context, data *d.LoginThrottle, lockout time.Duration) error
+	ClearThrottle(ctx context.Context, data *d.LoginThrottle) error
+	CreateAuditEntry(ctx context.Context, entry *d.AuditEntry) error
 }
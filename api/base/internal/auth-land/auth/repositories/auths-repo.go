@@ -3,13 +3,14 @@ package repositories
 import (
 	d "aigents-base/internal/auth-land/auth/domain"
 	auitf "aigents-base/internal/auth-land/auth/interfaces"
-	c_at "aigents-base/internal/common/atoms"
-	"fmt"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
 
+	"context"
 	"database/sql"
-	"net/http"
+	"errors"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 )
 
@@ -22,7 +23,12 @@ func NewAuthRepository(db *sql.DB) auitf.AuthRepositoryITF {
 	return &AuthRepository{db: db}
 }
 
-func (a *AuthRepository) Create(gctx *gin.Context, data *d.Auth) error {
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (a *AuthRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, a.db)
+}
+
+func (a *AuthRepository) Create(ctx context.Context, data *d.Auth) error {
 	query := `
 		INSERT INTO auths (
 			email,
@@ -31,7 +37,7 @@ func (a *AuthRepository) Create(gctx *gin.Context, data *d.Auth) error {
 		RETURNING auth_uuid, created_at, updated_at, COALESCE(deleted_at, TIMESTAMP '0001-01-01 00:00:00');
 	`
 
-	err := a.db.QueryRow(query, data.Email, data.Password).Scan(
+	err := a.conn(ctx).QueryRowContext(ctx, query, data.Email, data.Password).Scan(
 		&data.UUID,
 		&data.CreatedAt,
 		&data.UpdatedAt,
@@ -41,44 +47,51 @@ func (a *AuthRepository) Create(gctx *gin.Context, data *d.Auth) error {
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok {
 			if pgErr.Code == "23505" {
-				err = c_at.AbortAndBuildErrLogAtom(
-					gctx,
-					http.StatusConflict,
+				err = errs.New(
+					errs.Conflict,
 					"(R) Email already registered.",
-					fmt.Sprintf("Email %s already registred", data.Email))
+					"Email already registered.", "email", data.Email)
 				return err
 			}
 		}
 
 
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not register authentication.",
-			fmt.Sprintf("An unknown error ocurred: %s", err.Error()))
+			"An unknown error occurred.", "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func (a *AuthRepository) GetByEmail(gctx *gin.Context, data *d.Auth) error {
+// GetByEmail matches data.Email ignoring case and loads the email as stored.
+func (a *AuthRepository) GetByEmail(ctx context.Context, data *d.Auth) error {
 	query := `SELECT auth_uuid,
+                     email,
                      password,
                      role,
+                     COALESCE(email_verified_at, TIMESTAMP '0001-01-01 00:00:00'),
+                     totp_enabled_at IS NOT NULL,
                      created_at,
                      updated_at,
                      COALESCE(deleted_at, TIMESTAMP '0001-01-01 00:00:00')
               FROM auths
-              WHERE email = $1 AND deleted_at IS NULL;`
+              WHERE lower(email) = lower($1) AND deleted_at IS NULL;`
 
 	var scannedUUID string
 	var scannedRole string
 
-	err := a.db.QueryRow(query, data.Email).Scan(
+	var scannedEmail string
+
+	err := a.conn(ctx).QueryRowContext(ctx, query, data.Email).Scan(
 		&scannedUUID,
+		&scannedEmail,
 		&data.Password,
 		&scannedRole,
+		&data.EmailVerifiedAt,
+		&data.MFAEnabled,
 		&data.CreatedAt,
 		&data.UpdatedAt,
 		&data.DeletedAt,
@@ -86,35 +99,496 @@ func (a *AuthRepository) GetByEmail(gctx *gin.Context, data *d.Auth) error {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			err = fmt.Errorf("ERR_EMAIL_NOT_FOUND")
+			err = errs.New(errs.NotFound, "(R) Authentication not found.", "Email not found.")
 			return err
 		}
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err = errs.New(
+			errs.Internal,
 			"(R) Could not find authentication.",
-			fmt.Sprintf("An unknown error occurred: %s", err.Error()))
+			"An unknown error occurred.", "error", err)
 		return err
 	}
 
 	data.UUID = scannedUUID
+	data.Email = scannedEmail
 	data.Role = scannedRole
 
 	return nil
 }
 
-func (a *AuthRepository) GetByID(gctx *gin.Context, data *d.Auth) error {
+func (a *AuthRepository) GetByID(ctx context.Context, data *d.Auth) error {
+	query := `SELECT email,
+                     COALESCE(pending_email, ''),
+                     password,
+                     role,
+                     COALESCE(email_verified_at, TIMESTAMP '0001-01-01 00:00:00'),
+                     totp_enabled_at IS NOT NULL,
+                     created_at,
+                     updated_at,
+                     COALESCE(deleted_at, TIMESTAMP '0001-01-01 00:00:00')
+              FROM auths
+              WHERE auth_uuid = $1 AND deleted_at IS NULL;`
+
+	err := a.conn(ctx).QueryRowContext(ctx, query, data.UUID).Scan(
+		&data.Email,
+		&data.PendingEmail,
+		&data.Password,
+		&data.Role,
+		&data.EmailVerifiedAt,
+		&data.MFAEnabled,
+		&data.CreatedAt,
+		&data.UpdatedAt,
+		&data.DeletedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = errs.New(errs.NotFound, "(R) Authentication not found.", "Auth not found.")
+			return err
+		}
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not find authentication.",
+			"An unknown error occurred.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Fetch lists every authentication, deleted ones included, newest first.
+func (a *AuthRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Auth, error) {
+	query := `SELECT auth_uuid,
+                     email,
+                     role,
+                     COALESCE(email_verified_at, TIMESTAMP '0001-01-01 00:00:00'),
+                     created_at,
+                     updated_at,
+                     COALESCE(deleted_at, TIMESTAMP '0001-01-01 00:00:00')
+              FROM auths
+              ORDER BY created_at DESC, auth_uuid ASC
+              LIMIT $1 OFFSET $2;`
+
+	rows, err := a.conn(ctx).QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch authentications.",
+			"Failed to fetch authentications.", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	auths := []d.Auth{}
+
+	for rows.Next() {
+		var auth d.Auth
+
+		err := rows.Scan(
+			&auth.UUID,
+			&auth.Email,
+			&auth.Role,
+			&auth.EmailVerifiedAt,
+			&auth.CreatedAt,
+			&auth.UpdatedAt,
+			&auth.DeletedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch authentications.",
+				"Failed to scan authentication.", "error", err)
+			return nil, err
+		}
+
+		auths = append(auths, auth)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch authentications.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	return auths, nil
+}
+
+// Update writes the password. A new email is only stored as pending, the
+// current one and its verification stay in place until VerifyEmail confirms
+// it; asking for the current email back drops the pending one. On return
+// data.Email is the current email.
+func (a *AuthRepository) Update(ctx context.Context, data *d.Auth) error {
+	query := `
+		UPDATE auths
+		SET password = $3,
+		    pending_email = CASE WHEN email = $2 THEN NULL ELSE $2 END
+		WHERE auth_uuid = $1 AND deleted_at IS NULL
+		RETURNING email,
+		          COALESCE(pending_email, ''),
+		          COALESCE(email_verified_at, TIMESTAMP '0001-01-01 00:00:00'),
+		          updated_at;
+	`
+
+	err := a.conn(ctx).QueryRowContext(ctx, query, data.UUID, data.Email, data.Password).Scan(
+		&data.Email,
+		&data.PendingEmail,
+		&data.EmailVerifiedAt,
+		&data.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = errs.New(errs.NotFound, "(R) Authentication not found.", "Auth not found.")
+			return err
+		}
+
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not update authentication.",
+			"An unknown error occurred.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete soft-deletes the authentication together with its agents and chats
+// and revokes all of its sessions and API keys. The email is free to register
+// again afterwards.
+func (a *AuthRepository) Delete(ctx context.Context, data *d.Auth) error {
+	return c_db.RunInTx(ctx, a.db, func(ctx context.Context) error {
+		err := a.conn(ctx).QueryRowContext(ctx,
+			"UPDATE auths SET deleted_at = NOW() WHERE auth_uuid = $1 AND deleted_at IS NULL RETURNING deleted_at;",
+			data.UUID,
+		).Scan(&data.DeletedAt)
+
+		if err == sql.ErrNoRows {
+			err = errs.New(errs.NotFound, "(R) Authentication not found.", "Auth not found.")
+			return err
+		}
+
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not delete authentication.",
+				"Failed to delete auth.", "auth_uuid", data.UUID, "error", err)
+			return err
+		}
+
+		cascade := []string{
+			"UPDATE agents SET deleted_at = NOW() WHERE auth_uuid = $1 AND deleted_at IS NULL;",
+			"UPDATE chats SET deleted_at = NOW() WHERE auth_uuid = $1 AND deleted_at IS NULL;",
+			"UPDATE auth_sessions SET revoked_at = NOW() WHERE auth_uuid = $1 AND revoked_at IS NULL;",
+			"UPDATE api_keys SET revoked_at = NOW() WHERE auth_uuid = $1 AND revoked_at IS NULL;",
+		}
+
+		for _, stmt := range cascade {
+			if _, err = a.conn(ctx).ExecContext(ctx, stmt, data.UUID); err != nil {
+				err = errs.New(
+					errs.Internal,
+					"(R) Could not delete authentication.",
+					"Failed to cascade delete of auth.", "auth_uuid", data.UUID, "error", err)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateToken stores a new token for token.AuthUUID, invalidating any unused
+// one issued earlier for the same purpose.
+func (a *AuthRepository) CreateToken(ctx context.Context, token *d.AuthToken, ttl time.Duration) error {
+	return c_db.RunInTx(ctx, a.db, func(ctx context.Context) error {
+		_, err := a.conn(ctx).ExecContext(ctx,
+			"UPDATE auth_tokens SET used_at = NOW() WHERE auth_uuid = $1 AND purpose = $2 AND used_at IS NULL;",
+			token.AuthUUID,
+			token.Purpose,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not create token.",
+				"Failed to invalidate previous tokens.", "error", err)
+			return err
+		}
+
+		query := `
+			INSERT INTO auth_tokens (token_hash, auth_uuid, purpose, email, expires_at)
+			VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')
+			RETURNING expires_at;
+		`
+
+		err = a.conn(ctx).QueryRowContext(ctx,
+			query,
+			token.TokenHash,
+			token.AuthUUID,
+			token.Purpose,
+			token.Email,
+			int64(ttl.Seconds()),
+		).Scan(&token.ExpiresAt)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not create token.",
+				"Failed to insert token.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// consumeToken marks token.TokenHash used through q and loads who it was
+// issued to. Unknown, used and expired tokens give a validation error.
+func consumeToken(ctx context.Context, q c_db.Querier, token *d.AuthToken) error {
+	query := `
+		UPDATE auth_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING auth_uuid, email, used_at;
+	`
+
+	err := q.QueryRowContext(ctx, query, token.TokenHash, token.Purpose).Scan(
+		&token.AuthUUID,
+		&token.Email,
+		&token.UsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return errs.New(errs.Validation, "(R) Invalid or expired token.", "Token unknown, used or expired.")
+	}
+
+	return err
+}
+
+// VerifyEmail consumes a verification token and marks the address verified,
+// as long as it is still the email or the pending email of the
+// authentication; a pending email becomes the email.
+func (a *AuthRepository) VerifyEmail(ctx context.Context, token *d.AuthToken) error {
+	return c_db.RunInTx(ctx, a.db, func(ctx context.Context) error {
+		err := consumeToken(ctx, a.conn(ctx), token)
+		if err == nil {
+			query := `
+				UPDATE auths
+				SET email = $2,
+				    pending_email = CASE WHEN pending_email = $2 THEN NULL ELSE pending_email END,
+				    email_verified_at = NOW()
+				WHERE auth_uuid = $1 AND (email = $2 OR pending_email = $2) AND deleted_at IS NULL;
+			`
+
+			var res sql.Result
+			res, err = a.conn(ctx).ExecContext(ctx, query, token.AuthUUID, token.Email)
+
+			if err == nil {
+				var affected int64
+				affected, err = res.RowsAffected()
+				if err == nil && affected == 0 {
+					err = errs.New(errs.Validation, "(R) Invalid or expired token.", "Token unknown, used or expired.")
+				}
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, errs.Validation) {
+				return err
+			}
+
+			// the pending email was registered by someone else meanwhile
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+				err = errs.New(
+					errs.Conflict,
+					"(R) Email already registered.",
+					"Pending email taken before verification.", "email", token.Email)
+				return err
+			}
+
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not verify email.",
+				"Failed to verify email.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ResetPassword consumes a reset token, stores hashedPass and revokes every
+// session of the authentication.
+func (a *AuthRepository) ResetPassword(ctx context.Context, token *d.AuthToken, hashedPass string) error {
+	return c_db.RunInTx(ctx, a.db, func(ctx context.Context) error {
+		err := consumeToken(ctx, a.conn(ctx), token)
+		if err == nil {
+			var res sql.Result
+			res, err = a.conn(ctx).ExecContext(ctx,
+				"UPDATE auths SET password = $2 WHERE auth_uuid = $1 AND deleted_at IS NULL;",
+				token.AuthUUID,
+				hashedPass,
+			)
+
+			if err == nil {
+				var affected int64
+				affected, err = res.RowsAffected()
+				if err == nil && affected == 0 {
+					err = errs.New(errs.Validation, "(R) Invalid or expired token.", "Token unknown, used or expired.")
+				}
+			}
+		}
+
+		if err == nil {
+			_, err = a.conn(ctx).ExecContext(ctx,
+				"UPDATE auth_sessions SET revoked_at = NOW() WHERE auth_uuid = $1 AND revoked_at IS NULL;",
+				token.AuthUUID,
+			)
+		}
+
+		if err != nil {
+			if errors.Is(err, errs.Validation) {
+				return err
+			}
+
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not reset password.",
+				"Failed to reset password.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// GetThrottle loads the failure counter of data.Scope/data.Key. Keys without
+// failures come back with zero values.
+func (a *AuthRepository) GetThrottle(ctx context.Context, data *d.LoginThrottle) error {
+	query := `
+		SELECT failures,
+		       EXTRACT(EPOCH FROM NOW() - last_failure_at)::BIGINT,
+		       GREATEST(EXTRACT(EPOCH FROM COALESCE(locked_until, NOW()) - NOW()), 0)::BIGINT
+		FROM login_throttles
+		WHERE scope = $1 AND throttle_key = $2;
+	`
+
+	var sinceSecs, lockedSecs int64
+
+	err := a.conn(ctx).QueryRowContext(ctx, query, data.Scope, data.Key).Scan(
+		&data.Failures,
+		&sinceSecs,
+		&lockedSecs,
+	)
+
+	if err == sql.ErrNoRows {
+		data.Failures = 0
+		data.SinceLastFailure = 0
+		data.LockedFor = 0
+		return nil
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not check login attempts.",
+			"Failed to get throttle.", "scope", data.Scope, "error", err)
+		return err
+	}
+
+	data.SinceLastFailure = time.Duration(sinceSecs) * time.Second
+	data.LockedFor = time.Duration(lockedSecs) * time.Second
+
+	return nil
+}
+
+// RecordFailure counts one more failed login. The counter starts over when
+// the previous failure is older than window.
+func (a *AuthRepository) RecordFailure(ctx context.Context, data *d.LoginThrottle, window time.Duration) error {
+	query := `
+		INSERT INTO login_throttles (scope, throttle_key, failures, last_failure_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, throttle_key) DO UPDATE
+		SET failures = CASE
+		        WHEN login_throttles.last_failure_at < NOW() - $3 * INTERVAL '1 second' THEN 1
+		        ELSE login_throttles.failures + 1
+		    END,
+		    last_failure_at = NOW()
+		RETURNING failures;
+	`
+
+	err := a.conn(ctx).QueryRowContext(ctx, query, data.Scope, data.Key, int64(window.Seconds())).Scan(&data.Failures)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not record login attempt.",
+			"Failed to record failure.", "scope", data.Scope, "error", err)
+		return err
+	}
+
+	data.SinceLastFailure = 0
 
 	return nil
 }
-func (a *AuthRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Auth, error) {
-	return []d.Auth{}, nil
+
+func (a *AuthRepository) LockThrottle(ctx context.Context, data *d.LoginThrottle, lockout time.Duration) error {
+	query := `
+		UPDATE login_throttles
+		SET locked_until = NOW() + $3 * INTERVAL '1 second'
+		WHERE scope = $1 AND throttle_key = $2;
+	`
+
+	_, err := a.conn(ctx).ExecContext(ctx, query, data.Scope, data.Key, int64(lockout.Seconds()))
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not record login attempt.",
+			"Failed to lock throttle.", "scope", data.Scope, "error", err)
+		return err
+	}
+
+	data.LockedFor = lockout
+
+	return nil
 }
 
-func (a *AuthRepository) Update(gctx *gin.Context, data *d.Auth) error {
+func (a *AuthRepository) ClearThrottle(ctx context.Context, data *d.LoginThrottle) error {
+	_, err := a.conn(ctx).ExecContext(ctx,
+		"DELETE FROM login_throttles WHERE scope = $1 AND throttle_key = $2;",
+		data.Scope,
+		data.Key,
+	)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not record login attempt.",
+			"Failed to clear throttle.", "scope", data.Scope, "error", err)
+		return err
+	}
+
 	return nil
 }
 
-func (a *AuthRepository) Delete(gctx *gin.Context, data *d.Auth) error {
+func (a *AuthRepository) CreateAuditEntry(ctx context.Context, entry *d.AuditEntry) error {
+	query := `
+		INSERT INTO auth_audit_log (event, auth_uuid, email, client_ip, user_agent, detail)
+		VALUES ($1, NULLIF($2, '')::UUID, $3, $4, $5, $6);
+	`
+
+	_, err := a.conn(ctx).ExecContext(ctx,
+		query,
+		entry.Event,
+		entry.AuthUUID,
+		entry.Email,
+		entry.ClientIP,
+		entry.UserAgent,
+		entry.Detail,
+	)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not write audit entry.",
+			"Failed to write audit entry.", "event", entry.Event, "error", err)
+		return err
+	}
+
 	return nil
 }
@@ -4,80 +4,439 @@ import (
 	a_at "aigents-base/internal/auth-land/auth/atoms"
 	d "aigents-base/internal/auth-land/auth/domain"
 	auitf "aigents-base/internal/auth-land/auth/interfaces"
-	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+	citf "aigents-base/internal/common/interfaces"
 
-
-	"github.com/gin-gonic/gin"
-	"net/http"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 )
 
+// dummyCompare spends the bcrypt work of a password check on logins of
+// unknown emails; a variable so tests can see it run.
+var dummyCompare = a_at.DummyComparePassAtom
 
 type AuthService struct {
-	r auitf.AuthRepositoryITF
+	r          auitf.AuthRepositoryITF
+	mailer     citf.MailerITF
+	appURL     string
+	verifyTTL  time.Duration
+	resetTTL   time.Duration
+	policy     d.LoginPolicy
+	mailPolicy d.MailPolicy
 }
 
-func NewAuthService(repo auitf.AuthRepositoryITF) auitf.AuthServiceITF {
-	return &AuthService{r: repo}
+// NewAuthService builds the service; appURL is the frontend base URL the
+// verification and reset links in emails point to. mailer should not block
+// on delivery, or the time taken to answer tells which emails exist.
+func NewAuthService(
+	repo auitf.AuthRepositoryITF,
+	mailer citf.MailerITF,
+	appURL string,
+	verifyTTL, resetTTL time.Duration,
+	policy d.LoginPolicy,
+	mailPolicy d.MailPolicy,
+) auitf.AuthServiceITF {
+	return &AuthService{
+		r:          repo,
+		mailer:     mailer,
+		appURL:     appURL,
+		verifyTTL:  verifyTTL,
+		resetTTL:   resetTTL,
+		policy:     policy,
+		mailPolicy: mailPolicy,
+	}
 }
 
-func (s *AuthService) Create(gctx *gin.Context, data *d.Auth) error {
+func (s *AuthService) Create(ctx context.Context, data *d.Auth) error {
 	hashedPass, err := a_at.HashPassAtom(data.Password)
 	if err != nil {
 		return err
 	}
 	data.Password = hashedPass
 
-	if err := s.r.Create(gctx, data); err != nil {
+	if err := s.r.Create(ctx, data); err != nil {
 		return err
 	}
 
-	return nil
+	return s.mailToken(ctx, data, d.TokenVerifyEmail)
 }
 
-func (s *AuthService) Comparate(gctx *gin.Context, data *d.Auth) error {
+// Comparate checks the credentials in data, loading the UUID and role on
+// success. Failures are throttled per clientIP and per email, see
+// d.LoginPolicy; userAgent only goes into the audit trail.
+func (s *AuthService) Comparate(ctx context.Context, data *d.Auth, clientIP, userAgent string) error {
+	throttles := []*d.LoginThrottle{
+		{Scope: d.ThrottleScopeIP, Key: clientIP},
+		{Scope: d.ThrottleScopeEmail, Key: strings.ToLower(strings.TrimSpace(data.Email))},
+	}
+
+	if err := s.checkThrottles(ctx, throttles); err != nil {
+		return err
+	}
+
 	auth := &d.Auth{}
 	auth.Email = data.Email
 
-	err := s.r.GetByEmail(gctx, auth)
+	err := s.r.GetByEmail(ctx, auth)
 	if err != nil {
-		if err.Error() == "ERR_EMAIL_NOT_FOUND" {
-			err = c_at.AbortAndBuildErrLogAtom(
-				gctx,
-				http.StatusUnauthorized,
-				"(S) Invalid credentials.",
-				fmt.Sprintf("Authentication of %s not found", data.Email))
+		if !errors.Is(err, errs.NotFound) {
+			return err
 		}
-		return err
+
+		dummyCompare(data.Password)
+		return s.failLogin(ctx, throttles, "", data.Email, clientIP, userAgent,
+			"Login of unknown email.")
 	}
 
 	if !a_at.ComparePassAtom(auth.Password, data.Password) {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
-			"(S) Invalid credentials.",
-			fmt.Sprintf("Incorrect password of %s", auth.Email))
+		return s.failLogin(ctx, throttles, auth.UUID, data.Email, clientIP, userAgent,
+			"Login with incorrect password.")
+	}
+
+	if err := s.r.ClearThrottle(ctx, throttles[1]); err != nil {
+		return err
+	}
+
+	if auth.EmailVerifiedAt.IsZero() {
+		err = errs.New(
+			errs.Forbidden,
+			"(S) Email not verified.",
+			"Login of unverified email.", "email", auth.Email)
 		return err
 	}
 
 	data.UUID = auth.UUID
 	data.Role = auth.Role
+	data.MFAEnabled = auth.MFAEnabled
+
+	return nil
+}
+
+// checkThrottles rejects the attempt with 429 while any throttle is locked
+// or still inside its backoff delay.
+func (s *AuthService) checkThrottles(ctx context.Context, throttles []*d.LoginThrottle) error {
+	for _, t := range throttles {
+		if err := s.r.GetThrottle(ctx, t); err != nil {
+			return err
+		}
+
+		wait := t.LockedFor
+		if wait == 0 {
+			wait = a_at.BackoffDelayAtom(t.Failures, s.policy.BaseDelay, s.policy.MaxDelay) - t.SinceLastFailure
+		}
+
+		if wait > 0 {
+			err := errs.New(
+				errs.TooManyRequests,
+				"(S) Too many login attempts. Try again later.",
+				"Login throttled.", "scope", t.Scope, "key", t.Key, "retry_after", wait).WithRetryAfter(wait)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// failLogin records the failure on every throttle, locks the ones that hit
+// their limit (leaving an audit entry) and answers 401.
+func (s *AuthService) failLogin(
+	ctx context.Context,
+	throttles []*d.LoginThrottle,
+	authUUID, email, clientIP, userAgent, logMsg string,
+) error {
+	for _, t := range throttles {
+		if err := s.r.RecordFailure(ctx, t, s.policy.Lockout); err != nil {
+			return err
+		}
+
+		limit := s.policy.MaxEmailFailures
+		if t.Scope == d.ThrottleScopeIP {
+			limit = s.policy.MaxIPFailures
+		}
+
+		if limit <= 0 || t.Failures < limit {
+			continue
+		}
+
+		if err := s.r.LockThrottle(ctx, t, s.policy.Lockout); err != nil {
+			return err
+		}
+
+		entry := &d.AuditEntry{
+			Event:     d.AuditLoginLockout,
+			AuthUUID:  authUUID,
+			Email:     email,
+			ClientIP:  clientIP,
+			UserAgent: userAgent,
+			Detail:    fmt.Sprintf("%s %s locked for %s after %d failed logins", t.Scope, t.Key, s.policy.Lockout, t.Failures),
+		}
+		if err := s.r.CreateAuditEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	return errs.New(
+		errs.Unauthorized,
+		"(S) Invalid credentials.",
+		logMsg, "email", email)
+}
+
+func (s *AuthService) GetByID(ctx context.Context, data *d.Auth) error {
+	err := s.r.GetByID(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errAuthNotFound(data.UUID)
+	}
+
+	return err
+}
+
+func (s *AuthService) Fetch(ctx context.Context, limit, offset uint64) ([]d.Auth, error) {
+	return s.r.Fetch(ctx, limit, offset)
+}
+
+func (s *AuthService) Update(ctx context.Context, data *d.Auth) error {
+	err := s.r.Update(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errAuthNotFound(data.UUID)
+	}
+
+	return err
+}
+
+func (s *AuthService) Delete(ctx context.Context, data *d.Auth) error {
+	err := s.r.Delete(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errAuthNotFound(data.UUID)
+	}
+
+	return err
+}
+
+// UpdateCredentials changes the email and/or password of data.UUID once
+// currentPassword checks out. Empty fields in data are left unchanged; a new
+// email is kept pending and mailed a verification link, the current one
+// still signs in until then. On return data holds the stored profile.
+func (s *AuthService) UpdateCredentials(ctx context.Context, data *d.Auth, currentPassword string) error {
+	current := &d.Auth{UUID: data.UUID}
+	if err := s.checkPassword(ctx, current, currentPassword); err != nil {
+		return err
+	}
+
+	if data.Email != "" {
+		current.Email = data.Email
+	} else if current.PendingEmail != "" {
+		// Update would read the current email as dropping the pending one
+		current.Email = current.PendingEmail
+	}
+
+	if data.Password != "" {
+		hashedPass, err := a_at.HashPassAtom(data.Password)
+		if err != nil {
+			return err
+		}
+		current.Password = hashedPass
+	}
+
+	if err := s.Update(ctx, current); err != nil {
+		return err
+	}
+
+	newEmail := data.Email
+	*data = *current
+
+	if newEmail != "" && current.PendingEmail != "" {
+		return s.mailToken(ctx, &d.Auth{UUID: current.UUID, Email: current.PendingEmail}, d.TokenVerifyEmail)
+	}
+
+	return nil
+}
+
+// DeleteWithPassword soft-deletes data.UUID once currentPassword checks out.
+func (s *AuthService) DeleteWithPassword(ctx context.Context, data *d.Auth, currentPassword string) error {
+	if err := s.checkPassword(ctx, &d.Auth{UUID: data.UUID}, currentPassword); err != nil {
+		return err
+	}
+
+	return s.Delete(ctx, data)
+}
+
+// checkPassword loads data.UUID into data and verifies password against it.
+func (s *AuthService) checkPassword(ctx context.Context, data *d.Auth, password string) error {
+	if err := s.GetByID(ctx, data); err != nil {
+		return err
+	}
+
+	if !a_at.ComparePassAtom(data.Password, password) {
+		err := errs.New(
+			errs.Unauthorized,
+			"(S) Invalid current password.",
+			"Incorrect current password.", "email", data.Email)
+		return err
+	}
+
+	return nil
+}
+
+// SendVerification mails a new verification link to email. Unknown and
+// already verified addresses are silently skipped so the endpoint can't be
+// used to probe for accounts; requests are counted per clientIP and per
+// email, see d.MailPolicy.
+func (s *AuthService) SendVerification(ctx context.Context, email, clientIP string) error {
+	if err := s.throttleMail(ctx, email, clientIP); err != nil {
+		return err
+	}
+
+	auth := &d.Auth{Email: email}
+	err := s.r.GetByEmail(ctx, auth)
+	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if !auth.EmailVerifiedAt.IsZero() {
+		return nil
+	}
+
+	return s.mailToken(ctx, auth, d.TokenVerifyEmail)
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	authToken := &d.AuthToken{TokenHash: a_at.HashTokenAtom(token), Purpose: d.TokenVerifyEmail}
+
+	err := s.r.VerifyEmail(ctx, authToken)
+	if errors.Is(err, errs.Validation) {
+		err = errInvalidToken(d.TokenVerifyEmail)
+	}
+
+	return err
+}
+
+// ForgotPassword mails a reset link to email, skipping unknown addresses
+// silently and throttling like SendVerification.
+func (s *AuthService) ForgotPassword(ctx context.Context, email, clientIP string) error {
+	if err := s.throttleMail(ctx, email, clientIP); err != nil {
+		return err
+	}
+
+	auth := &d.Auth{Email: email}
+	err := s.r.GetByEmail(ctx, auth)
+	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return s.mailToken(ctx, auth, d.TokenResetPassword)
+}
+
+// throttleMail counts one email request for clientIP and for email, known
+// or not, answering 429 while either is over its d.MailPolicy limit.
+func (s *AuthService) throttleMail(ctx context.Context, email, clientIP string) error {
+	throttles := []*d.LoginThrottle{
+		{Scope: d.ThrottleScopeMailIP, Key: clientIP},
+		{Scope: d.ThrottleScopeMailEmail, Key: strings.ToLower(strings.TrimSpace(email))},
+	}
+
+	for _, t := range throttles {
+		if err := s.r.GetThrottle(ctx, t); err != nil {
+			return err
+		}
+
+		if t.LockedFor > 0 {
+			err := errs.New(
+				errs.TooManyRequests,
+				"(S) Too many email requests. Try again later.",
+				"Mail request throttled.", "scope", t.Scope, "key", t.Key, "retry_after", t.LockedFor).WithRetryAfter(t.LockedFor)
+			return err
+		}
+	}
+
+	for _, t := range throttles {
+		if err := s.r.RecordFailure(ctx, t, s.mailPolicy.Window); err != nil {
+			return err
+		}
+
+		limit := s.mailPolicy.MaxPerEmail
+		if t.Scope == d.ThrottleScopeMailIP {
+			limit = s.mailPolicy.MaxPerIP
+		}
+
+		if limit > 0 && t.Failures >= limit {
+			if err := s.r.LockThrottle(ctx, t, s.mailPolicy.Window); err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
 
-func (s *AuthService) GetByID(gctx *gin.Context, data *d.Auth) error {
-	return s.r.GetByID(gctx, data)
+// ResetPassword sets password through a reset token, signing the account out
+// everywhere.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	hashedPass, err := a_at.HashPassAtom(password)
+	if err != nil {
+		return err
+	}
+
+	authToken := &d.AuthToken{TokenHash: a_at.HashTokenAtom(token), Purpose: d.TokenResetPassword}
+
+	err = s.r.ResetPassword(ctx, authToken, hashedPass)
+	if errors.Is(err, errs.Validation) {
+		err = errInvalidToken(d.TokenResetPassword)
+	}
+
+	return err
 }
 
-func (s *AuthService) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Auth, error) {
-	return s.r.Fetch(gctx, limit, offset)
+// mailToken issues a purpose token for auth and hands its link to the
+// mailer. A failed hand-off is only logged: the token is stored and the user
+// can ask again.
+func (s *AuthService) mailToken(ctx context.Context, auth *d.Auth, purpose string) error {
+	token, hash, err := a_at.NewTokenAtom()
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(S) Could not create token.",
+			"Failed to generate token.", "error", err)
+		return err
+	}
+
+	ttl, subject, body := s.verifyTTL, "Verify your email", "Confirm your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThe link expires in %s."
+	if purpose == d.TokenResetPassword {
+		ttl, subject, body = s.resetTTL, "Reset your password", "Choose a new password by opening the link below:\n\n%s/reset-password?token=%s\n\nThe link expires in %s. If you did not ask for it, ignore this email."
+	}
+
+	authToken := &d.AuthToken{TokenHash: hash, AuthUUID: auth.UUID, Purpose: purpose, Email: auth.Email}
+	if err := s.r.CreateToken(ctx, authToken, ttl); err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(auth.Email, subject, fmt.Sprintf(body, s.appURL, token, ttl))
+	if err != nil {
+		slog.Error("Failed to mail token.", "purpose", purpose, "email", auth.Email, "error", err)
+	}
+
+	return nil
 }
 
-func (s *AuthService) Update(gctx *gin.Context, data *d.Auth) error {
-	return s.r.Update(gctx, data)
+func errInvalidToken(purpose string) error {
+	return errs.New(
+		errs.Validation,
+		"(S) Invalid or expired token.",
+		"Invalid token.", "purpose", purpose)
 }
 
-func (s *AuthService) Delete(gctx *gin.Context, data *d.Auth) error {
-	return s.r.Delete(gctx, data)
+func errAuthNotFound(authUUID string) error {
+	return errs.New(
+		errs.NotFound,
+		"(S) Authentication not found.",
+		"Auth not found.", "auth_uuid", authUUID)
 }
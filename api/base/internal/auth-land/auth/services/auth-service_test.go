@@ -0,0 +1,267 @@
+package services
+
+import (
+	d "aigents-base/internal/auth-land/auth/domain"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeThrottle struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// fakeAuthRepository keeps authentications and login throttles in memory,
+// measuring throttle durations against now, which tests move forward.
+type fakeAuthRepository struct {
+	now       time.Time
+	auths     map[string]d.Auth
+	throttles map[string]*fakeThrottle
+	audit     []d.AuditEntry
+}
+
+func newFakeAuthRepository(t *testing.T) *fakeAuthRepository {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &fakeAuthRepository{
+		now: time.Unix(1700000000, 0),
+		auths: map[string]d.Auth{
+			"user@example.com": {
+				UUID:            "auth-1",
+				Email:           "user@example.com",
+				Password:        string(hash),
+				Role:            "USER",
+				EmailVerifiedAt: time.Unix(1600000000, 0),
+			},
+		},
+		throttles: map[string]*fakeThrottle{},
+	}
+}
+
+func (r *fakeAuthRepository) Create(ctx context.Context, data *d.Auth) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) GetByID(ctx context.Context, data *d.Auth) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Auth, error) {
+	return nil, nil
+}
+
+func (r *fakeAuthRepository) Update(ctx context.Context, data *d.Auth) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) Delete(ctx context.Context, data *d.Auth) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) GetByEmail(ctx context.Context, data *d.Auth) error {
+	auth, ok := r.auths[strings.ToLower(data.Email)]
+	if !ok {
+		return errs.New(errs.NotFound, "(R) Authentication not found.", "Auth not found.")
+	}
+
+	*data = auth
+	return nil
+}
+
+func (r *fakeAuthRepository) CreateToken(ctx context.Context, token *d.AuthToken, ttl time.Duration) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) VerifyEmail(ctx context.Context, token *d.AuthToken) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) ResetPassword(ctx context.Context, token *d.AuthToken, hashedPass string) error {
+	return nil
+}
+
+func (r *fakeAuthRepository) GetThrottle(ctx context.Context, data *d.LoginThrottle) error {
+	data.Failures, data.SinceLastFailure, data.LockedFor = 0, 0, 0
+
+	t, ok := r.throttles[data.Scope+"/"+data.Key]
+	if !ok {
+		return nil
+	}
+
+	data.Failures = t.failures
+	data.SinceLastFailure = r.now.Sub(t.lastFailure)
+	if t.lockedUntil.After(r.now) {
+		data.LockedFor = t.lockedUntil.Sub(r.now)
+	}
+
+	return nil
+}
+
+func (r *fakeAuthRepository) RecordFailure(ctx context.Context, data *d.LoginThrottle, window time.Duration) error {
+	key := data.Scope + "/" + data.Key
+
+	t, ok := r.throttles[key]
+	if !ok || t.lastFailure.Before(r.now.Add(-window)) {
+		t = &fakeThrottle{}
+		r.throttles[key] = t
+	}
+
+	t.failures++
+	t.lastFailure = r.now
+
+	data.Failures = t.failures
+	data.SinceLastFailure = 0
+	return nil
+}
+
+func (r *fakeAuthRepository) LockThrottle(ctx context.Context, data *d.LoginThrottle, lockout time.Duration) error {
+	r.throttles[data.Scope+"/"+data.Key].lockedUntil = r.now.Add(lockout)
+	data.LockedFor = lockout
+	return nil
+}
+
+func (r *fakeAuthRepository) ClearThrottle(ctx context.Context, data *d.LoginThrottle) error {
+	delete(r.throttles, data.Scope+"/"+data.Key)
+	return nil
+}
+
+func (r *fakeAuthRepository) CreateAuditEntry(ctx context.Context, entry *d.AuditEntry) error {
+	r.audit = append(r.audit, *entry)
+	return nil
+}
+
+func login(sv *AuthService, email, password, clientIP string) error {
+	return sv.Comparate(context.Background(), &d.Auth{Email: email, Password: password}, clientIP, "test-agent")
+}
+
+func retryAfter(err error) time.Duration {
+	if e, ok := errs.As(err); ok && e.Kind == errs.TooManyRequests {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+func TestComparateBackoffGrows(t *testing.T) {
+	repo := newFakeAuthRepository(t)
+	sv := &AuthService{r: repo, policy: d.LoginPolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  4 * time.Second,
+		Lockout:   time.Hour,
+	}}
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if err := login(sv, "user@example.com", "wrong-password", "10.0.0.1"); !errors.Is(err, errs.Unauthorized) {
+			t.Fatalf("wrong password: error = %v, want Unauthorized", err)
+		}
+
+		// even the right password waits out the delay
+		err := login(sv, "user@example.com", "correct-password", "10.0.0.1")
+		if got := retryAfter(err); got != want {
+			t.Fatalf("retry after = %v (error %v), want %v", got, err, want)
+		}
+
+		repo.now = repo.now.Add(want)
+	}
+
+	if err := login(sv, "user@example.com", "correct-password", "10.0.0.1"); err != nil {
+		t.Fatalf("login after the delay: %v", err)
+	}
+
+	if _, ok := repo.throttles[d.ThrottleScopeEmail+"/user@example.com"]; ok {
+		t.Error("email throttle kept after a successful login")
+	}
+}
+
+func TestComparateLockout(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy d.LoginPolicy
+		emails []string
+		scope  string
+	}{
+		{
+			name:   "email key",
+			policy: d.LoginPolicy{MaxEmailFailures: 3, MaxIPFailures: 100, Lockout: time.Hour},
+			emails: []string{"user@example.com", "User@Example.com ", "user@example.com"},
+			scope:  d.ThrottleScopeEmail,
+		},
+		{
+			name:   "IP key",
+			policy: d.LoginPolicy{MaxEmailFailures: 100, MaxIPFailures: 3, Lockout: time.Hour},
+			emails: []string{"a@example.com", "b@example.com", "user@example.com"},
+			scope:  d.ThrottleScopeIP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeAuthRepository(t)
+			sv := &AuthService{r: repo, policy: tt.policy}
+
+			for i, email := range tt.emails {
+				if err := login(sv, email, "wrong-password", "10.0.0.1"); !errors.Is(err, errs.Unauthorized) {
+					t.Fatalf("failure %d: error = %v, want Unauthorized", i+1, err)
+				}
+
+				if locked := i == len(tt.emails)-1; locked != (len(repo.audit) == 1) {
+					t.Fatalf("after failure %d: %d audit entries", i+1, len(repo.audit))
+				}
+			}
+
+			entry := repo.audit[0]
+			if entry.Event != d.AuditLoginLockout || entry.ClientIP != "10.0.0.1" || entry.UserAgent != "test-agent" {
+				t.Errorf("audit entry = %+v", entry)
+			}
+
+			if !strings.HasPrefix(entry.Detail, tt.scope+" ") {
+				t.Errorf("audit detail = %q, want the %s scope", entry.Detail, tt.scope)
+			}
+
+			err := login(sv, "user@example.com", "correct-password", "10.0.0.1")
+			if got := retryAfter(err); got != time.Hour {
+				t.Fatalf("locked login: retry after = %v (error %v), want 1h", got, err)
+			}
+
+			repo.now = repo.now.Add(time.Hour)
+			if err := login(sv, "user@example.com", "correct-password", "10.0.0.1"); err != nil {
+				t.Fatalf("login after the lockout: %v", err)
+			}
+		})
+	}
+}
+
+func TestComparateUnknownEmail(t *testing.T) {
+	repo := newFakeAuthRepository(t)
+	sv := &AuthService{r: repo}
+
+	var compared []string
+	orig := dummyCompare
+	dummyCompare = func(tryPass string) { compared = append(compared, tryPass) }
+	t.Cleanup(func() { dummyCompare = orig })
+
+	unknown := login(sv, "nobody@example.com", "some-password", "10.0.0.1")
+	wrong := login(sv, "user@example.com", "wrong-password", "10.0.0.1")
+
+	if len(compared) != 1 || compared[0] != "some-password" {
+		t.Errorf("dummy compares = %v, want one for the unknown email", compared)
+	}
+
+	ue, _ := errs.As(unknown)
+	we, _ := errs.As(wrong)
+	if ue == nil || we == nil || ue.Kind != errs.Unauthorized || ue.Msg != we.Msg {
+		t.Errorf("unknown email = %v, wrong password = %v, want the same Unauthorized", unknown, wrong)
+	}
+}
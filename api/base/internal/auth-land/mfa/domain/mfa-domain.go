@@ -0,0 +1,33 @@
+package domain
+
+const (
+	// RecoveryCodeCount is how many recovery codes are handed out at once.
+	RecoveryCodeCount = 10
+
+	// ThrottleScopeMFA keys failed second factor attempts by auth UUID in
+	// login_throttles.
+	ThrottleScopeMFA = "MFA"
+)
+
+// MFAState is the second factor setup of an authentication. SecretEnc is the
+// TOTP secret encrypted at rest; LastStep is the last TOTP time step accepted,
+// so a code can't be replayed.
+type MFAState struct {
+	AuthUUID  string
+	Email     string
+	Role      string
+	SecretEnc string
+	Enabled   bool
+	LastStep  int64
+}
+
+// Enrollment is a pending TOTP secret, shown once so an authenticator app can
+// import it.
+type Enrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
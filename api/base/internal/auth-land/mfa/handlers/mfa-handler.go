@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	d "aigents-base/internal/auth-land/mfa/domain"
+	mfitf "aigents-base/internal/auth-land/mfa/interfaces"
+	sd "aigents-base/internal/auth-land/sessions/domain"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
+	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	s  mfitf.MFAServiceITF
+	ss ssitf.SessionServiceITF
+}
+
+func NewMFAHandler(sv mfitf.MFAServiceITF, sessionSv ssitf.SessionServiceITF) *MFAHandler {
+	return &MFAHandler{s: sv, ss: sessionSv}
+}
+
+func (h *MFAHandler) Enroll(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	enrollment, err := h.s.Enroll(gctx.Request.Context(), authUUID)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.Enrollment](gctx, http.StatusOK, "(*) Confirm with a code from your authenticator app.", *enrollment)
+}
+
+func (h *MFAHandler) Confirm(gctx *gin.Context) {
+	h.withCode(gctx, func(authUUID, code string) {
+		codes, err := h.s.Confirm(gctx.Request.Context(), authUUID, code)
+		if err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		c_at.RespAtom[d.RecoveryCodes](gctx, http.StatusOK, "(*) Two-factor authentication enabled.", *codes)
+	})
+}
+
+func (h *MFAHandler) Disable(gctx *gin.Context) {
+	h.withCode(gctx, func(authUUID, code string) {
+		if err := h.s.Disable(gctx.Request.Context(), authUUID, code); err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Two-factor authentication disabled.", nil)
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(gctx *gin.Context) {
+	h.withCode(gctx, func(authUUID, code string) {
+		codes, err := h.s.RegenerateRecoveryCodes(gctx.Request.Context(), authUUID, code)
+		if err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		c_at.RespAtom[d.RecoveryCodes](gctx, http.StatusOK, "(*) Recovery codes regenerated.", *codes)
+	})
+}
+
+// Verify is the second login step: a challenge token from AuthHandler.Login
+// plus a TOTP or recovery code gets the usual session cookies.
+func (h *MFAHandler) Verify(gctx *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required,max=32"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	authUUID, ok := m.ParseMFAChallenge(req.ChallengeToken)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid or expired challenge.",
+			"Invalid MFA challenge token.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	state, err := h.s.VerifyChallenge(gctx.Request.Context(), authUUID, req.Code)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	session := &sd.Session{
+		AuthUUID:  authUUID,
+		ClientIP:  gctx.ClientIP(),
+		UserAgent: gctx.Request.UserAgent(),
+	}
+	if err := h.ss.Create(gctx.Request.Context(), session); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	claims := &m.Claims{UUID: authUUID, Role: state.Role, SessionUUID: session.SessionUUID}
+	if err := m.SetAuthCookies(gctx, claims, session.RefreshJTI); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Login successful.", nil)
+}
+
+// withCode binds the {"code": ...} body shared by the authenticated MFA
+// endpoints and runs next with the logged auth.
+func (h *MFAHandler) withCode(gctx *gin.Context, next func(authUUID, code string)) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required,max=32"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	next(authUUID, req.Code)
+}
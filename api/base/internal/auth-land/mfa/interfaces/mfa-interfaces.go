@@ -0,0 +1,29 @@
+package interfaces
+
+import (
+	d "aigents-base/internal/auth-land/mfa/domain"
+
+	"context"
+	"time"
+)
+
+type MFAServiceITF interface {
+	Enroll(ctx context.Context, authUUID string) (*d.Enrollment, error)
+	Confirm(ctx context.Context, authUUID, code string) (*d.RecoveryCodes, error)
+	Disable(ctx context.Context, authUUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, authUUID, code string) (*d.RecoveryCodes, error)
+	VerifyChallenge(ctx context.Context, authUUID, code string) (*d.MFAState, error)
+}
+
+type MFARepositoryITF interface {
+	GetState(ctx context.Context, data *d.MFAState) error
+	SetPendingSecret(ctx context.Context, data *d.MFAState) error
+	Enable(ctx context.Context, data *d.MFAState, recoveryHashes []string) error
+	ReplaceRecoveryCodes(ctx context.Context, authUUID string, recoveryHashes []string) error
+	Disable(ctx context.Context, authUUID string) error
+	UseStep(ctx context.Context, authUUID string, step int64) error
+	UseRecoveryCode(ctx context.Context, authUUID, codeHash string) error
+	GetFailures(ctx context.Context, authUUID string, window time.Duration) (int, error)
+	RecordFailure(ctx context.Context, authUUID string, window time.Duration) error
+	ClearFailures(ctx context.Context, authUUID string) error
+}
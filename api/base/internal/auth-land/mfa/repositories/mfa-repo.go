@@ -0,0 +1,280 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/mfa/domain"
+	mfitf "aigents-base/internal/auth-land/mfa/interfaces"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) mfitf.MFARepositoryITF {
+	return &MFARepository{db: db}
+}
+
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *MFARepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
+func (r *MFARepository) GetState(ctx context.Context, data *d.MFAState) error {
+	query := `
+	SELECT email,
+	       role,
+	       COALESCE(totp_secret_enc, ''),
+	       totp_enabled_at IS NOT NULL,
+	       COALESCE(totp_last_step, 0)
+	FROM auths
+	WHERE auth_uuid = $1 AND deleted_at IS NULL;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.AuthUUID).Scan(
+		&data.Email,
+		&data.Role,
+		&data.SecretEnc,
+		&data.Enabled,
+		&data.LastStep,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Authentication not found.", "Auth not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not get two-factor state.",
+			"Failed to get MFA state.", "auth_uuid", data.AuthUUID, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// SetPendingSecret stores a new secret awaiting confirmation. It won't
+// overwrite the secret of an enabled setup (a conflict).
+func (r *MFARepository) SetPendingSecret(ctx context.Context, data *d.MFAState) error {
+	query := `
+	UPDATE auths
+	SET totp_secret_enc = $2, totp_last_step = NULL
+	WHERE auth_uuid = $1 AND deleted_at IS NULL AND totp_enabled_at IS NULL;
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, data.AuthUUID, data.SecretEnc)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not start two-factor enrollment.",
+			"Failed to store pending TOTP secret.", "auth_uuid", data.AuthUUID, "error", err)
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not start two-factor enrollment.",
+			"Failed to read affected rows.", "error", err)
+		return err
+	}
+
+	if affected == 0 {
+		err = errs.New(errs.Conflict, "(R) Two-factor authentication already enabled.", "MFA already enabled.")
+		return err
+	}
+
+	return nil
+}
+
+// Enable turns on the pending secret, recording data.LastStep as used, and
+// stores a fresh set of recovery codes.
+func (r *MFARepository) Enable(ctx context.Context, data *d.MFAState, recoveryHashes []string) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		res, err := r.conn(ctx).ExecContext(ctx,
+			"UPDATE auths SET totp_enabled_at = NOW(), totp_last_step = $2 WHERE auth_uuid = $1 AND totp_enabled_at IS NULL AND totp_secret_enc = $3;",
+			data.AuthUUID,
+			data.LastStep,
+			data.SecretEnc,
+		)
+		if err != nil {
+			return mfaErr("Failed to enable MFA.", "auth_uuid", data.AuthUUID, "error", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return mfaErr("Failed to read affected rows.", "error", err)
+		}
+
+		if affected == 0 {
+			err = errs.New(errs.Conflict, "(R) Two-factor authentication already enabled.", "MFA already enabled.")
+			return err
+		}
+
+		if err = replaceCodes(ctx, r.conn(ctx), data.AuthUUID, recoveryHashes); err != nil {
+			return mfaErr("Failed to store recovery codes.", "auth_uuid", data.AuthUUID, "error", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, authUUID string, recoveryHashes []string) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		if err := replaceCodes(ctx, r.conn(ctx), authUUID, recoveryHashes); err != nil {
+			return mfaErr("Failed to replace recovery codes.", "auth_uuid", authUUID, "error", err)
+		}
+
+		return nil
+	})
+}
+
+func replaceCodes(ctx context.Context, q c_db.Querier, authUUID string, recoveryHashes []string) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE auth_uuid = $1;", authUUID); err != nil {
+		return err
+	}
+
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO mfa_recovery_codes (code_hash, auth_uuid) SELECT unnest($2::TEXT[]), $1;",
+		authUUID,
+		pq.Array(recoveryHashes),
+	)
+
+	return err
+}
+
+func (r *MFARepository) Disable(ctx context.Context, authUUID string) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		_, err := r.conn(ctx).ExecContext(ctx,
+			"UPDATE auths SET totp_secret_enc = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE auth_uuid = $1;",
+			authUUID,
+		)
+		if err == nil {
+			_, err = r.conn(ctx).ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE auth_uuid = $1;", authUUID)
+		}
+
+		if err != nil {
+			return mfaErr("Failed to disable MFA.", "auth_uuid", authUUID, "error", err)
+		}
+
+		return nil
+	})
+}
+
+// UseStep records step as the last accepted TOTP step. Steps at or before
+// the last one were already used (unauthorized).
+func (r *MFARepository) UseStep(ctx context.Context, authUUID string, step int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		"UPDATE auths SET totp_last_step = $2 WHERE auth_uuid = $1 AND COALESCE(totp_last_step, 0) < $2;",
+		authUUID,
+		step,
+	)
+	if err != nil {
+		return mfaErr("Failed to record TOTP step.", "auth_uuid", authUUID, "error", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return mfaErr("Failed to read affected rows.", "error", err)
+	}
+
+	if affected == 0 {
+		err = errs.New(errs.Unauthorized, "(R) Invalid two-factor code.", "TOTP step already used.")
+		return err
+	}
+
+	return nil
+}
+
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, authUUID, codeHash string) error {
+	res, err := r.conn(ctx).ExecContext(ctx,
+		"UPDATE mfa_recovery_codes SET used_at = NOW() WHERE code_hash = $1 AND auth_uuid = $2 AND used_at IS NULL;",
+		codeHash,
+		authUUID,
+	)
+	if err != nil {
+		return mfaErr("Failed to use recovery code.", "auth_uuid", authUUID, "error", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return mfaErr("Failed to read affected rows.", "error", err)
+	}
+
+	if affected == 0 {
+		err = errs.New(errs.Unauthorized, "(R) Invalid two-factor code.", "Recovery code unknown or used.")
+		return err
+	}
+
+	return nil
+}
+
+// GetFailures counts failed second factor attempts of authUUID within window.
+func (r *MFARepository) GetFailures(ctx context.Context, authUUID string, window time.Duration) (int, error) {
+	query := `
+	SELECT CASE WHEN last_failure_at > NOW() - $3 * INTERVAL '1 second' THEN failures ELSE 0 END
+	FROM login_throttles
+	WHERE scope = $1 AND throttle_key = $2;
+	`
+
+	var failures int
+	err := r.conn(ctx).QueryRowContext(ctx, query, d.ThrottleScopeMFA, authUUID, int64(window.Seconds())).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, mfaErr("Failed to get MFA failures.", "auth_uuid", authUUID, "error", err)
+	}
+
+	return failures, nil
+}
+
+func (r *MFARepository) RecordFailure(ctx context.Context, authUUID string, window time.Duration) error {
+	query := `
+	INSERT INTO login_throttles (scope, throttle_key, failures, last_failure_at)
+	VALUES ($1, $2, 1, NOW())
+	ON CONFLICT (scope, throttle_key) DO UPDATE
+	SET failures = CASE
+	        WHEN login_throttles.last_failure_at < NOW() - $3 * INTERVAL '1 second' THEN 1
+	        ELSE login_throttles.failures + 1
+	    END,
+	    last_failure_at = NOW();
+	`
+
+	_, err := r.conn(ctx).ExecContext(ctx, query, d.ThrottleScopeMFA, authUUID, int64(window.Seconds()))
+	if err != nil {
+		return mfaErr("Failed to record MFA failure.", "auth_uuid", authUUID, "error", err)
+	}
+
+	return nil
+}
+
+func (r *MFARepository) ClearFailures(ctx context.Context, authUUID string) error {
+	_, err := r.conn(ctx).ExecContext(ctx,
+		"DELETE FROM login_throttles WHERE scope = $1 AND throttle_key = $2;",
+		d.ThrottleScopeMFA,
+		authUUID,
+	)
+	if err != nil {
+		return mfaErr("Failed to clear MFA failures.", "auth_uuid", authUUID, "error", err)
+	}
+
+	return nil
+}
+
+func mfaErr(logMsg string, attrs ...any) error {
+	return errs.New(
+		errs.Internal,
+		"(R) Could not update two-factor authentication.",
+		logMsg, attrs...)
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	mf_at "aigents-base/internal/auth-land/mfa/atoms"
+	d "aigents-base/internal/auth-land/mfa/domain"
+	mfitf "aigents-base/internal/auth-land/mfa/interfaces"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+type MFAService struct {
+	r           mfitf.MFARepositoryITF
+	key         []byte
+	issuer      string
+	maxFailures int
+	lockout     time.Duration
+}
+
+// NewMFAService builds the service. key is the AES-256 key TOTP secrets are
+// encrypted with; issuer names the account in authenticator apps; after
+// maxFailures wrong codes within lockout further attempts are refused until
+// lockout has passed.
+func NewMFAService(
+	repo mfitf.MFARepositoryITF,
+	key []byte,
+	issuer string,
+	maxFailures int,
+	lockout time.Duration,
+) mfitf.MFAServiceITF {
+	return &MFAService{
+		r:           repo,
+		key:         key,
+		issuer:      issuer,
+		maxFailures: maxFailures,
+		lockout:     lockout,
+	}
+}
+
+// EncryptionKeyFromEnv decodes MFA_ENCRYPTION_KEY, a base64 encoded 32 byte
+// key. Starting without one would leave TOTP secrets unreadable, so it's fatal.
+func EncryptionKeyFromEnv() []byte {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("MFA_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		log.Fatalf("MFA_ENCRYPTION_KEY must be a base64 encoded 32 byte key")
+	}
+
+	return key
+}
+
+// Enroll starts (or restarts) TOTP enrollment with a new pending secret.
+func (s *MFAService) Enroll(ctx context.Context, authUUID string) (*d.Enrollment, error) {
+	state, err := s.state(ctx, authUUID, errs.NotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Enabled {
+		return nil, errAlreadyEnabled(authUUID)
+	}
+
+	secret, err := mf_at.NewTOTPSecretAtom()
+	if err == nil {
+		state.SecretEnc, err = mf_at.EncryptSecretAtom(s.key, secret)
+	}
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(S) Could not start two-factor enrollment.",
+			"Failed to create TOTP secret.", "error", err)
+		return nil, err
+	}
+
+	err = s.r.SetPendingSecret(ctx, state)
+	if err != nil {
+		if errors.Is(err, errs.Conflict) {
+			err = errAlreadyEnabled(authUUID)
+		}
+		return nil, err
+	}
+
+	return &d.Enrollment{
+		Secret:     secret,
+		OTPAuthURI: mf_at.OTPAuthURIAtom(s.issuer, state.Email, secret),
+	}, nil
+}
+
+// Confirm enables the pending secret once code proves the authenticator app
+// has it, and hands out the first recovery codes.
+func (s *MFAService) Confirm(ctx context.Context, authUUID, code string) (*d.RecoveryCodes, error) {
+	state, err := s.state(ctx, authUUID, errs.NotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Enabled {
+		return nil, errAlreadyEnabled(authUUID)
+	}
+
+	if state.SecretEnc == "" {
+		err = errs.New(
+			errs.Conflict,
+			"(S) Start two-factor enrollment first.",
+			"Auth confirmed MFA without enrolling.", "auth_uuid", authUUID)
+		return nil, err
+	}
+
+	secret, err := s.decrypt(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := mf_at.VerifyTOTPAtom(secret, code, time.Now())
+	if !ok {
+		err = errs.New(
+			errs.Validation,
+			"(S) Invalid two-factor code.",
+			"Wrong enrollment code.", "auth_uuid", authUUID)
+		return nil, err
+	}
+
+	codes, hashes, err := s.newRecoveryCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state.LastStep = step
+	err = s.r.Enable(ctx, state, hashes)
+	if err != nil {
+		if errors.Is(err, errs.Conflict) {
+			err = errAlreadyEnabled(authUUID)
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *MFAService) Disable(ctx context.Context, authUUID, code string) error {
+	state, err := s.enabledState(ctx, authUUID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkCode(ctx, state, code); err != nil {
+		return err
+	}
+
+	return s.r.Disable(ctx, authUUID)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, authUUID, code string) (*d.RecoveryCodes, error) {
+	state, err := s.enabledState(ctx, authUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCode(ctx, state, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.newRecoveryCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.r.ReplaceRecoveryCodes(ctx, authUUID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifyChallenge checks the second factor of a login, returning the state
+// (with the current role) to sign the user in with.
+func (s *MFAService) VerifyChallenge(ctx context.Context, authUUID, code string) (*d.MFAState, error) {
+	state, err := s.state(ctx, authUUID, errs.Unauthorized)
+	if err != nil {
+		return nil, err
+	}
+
+	if !state.Enabled {
+		err = errs.New(
+			errs.Unauthorized,
+			"(S) Invalid two-factor challenge.",
+			"MFA challenge for auth without MFA.", "auth_uuid", authUUID)
+		return nil, err
+	}
+
+	if err := s.checkCode(ctx, state, code); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// checkCode accepts a current TOTP code or an unused recovery code, refusing
+// attempts once too many wrong codes were tried.
+func (s *MFAService) checkCode(ctx context.Context, state *d.MFAState, code string) error {
+	failures, err := s.r.GetFailures(ctx, state.AuthUUID, s.lockout)
+	if err != nil {
+		return err
+	}
+
+	if s.maxFailures > 0 && failures >= s.maxFailures {
+		err = errs.New(
+			errs.TooManyRequests,
+			"(S) Too many two-factor attempts. Try again later.",
+			"MFA locked after repeated failures.", "auth_uuid", state.AuthUUID, "failures", failures).WithRetryAfter(s.lockout)
+		return err
+	}
+
+	secret, err := s.decrypt(ctx, state)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := mf_at.VerifyTOTPAtom(secret, code, time.Now()); ok {
+		err = s.r.UseStep(ctx, state.AuthUUID, step)
+	} else {
+		err = s.r.UseRecoveryCode(ctx, state.AuthUUID, mf_at.HashRecoveryCodeAtom(code))
+	}
+
+	if err != nil {
+		if !errors.Is(err, errs.Unauthorized) {
+			return err
+		}
+
+		if err := s.r.RecordFailure(ctx, state.AuthUUID, s.lockout); err != nil {
+			return err
+		}
+
+		err = errs.New(
+			errs.Unauthorized,
+			"(S) Invalid two-factor code.",
+			"Wrong or reused MFA code.", "auth_uuid", state.AuthUUID)
+		return err
+	}
+
+	return s.r.ClearFailures(ctx, state.AuthUUID)
+}
+
+func (s *MFAService) state(ctx context.Context, authUUID string, notFoundKind errs.Kind) (*d.MFAState, error) {
+	state := &d.MFAState{AuthUUID: authUUID}
+
+	err := s.r.GetState(ctx, state)
+	if err != nil {
+		if errors.Is(err, errs.NotFound) {
+			err = errs.New(
+				notFoundKind,
+				"(S) Authentication not found.",
+				"Auth not found.", "auth_uuid", authUUID)
+		}
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (s *MFAService) enabledState(ctx context.Context, authUUID string) (*d.MFAState, error) {
+	state, err := s.state(ctx, authUUID, errs.NotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	if !state.Enabled {
+		err = errs.New(
+			errs.Conflict,
+			"(S) Two-factor authentication is not enabled.",
+			"Auth has no MFA enabled.", "auth_uuid", authUUID)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (s *MFAService) decrypt(ctx context.Context, state *d.MFAState) (string, error) {
+	secret, err := mf_at.DecryptSecretAtom(s.key, state.SecretEnc)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(S) Could not read two-factor secret.",
+			"Failed to decrypt TOTP secret.", "auth_uuid", state.AuthUUID, "error", err)
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (s *MFAService) newRecoveryCodes(ctx context.Context) (*d.RecoveryCodes, []string, error) {
+	codes := &d.RecoveryCodes{Codes: make([]string, 0, d.RecoveryCodeCount)}
+	hashes := make([]string, 0, d.RecoveryCodeCount)
+
+	for range d.RecoveryCodeCount {
+		code, err := mf_at.NewRecoveryCodeAtom()
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(S) Could not create recovery codes.",
+				"Failed to generate recovery code.", "error", err)
+			return nil, nil, err
+		}
+
+		codes.Codes = append(codes.Codes, code)
+		hashes = append(hashes, mf_at.HashRecoveryCodeAtom(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func errAlreadyEnabled(authUUID string) error {
+	return errs.New(
+		errs.Conflict,
+		"(S) Two-factor authentication is already enabled.",
+		"Auth already has MFA enabled.", "auth_uuid", authUUID)
+}
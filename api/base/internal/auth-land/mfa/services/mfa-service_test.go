@@ -0,0 +1,143 @@
+package services
+
+import (
+	mf_at "aigents-base/internal/auth-land/mfa/atoms"
+	d "aigents-base/internal/auth-land/mfa/domain"
+	errs "aigents-base/internal/common/errs"
+
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeMFARepository keeps one enabled authentication in memory, refusing
+// used TOTP steps and recovery codes the way the SQL does.
+type fakeMFARepository struct {
+	state    d.MFAState
+	recovery map[string]bool
+	failures int
+}
+
+func (r *fakeMFARepository) GetState(ctx context.Context, data *d.MFAState) error {
+	*data = r.state
+	return nil
+}
+
+func (r *fakeMFARepository) SetPendingSecret(ctx context.Context, data *d.MFAState) error {
+	return nil
+}
+
+func (r *fakeMFARepository) Enable(ctx context.Context, data *d.MFAState, recoveryHashes []string) error {
+	return nil
+}
+
+func (r *fakeMFARepository) ReplaceRecoveryCodes(ctx context.Context, authUUID string, recoveryHashes []string) error {
+	return nil
+}
+
+func (r *fakeMFARepository) Disable(ctx context.Context, authUUID string) error {
+	return nil
+}
+
+func (r *fakeMFARepository) UseStep(ctx context.Context, authUUID string, step int64) error {
+	if step <= r.state.LastStep {
+		return errs.New(errs.Unauthorized, "(R) Invalid two-factor code.", "TOTP step already used.")
+	}
+
+	r.state.LastStep = step
+	return nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(ctx context.Context, authUUID, codeHash string) error {
+	if !r.recovery[codeHash] {
+		return errs.New(errs.Unauthorized, "(R) Invalid two-factor code.", "Recovery code unknown or used.")
+	}
+
+	r.recovery[codeHash] = false
+	return nil
+}
+
+func (r *fakeMFARepository) GetFailures(ctx context.Context, authUUID string, window time.Duration) (int, error) {
+	return r.failures, nil
+}
+
+func (r *fakeMFARepository) RecordFailure(ctx context.Context, authUUID string, window time.Duration) error {
+	r.failures++
+	return nil
+}
+
+func (r *fakeMFARepository) ClearFailures(ctx context.Context, authUUID string) error {
+	r.failures = 0
+	return nil
+}
+
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newTestMFAService(t *testing.T) (*MFAService, *fakeMFARepository) {
+	t.Helper()
+
+	key := bytes.Repeat([]byte{7}, 32)
+	sealed, err := mf_at.EncryptSecretAtom(key, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &fakeMFARepository{
+		state:    d.MFAState{AuthUUID: "auth-1", SecretEnc: sealed, Enabled: true},
+		recovery: map[string]bool{mf_at.HashRecoveryCodeAtom("abcde-fghij"): true},
+	}
+
+	sv := NewMFAService(repo, key, "Aigents", 5, time.Minute).(*MFAService)
+	return sv, repo
+}
+
+func TestVerifyChallengeRefusesReplayedCode(t *testing.T) {
+	sv, repo := newTestMFAService(t)
+	ctx := context.Background()
+
+	code, err := mf_at.TOTPCodeAtom(testSecret, time.Now().Unix()/30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sv.VerifyChallenge(ctx, "auth-1", code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	if _, err := sv.VerifyChallenge(ctx, "auth-1", code); !errors.Is(err, errs.Unauthorized) {
+		t.Fatalf("replay: error = %v, want Unauthorized", err)
+	}
+
+	if repo.failures != 1 {
+		t.Errorf("failures = %d, want the replay counted", repo.failures)
+	}
+}
+
+func TestVerifyChallengeRecoveryCode(t *testing.T) {
+	sv, _ := newTestMFAService(t)
+	ctx := context.Background()
+
+	if _, err := sv.VerifyChallenge(ctx, "auth-1", " ABCDE-FGHIJ "); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	if _, err := sv.VerifyChallenge(ctx, "auth-1", "abcdefghij"); !errors.Is(err, errs.Unauthorized) {
+		t.Fatalf("reuse: error = %v, want Unauthorized", err)
+	}
+}
+
+func TestVerifyChallengeLocksAfterFailures(t *testing.T) {
+	sv, repo := newTestMFAService(t)
+	repo.failures = 5
+
+	code, err := mf_at.TOTPCodeAtom(testSecret, time.Now().Unix()/30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sv.VerifyChallenge(context.Background(), "auth-1", code); !errors.Is(err, errs.TooManyRequests) {
+		t.Fatalf("error = %v, want TooManyRequests", err)
+	}
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ProviderOIDC   = "oidc"
+	ProviderGitHub = "github"
+)
+
+// ProviderConfig is one identity provider, read from the OIDC_<NAME>_*
+// environment variables. OpenID Connect providers only need Issuer; GitHub
+// speaks plain OAuth2 and is reached through AuthURL, TokenURL and APIURL,
+// which default to github.com.
+type ProviderConfig struct {
+	Name         string
+	Type         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	AuthURL      string
+	TokenURL     string
+	APIURL       string
+}
+
+// Discovery is the subset of the provider metadata document we use.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// FlowClaims travel in the signed oidc_flow cookie between the redirect to
+// the provider and its callback.
+type FlowClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// IDClaims are the ID token claims we rely on.
+type IDClaims struct {
+	Email         string   `json:"email"`
+	EmailVerified FlexBool `json:"email_verified"`
+	Nonce         string   `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// FlexBool accepts both true and "true", since some providers send
+// email_verified as a string.
+type FlexBool bool
+
+func (b *FlexBool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch t := v.(type) {
+	case bool:
+		*b = FlexBool(t)
+	case string:
+		*b = FlexBool(t == "true")
+	default:
+		*b = false
+	}
+
+	return nil
+}
+
+// Identity links a provider subject to an authentication.
+type Identity struct {
+	IdentityUUID  string    `json:"identity_uuid"`
+	AuthUUID      string    `json:"auth_uuid"`
+	Role          string    `json:"-"`
+	Provider      string    `json:"provider"`
+	Subject       string    `json:"subject"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"-"`
+	MFAEnabled    bool      `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastLoginAt   time.Time `json:"last_login_at"`
+}
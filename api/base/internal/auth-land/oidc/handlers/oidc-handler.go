@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	ad "aigents-base/internal/auth-land/auth/domain"
+	d "aigents-base/internal/auth-land/oidc/domain"
+	oiditf "aigents-base/internal/auth-land/oidc/interfaces"
+	sd "aigents-base/internal/auth-land/sessions/domain"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
+	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	flowCookie     = "oidc_flow"
+	flowCookiePath = "/api/v1/auth/oidc"
+	flowAudience   = "oidc_flow"
+	flowTTL        = 10 * time.Minute
+)
+
+type OIDCHandler struct {
+	s      oiditf.OIDCServiceITF
+	ss     ssitf.SessionServiceITF
+	appURL string
+}
+
+// NewOIDCHandler builds the handler; after a successful callback the browser
+// is sent to appURL, or gets a JSON answer when it is empty.
+func NewOIDCHandler(sv oiditf.OIDCServiceITF, sessionSv ssitf.SessionServiceITF, appURL string) *OIDCHandler {
+	return &OIDCHandler{s: sv, ss: sessionSv, appURL: appURL}
+}
+
+// Start redirects to the provider, keeping state, nonce and PKCE verifier in
+// a short lived signed cookie.
+func (h *OIDCHandler) Start(gctx *gin.Context) {
+	authURL, flow, err := h.s.Start(gctx.Request.Context(), gctx.Param("provider"))
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	now := time.Now()
+	flow.Audience = jwt.ClaimStrings{flowAudience}
+	flow.IssuedAt = jwt.NewNumericDate(now)
+	flow.ExpiresAt = jwt.NewNumericDate(now.Add(flowTTL))
+
+	signed, err := m.SignToken(flow)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(H) Could not start provider login.",
+			"Could not sign flow cookie.", "error", err)
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	gctx.SetSameSite(http.SameSiteLaxMode)
+	gctx.SetCookie(flowCookie, signed, int(flowTTL.Seconds()), flowCookiePath, "", false, true)
+	gctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes the flow and signs the user in with the same cookies as
+// AuthHandler.Login. Accounts with two-factor enabled get the same MFA
+// challenge as a password login instead, handed to the app in the URL
+// fragment so it stays out of server logs and Referer headers.
+func (h *OIDCHandler) Callback(gctx *gin.Context) {
+	provider := gctx.Param("provider")
+
+	flowStr, cookieErr := gctx.Cookie(flowCookie)
+	gctx.SetCookie(flowCookie, "", -1, flowCookiePath, "", false, true)
+
+	if providerErr := gctx.Query("error"); providerErr != "" {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Provider login was not completed.",
+			"Provider answered error.", "provider", provider, "provider_error", providerErr)
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	flow := &d.FlowClaims{}
+	valid := cookieErr == nil && m.ParseToken(flowStr, flow, flowAudience)
+
+	state := gctx.Query("state")
+	code := gctx.Query("code")
+	if !valid || flow.Provider != provider || code == "" ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(state)) != 1 {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid or expired login flow.",
+			"Rejected callback: missing flow, state mismatch or no code.", "provider", provider)
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	identity, err := h.s.Callback(gctx.Request.Context(), flow, code)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if identity.MFAEnabled {
+		challenge, err := m.GenerateMFAChallenge(gctx, identity.AuthUUID)
+		if err != nil {
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		if h.appURL != "" {
+			fragment := url.Values{"mfa_required": {"true"}, "challenge_token": {challenge}}
+			gctx.Redirect(http.StatusFound, h.appURL+"#"+fragment.Encode())
+			return
+		}
+
+		c_at.RespAtom[ad.LoginChallenge](gctx, http.StatusOK, "(*) Two-factor code required.",
+			ad.LoginChallenge{MFARequired: true, ChallengeToken: challenge})
+		return
+	}
+
+	session := &sd.Session{
+		AuthUUID:  identity.AuthUUID,
+		ClientIP:  gctx.ClientIP(),
+		UserAgent: gctx.Request.UserAgent(),
+	}
+	if err := h.ss.Create(gctx.Request.Context(), session); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	claims := &m.Claims{UUID: identity.AuthUUID, Role: identity.Role, SessionUUID: session.SessionUUID}
+	if err := m.SetAuthCookies(gctx, claims, session.RefreshJTI); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if h.appURL != "" {
+		gctx.Redirect(http.StatusFound, h.appURL)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Login successful.", nil)
+}
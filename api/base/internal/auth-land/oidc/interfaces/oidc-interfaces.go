@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	d "aigents-base/internal/auth-land/oidc/domain"
+
+	"context"
+)
+
+type OIDCServiceITF interface {
+	Start(ctx context.Context, provider string) (string, *d.FlowClaims, error)
+	Callback(ctx context.Context, flow *d.FlowClaims, code string) (*d.Identity, error)
+}
+
+// ProviderITF is one external identity provider: where to send the browser
+// and how to turn the code it comes back with into verified claims.
+type ProviderITF interface {
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Identify(ctx context.Context, code string, flow *d.FlowClaims) (*d.IDClaims, error)
+}
+
+type OIDCRepositoryITF interface {
+	LinkIdentity(ctx context.Context, data *d.Identity) error
+}
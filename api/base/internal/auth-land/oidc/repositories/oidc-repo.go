@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/oidc/domain"
+	oiditf "aigents-base/internal/auth-land/oidc/interfaces"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+)
+
+type OIDCRepository struct {
+	db *sql.DB
+}
+
+func NewOIDCRepository(db *sql.DB) oiditf.OIDCRepositoryITF {
+	return &OIDCRepository{db: db}
+}
+
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *OIDCRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
+// LinkIdentity resolves the authentication behind a provider login, filling
+// data.AuthUUID, data.Role and data.MFAEnabled. A known provider subject logs into its linked
+// account; otherwise a verified email links to the live account holding it,
+// compared without case, or creates one. Logins whose email the provider
+// didn't verify only work for subjects that are already linked.
+//
+// An account whose email was never verified may have been registered by
+// someone else ahead of its owner, so linking it wipes whatever that person
+// could have set up: password, two-factor, sessions, API keys and pending
+// tokens.
+//
+// Concurrent first logins of the same email or subject race on the inserts;
+// the one that loses picks up the row the other wrote instead of failing.
+func (r *OIDCRepository) LinkIdentity(ctx context.Context, data *d.Identity) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		known, err := r.touchIdentity(ctx, data)
+		if err != nil || known {
+			return err
+		}
+
+		if !data.EmailVerified || data.Email == "" {
+			return errs.New(errs.Conflict, "(R) Email not verified.", "Email not verified.")
+		}
+
+		found, err := r.authByEmail(ctx, data)
+		if err == nil && !found {
+			found, err = r.createAuth(ctx, data)
+		}
+		if err == nil && !found {
+			found, err = r.authByEmail(ctx, data)
+		}
+		if err != nil {
+			return err
+		}
+		if !found {
+			return linkErr("Failed to resolve authentication by email.", "email", data.Email)
+		}
+
+		linkSQL := `
+		INSERT INTO auth_identities (auth_uuid, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, subject) DO NOTHING
+		RETURNING identity_uuid, created_at, last_login_at;
+		`
+
+		err = r.conn(ctx).QueryRowContext(ctx, linkSQL, data.AuthUUID, data.Provider, data.Subject, data.Email).Scan(
+			&data.IdentityUUID,
+			&data.CreatedAt,
+			&data.LastLoginAt,
+		)
+
+		switch {
+		case err == sql.ErrNoRows:
+			if known, err = r.touchIdentity(ctx, data); err == nil && !known {
+				err = linkErr("Failed to resolve identity.", "provider", data.Provider)
+			}
+			return err
+		case err != nil:
+			return linkErr("Failed to link identity.", "error", err)
+		}
+
+		return nil
+	})
+}
+
+// touchIdentity logs into the account linked to data's provider subject,
+// reporting false when the subject isn't linked yet.
+func (r *OIDCRepository) touchIdentity(ctx context.Context, data *d.Identity) (bool, error) {
+	var deleted bool
+
+	knownSQL := `
+	SELECT i.identity_uuid, i.auth_uuid, a.role, a.deleted_at IS NOT NULL, a.totp_enabled_at IS NOT NULL
+	FROM auth_identities i
+	INNER JOIN auths a ON i.auth_uuid = a.auth_uuid
+	WHERE i.provider = $1 AND i.subject = $2;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, knownSQL, data.Provider, data.Subject).Scan(
+		&data.IdentityUUID,
+		&data.AuthUUID,
+		&data.Role,
+		&deleted,
+		&data.MFAEnabled,
+	)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, linkErr("Failed to look up identity.", "error", err)
+	case deleted:
+		return false, errs.New(errs.Forbidden, "(R) Authentication deleted.", "Auth deleted.")
+	}
+
+	err = r.conn(ctx).QueryRowContext(ctx,
+		"UPDATE auth_identities SET email = $2, last_login_at = NOW() WHERE identity_uuid = $1 RETURNING created_at, last_login_at;",
+		data.IdentityUUID,
+		data.Email,
+	).Scan(&data.CreatedAt, &data.LastLoginAt)
+	if err != nil {
+		return false, linkErr("Failed to touch identity.", "error", err)
+	}
+
+	return true, nil
+}
+
+// authByEmail locks the live account holding data.Email, reporting false when
+// there is none. An account whose email was never verified is reset first.
+func (r *OIDCRepository) authByEmail(ctx context.Context, data *d.Identity) (bool, error) {
+	var verified bool
+
+	authSQL := `
+	SELECT auth_uuid, role, totp_enabled_at IS NOT NULL, email_verified_at IS NOT NULL
+	FROM auths
+	WHERE lower(email) = lower($1) AND deleted_at IS NULL
+	FOR UPDATE;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, authSQL, data.Email).Scan(&data.AuthUUID, &data.Role, &data.MFAEnabled, &verified)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, linkErr("Failed to look up authentication.", "error", err)
+	}
+
+	if !verified {
+		if err = resetUnverified(ctx, r.conn(ctx), data.AuthUUID); err != nil {
+			return false, err
+		}
+		data.MFAEnabled = false
+	}
+
+	return true, nil
+}
+
+// createAuth creates the account for data.Email, reporting false when a
+// concurrent registration of the same email got there first. Accounts
+// created here have no usable password until the user sets one through the
+// reset flow.
+func (r *OIDCRepository) createAuth(ctx context.Context, data *d.Identity) (bool, error) {
+	createSQL := `
+	INSERT INTO auths (email, password, email_verified_at)
+	VALUES ($1, '', NOW())
+	ON CONFLICT (lower(email)) WHERE deleted_at IS NULL DO NOTHING
+	RETURNING auth_uuid, role;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, createSQL, data.Email).Scan(&data.AuthUUID, &data.Role)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, linkErr("Failed to create authentication.", "error", err)
+	}
+
+	data.MFAEnabled = false
+	return true, nil
+}
+
+// resetUnverified marks the email of authUUID as verified by the provider
+// and drops every credential set up before that.
+func resetUnverified(ctx context.Context, q c_db.Querier, authUUID string) error {
+	statements := []string{
+		`UPDATE auths
+		 SET password = '', email_verified_at = NOW(), pending_email = NULL,
+		     totp_secret_enc = NULL, totp_enabled_at = NULL, totp_last_step = NULL
+		 WHERE auth_uuid = $1;`,
+		"DELETE FROM mfa_recovery_codes WHERE auth_uuid = $1;",
+		"UPDATE auth_sessions SET revoked_at = NOW() WHERE auth_uuid = $1 AND revoked_at IS NULL;",
+		"UPDATE api_keys SET revoked_at = NOW() WHERE auth_uuid = $1 AND revoked_at IS NULL;",
+		"UPDATE auth_tokens SET used_at = NOW() WHERE auth_uuid = $1 AND used_at IS NULL;",
+	}
+
+	for _, stmt := range statements {
+		if _, err := q.ExecContext(ctx, stmt, authUUID); err != nil {
+			return linkErr("Failed to reset unverified authentication.", "auth_uuid", authUUID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func linkErr(logMsg string, attrs ...any) error {
+	return errs.New(
+		errs.Internal,
+		"(R) Could not sign in with provider.",
+		logMsg, attrs...)
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/oidc/domain"
+	c_db "aigents-base/internal/common/db"
+
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// openTestDB connects to the database in TEST_DB_URL and migrates it; these
+// tests run real SQL, so they are skipped when it isn't set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := c_db.NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return conn
+}
+
+func login(email string) *d.Identity {
+	return &d.Identity{Provider: d.ProviderOIDC, Subject: uuid.NewString(), Email: email, EmailVerified: true}
+}
+
+func TestLinkIdentityEmailIgnoresCase(t *testing.T) {
+	conn := openTestDB(t)
+	r := &OIDCRepository{db: conn}
+	ctx := context.Background()
+
+	email := uuid.NewString() + "@example.com"
+
+	var authUUID string
+	err := conn.QueryRow(
+		"INSERT INTO auths (email, password, email_verified_at) VALUES ($1, 'x', NOW()) RETURNING auth_uuid;",
+		email,
+	).Scan(&authUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := login(strings.ToUpper(email))
+	if err := r.LinkIdentity(ctx, data); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+
+	if data.AuthUUID != authUUID {
+		t.Errorf("linked to %s, want the existing account %s", data.AuthUUID, authUUID)
+	}
+}
+
+func TestLinkIdentitySkipsDeletedAccount(t *testing.T) {
+	conn := openTestDB(t)
+	r := &OIDCRepository{db: conn}
+	ctx := context.Background()
+
+	email := uuid.NewString() + "@example.com"
+
+	var deletedUUID string
+	err := conn.QueryRow(
+		"INSERT INTO auths (email, password, email_verified_at, deleted_at) VALUES ($1, 'x', NOW(), NOW()) RETURNING auth_uuid;",
+		email,
+	).Scan(&deletedUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the email was freed by the deletion, so the login gets a new account
+	data := login(email)
+	if err := r.LinkIdentity(ctx, data); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+
+	if data.AuthUUID == "" || data.AuthUUID == deletedUUID {
+		t.Errorf("linked to %q, want a new account", data.AuthUUID)
+	}
+}
+
+func TestLinkIdentityConcurrentFirstLogins(t *testing.T) {
+	conn := openTestDB(t)
+	r := &OIDCRepository{db: conn}
+	ctx := context.Background()
+
+	first := login(uuid.NewString() + "@example.com")
+
+	const logins = 8
+	results := make([]*d.Identity, logins)
+	failures := make([]error, logins)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := *first
+			results[i] = &data
+			failures[i] = r.LinkIdentity(ctx, &data)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, data := range results {
+		if failures[i] != nil {
+			t.Fatalf("login %d: %v", i, failures[i])
+		}
+
+		if data.AuthUUID != results[0].AuthUUID || data.IdentityUUID != results[0].IdentityUUID {
+			t.Errorf("login %d linked %s/%s, login 0 linked %s/%s",
+				i, data.AuthUUID, data.IdentityUUID, results[0].AuthUUID, results[0].IdentityUUID)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	o_at "aigents-base/internal/auth-land/oidc/atoms"
+	d "aigents-base/internal/auth-land/oidc/domain"
+
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitHubProvider signs in through GitHub, which has no OpenID Connect
+// support: the code is redeemed for an access token and the account is read
+// from the REST API. Without an ID token there is no nonce; state and PKCE
+// bind the callback to its flow.
+type GitHubProvider struct {
+	cfg    d.ProviderConfig
+	client *http.Client
+}
+
+func NewGitHubProvider(cfg d.ProviderConfig) *GitHubProvider {
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = "https://github.com/login/oauth/authorize"
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = "https://github.com/login/oauth/access_token"
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+
+	return &GitHubProvider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {o_at.CodeChallengeAtom(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	return p.cfg.AuthURL + "?" + params.Encode(), nil
+}
+
+// Identify redeems code and reads the account id and its primary email,
+// which counts as verified only if GitHub says so.
+func (p *GitHubProvider) Identify(ctx context.Context, code string, flow *d.FlowClaims) (*d.IDClaims, error) {
+	token, err := redeemCode(ctx, p.client, p.cfg, p.cfg.TokenURL, code, flow.Verifier)
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := getJSON(ctx, p.client, p.cfg.APIURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+
+	if user.ID == 0 {
+		return nil, fmt.Errorf("user response has no id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.client, p.cfg.APIURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	claims := &d.IDClaims{}
+	claims.Subject = strconv.FormatInt(user.ID, 10)
+
+	for _, e := range emails {
+		if e.Primary {
+			claims.Email = e.Email
+			claims.EmailVerified = d.FlexBool(e.Verified)
+			break
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	o_at "aigents-base/internal/auth-land/oidc/atoms"
+	d "aigents-base/internal/auth-land/oidc/domain"
+	oiditf "aigents-base/internal/auth-land/oidc/interfaces"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval limits how often an unknown kid can make us refetch
+// the provider keys.
+const jwksRefreshInterval = time.Minute
+
+// Provider talks to one OpenID Connect provider. Its discovery document and
+// keys are fetched lazily and cached.
+type Provider struct {
+	cfg    d.ProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *d.Discovery
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg d.ProviderConfig) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// ProvidersFromEnv reads the providers listed in OIDC_PROVIDERS, each one
+// configured by OIDC_<NAME>_CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and the
+// optional space separated _SCOPES. _TYPE is "oidc", the default, which
+// needs _ISSUER, or "github", whose endpoints _AUTH_URL, _TOKEN_URL and
+// _API_URL only need setting for GitHub Enterprise.
+func ProvidersFromEnv() map[string]oiditf.ProviderITF {
+	providers := map[string]oiditf.ProviderITF{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := d.ProviderConfig{
+			Name:         name,
+			Type:         strings.ToLower(os.Getenv(prefix + "TYPE")),
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			AuthURL:      os.Getenv(prefix + "AUTH_URL"),
+			TokenURL:     os.Getenv(prefix + "TOKEN_URL"),
+			APIURL:       strings.TrimSuffix(os.Getenv(prefix+"API_URL"), "/"),
+		}
+
+		if cfg.ClientID == "" || cfg.RedirectURL == "" {
+			log.Fatalf("OIDC provider %s needs %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix)
+		}
+
+		switch cfg.Type {
+		case "", d.ProviderOIDC:
+			if cfg.Issuer == "" {
+				log.Fatalf("OIDC provider %s needs %sISSUER", name, prefix)
+			}
+			providers[name] = NewProvider(cfg)
+		case d.ProviderGitHub:
+			providers[name] = NewGitHubProvider(cfg)
+		default:
+			log.Fatalf("OIDC provider %s has unknown %sTYPE %q", name, prefix, cfg.Type)
+		}
+	}
+
+	return providers
+}
+
+func (p *Provider) discover(ctx context.Context) (*d.Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	doc := &d.Discovery{}
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", doc); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(doc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+	}
+
+	p.discovery = doc
+	return doc, nil
+}
+
+// AuthCodeURL is where to send the browser to start an authorization code
+// flow bound to state, nonce and the PKCE verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {o_at.CodeChallengeAtom(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return doc.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Identify redeems code and verifies the ID token it is answered with.
+func (p *Provider) Identify(ctx context.Context, code string, flow *d.FlowClaims) (*d.IDClaims, error) {
+	rawToken, err := p.Exchange(ctx, code, flow.Verifier)
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+
+	claims, err := p.VerifyIDToken(ctx, rawToken, flow.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("verify id token: %w", err)
+	}
+
+	return claims, nil
+}
+
+// Exchange trades an authorization code for the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := redeemCode(ctx, p.client, p.cfg, doc.TokenEndpoint, code, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*d.IDClaims, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &d.IDClaims{}
+	_, err = jwt.ParseWithClaims(
+		rawToken,
+		claims,
+		func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+			return p.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("id token has no sub")
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	if p.discovery == nil {
+		return nil, fmt.Errorf("provider not discovered")
+	}
+
+	set := &d.JWKSet{}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := o_at.PublicKeyAtom(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookupKey finds kid among the cached keys; tokens without a kid are
+// accepted only while the provider publishes a single key.
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, out any) error {
+	return getJSON(ctx, p.client, target, "", out)
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// redeemCode runs the authorization code grant against tokenURL.
+func redeemCode(ctx context.Context, client *http.Client, cfg d.ProviderConfig, tokenURL, code, verifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read token response: %w", err)
+	}
+
+	body := &tokenResponse{}
+	if err := json.Unmarshal(raw, body); err != nil {
+		return nil, fmt.Errorf("decode token response (status %d): %w", resp.StatusCode, err)
+	}
+
+	// GitHub reports errors with a 200
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("token endpoint answered %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+
+	return body, nil
+}
+
+// getJSON fetches target into out, authenticating with accessToken when set.
+func getJSON(ctx context.Context, client *http.Client, target, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s answered %d", target, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("decode %s: %w", target, err)
+	}
+
+	return nil
+}
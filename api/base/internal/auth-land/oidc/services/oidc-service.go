@@ -0,0 +1,108 @@
+package services
+
+import (
+	o_at "aigents-base/internal/auth-land/oidc/atoms"
+	d "aigents-base/internal/auth-land/oidc/domain"
+	oiditf "aigents-base/internal/auth-land/oidc/interfaces"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+)
+
+type OIDCService struct {
+	r         oiditf.OIDCRepositoryITF
+	providers map[string]oiditf.ProviderITF
+}
+
+func NewOIDCService(repo oiditf.OIDCRepositoryITF, providers map[string]oiditf.ProviderITF) oiditf.OIDCServiceITF {
+	return &OIDCService{r: repo, providers: providers}
+}
+
+// Start opens an authorization code flow with provider, returning the URL to
+// redirect to and the flow values the callback must be checked against.
+func (s *OIDCService) Start(ctx context.Context, provider string) (string, *d.FlowClaims, error) {
+	p, err := s.provider(ctx, provider)
+	if err != nil {
+		return "", nil, err
+	}
+
+	flow := &d.FlowClaims{Provider: provider}
+	for _, v := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *v, err = o_at.RandomStringAtom(32); err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(S) Could not start provider login.",
+				"Failed to generate flow values.", "error", err)
+			return "", nil, err
+		}
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		err = errs.New(
+			errs.Unavailable,
+			"(S) Provider unavailable.",
+			"Provider discovery failed.", "provider", provider, "error", err)
+		return "", nil, err
+	}
+
+	return authURL, flow, nil
+}
+
+// Callback redeems code for the flow and resolves the authentication behind
+// the provider identity.
+func (s *OIDCService) Callback(ctx context.Context, flow *d.FlowClaims, code string) (*d.Identity, error) {
+	p, err := s.provider(ctx, flow.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.Identify(ctx, code, flow)
+	if err != nil {
+		err = errs.New(
+			errs.Unauthorized,
+			"(S) Could not sign in with provider.",
+			"Provider login rejected.", "provider", flow.Provider, "error", err)
+		return nil, err
+	}
+
+	identity := &d.Identity{
+		Provider:      flow.Provider,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+	}
+
+	err = s.r.LinkIdentity(ctx, identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.Conflict):
+			err = errs.New(
+				errs.Forbidden,
+				"(S) Provider email is not verified.",
+				"Unverified email from provider.", "email", identity.Email, "provider", flow.Provider, "subject", identity.Subject)
+		case errors.Is(err, errs.Forbidden):
+			err = errs.New(
+				errs.Forbidden,
+				"(S) Authentication was deleted.",
+				"Provider subject maps to a deleted authentication.", "provider", flow.Provider, "subject", identity.Subject)
+		}
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+func (s *OIDCService) provider(ctx context.Context, name string) (oiditf.ProviderITF, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		err := errs.New(
+			errs.NotFound,
+			"(S) Unknown provider.",
+			"OIDC provider is not configured.", "provider", name)
+		return nil, err
+	}
+
+	return p, nil
+}
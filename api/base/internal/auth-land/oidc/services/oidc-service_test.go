@@ -0,0 +1,352 @@
+package services
+
+import (
+	o_at "aigents-base/internal/auth-land/oidc/atoms"
+	d "aigents-base/internal/auth-land/oidc/domain"
+	oiditf "aigents-base/internal/auth-land/oidc/interfaces"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// standInIdP is a minimal OpenID Connect provider: it remembers the nonce
+// and PKCE challenge of each authorization request and only redeems a code
+// for the matching verifier.
+type standInIdP struct {
+	t      *testing.T
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	issuer string
+
+	mu      sync.Mutex
+	pending map[string]url.Values
+	// tamper lets a test alter the ID token claims before signing
+	tamper func(claims jwt.MapClaims)
+}
+
+func newStandInIdP(t *testing.T) *standInIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &standInIdP{t: t, key: key, pending: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(d.Discovery{
+			Issuer:                idp.issuer,
+			AuthorizationEndpoint: idp.issuer + "/authorize",
+			TokenEndpoint:         idp.issuer + "/token",
+			JWKSURI:               idp.issuer + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(d.JWKSet{Keys: []d.JWK{{
+			Kty: "RSA",
+			Kid: "test",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.srv = httptest.NewServer(mux)
+	idp.issuer = idp.srv.URL
+	t.Cleanup(idp.srv.Close)
+
+	return idp
+}
+
+// authorize stands in for the user approving the login: it records the
+// request behind authURL and returns the code to call back with.
+func (idp *standInIdP) authorize(authURL string) (code string) {
+	idp.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	code = "code-" + u.Query().Get("state")[:8]
+
+	idp.mu.Lock()
+	idp.pending[code] = u.Query()
+	idp.mu.Unlock()
+
+	return code
+}
+
+func (idp *standInIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	idp.mu.Lock()
+	req, ok := idp.pending[r.Form.Get("code")]
+	delete(idp.pending, r.Form.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || o_at.CodeChallengeAtom(r.Form.Get("code_verifier")) != req.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            idp.issuer,
+		"aud":            req.Get("client_id"),
+		"sub":            "subject-1",
+		"email":          "user@example.com",
+		"email_verified": "true",
+		"nonce":          req.Get("nonce"),
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+	if idp.tamper != nil {
+		idp.tamper(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+// fakeOIDCRepository records the identities handed to LinkIdentity.
+type fakeOIDCRepository struct {
+	linked []d.Identity
+	err    error
+}
+
+func (r *fakeOIDCRepository) LinkIdentity(ctx context.Context, data *d.Identity) error {
+	r.linked = append(r.linked, *data)
+	if r.err != nil {
+		return r.err
+	}
+
+	data.AuthUUID = "auth-1"
+	return nil
+}
+
+func newTestService(idp *standInIdP, repo *fakeOIDCRepository) oiditf.OIDCServiceITF {
+	providers := map[string]oiditf.ProviderITF{
+		"idp": NewProvider(d.ProviderConfig{
+			Name:        "idp",
+			Issuer:      idp.issuer,
+			ClientID:    "client-1",
+			RedirectURL: "http://localhost/callback",
+		}),
+	}
+
+	return NewOIDCService(repo, providers)
+}
+
+func TestOIDCCallbackLinksVerifiedIdentity(t *testing.T) {
+	idp := newStandInIdP(t)
+	repo := &fakeOIDCRepository{}
+	sv := newTestService(idp, repo)
+	ctx := context.Background()
+
+	authURL, flow, err := sv.Start(ctx, "idp")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	q, _ := url.Parse(authURL)
+	if got := q.Query().Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	if q.Query().Get("state") != flow.State || q.Query().Get("nonce") != flow.Nonce {
+		t.Errorf("auth URL does not carry the flow state and nonce")
+	}
+
+	identity, err := sv.Callback(ctx, flow, idp.authorize(authURL))
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	if identity.AuthUUID != "auth-1" {
+		t.Errorf("AuthUUID = %q, want auth-1", identity.AuthUUID)
+	}
+
+	if len(repo.linked) != 1 {
+		t.Fatalf("LinkIdentity called %d times, want 1", len(repo.linked))
+	}
+
+	got := repo.linked[0]
+	if got.Provider != "idp" || got.Subject != "subject-1" || got.Email != "user@example.com" || !got.EmailVerified {
+		t.Errorf("linked identity = %+v", got)
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(claims jwt.MapClaims)
+		flow   func(flow *d.FlowClaims)
+	}{
+		{
+			name: "wrong PKCE verifier",
+			flow: func(flow *d.FlowClaims) { flow.Verifier = "not-the-verifier" },
+		},
+		{
+			name:   "nonce mismatch",
+			tamper: func(claims jwt.MapClaims) { claims["nonce"] = "replayed" },
+		},
+		{
+			name:   "other audience",
+			tamper: func(claims jwt.MapClaims) { claims["aud"] = "client-2" },
+		},
+		{
+			name:   "other issuer",
+			tamper: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example" },
+		},
+		{
+			name:   "expired token",
+			tamper: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newStandInIdP(t)
+			idp.tamper = tt.tamper
+			repo := &fakeOIDCRepository{}
+			sv := newTestService(idp, repo)
+			ctx := context.Background()
+
+			authURL, flow, err := sv.Start(ctx, "idp")
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			code := idp.authorize(authURL)
+			if tt.flow != nil {
+				tt.flow(flow)
+			}
+
+			_, err = sv.Callback(ctx, flow, code)
+			if !errors.Is(err, errs.Unauthorized) {
+				t.Errorf("Callback error = %v, want Unauthorized", err)
+			}
+
+			if len(repo.linked) != 0 {
+				t.Errorf("rejected login reached LinkIdentity")
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackUnverifiedEmail(t *testing.T) {
+	idp := newStandInIdP(t)
+	idp.tamper = func(claims jwt.MapClaims) { claims["email_verified"] = false }
+	repo := &fakeOIDCRepository{err: errs.New(errs.Conflict, "(R) Email not verified.", "Email not verified.")}
+	sv := newTestService(idp, repo)
+	ctx := context.Background()
+
+	authURL, flow, err := sv.Start(ctx, "idp")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	_, err = sv.Callback(ctx, flow, idp.authorize(authURL))
+	if !errors.Is(err, errs.Forbidden) {
+		t.Errorf("Callback error = %v, want Forbidden", err)
+	}
+
+	if len(repo.linked) != 1 || repo.linked[0].EmailVerified {
+		t.Errorf("linked identity = %+v, want one unverified", repo.linked)
+	}
+}
+
+func TestOIDCUnknownProvider(t *testing.T) {
+	sv := NewOIDCService(&fakeOIDCRepository{}, map[string]oiditf.ProviderITF{})
+
+	if _, _, err := sv.Start(context.Background(), "nope"); !errors.Is(err, errs.NotFound) {
+		t.Errorf("Start error = %v, want NotFound", err)
+	}
+}
+
+func TestGitHubIdentify(t *testing.T) {
+	const verifier = "verifier-1"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "code-1" || r.Form.Get("code_verifier") != verifier {
+			// GitHub answers grant errors with a 200
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token-1", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": 42, "login": "octocat", "email": nil})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p := NewGitHubProvider(d.ProviderConfig{
+		Name:        "github",
+		Type:        d.ProviderGitHub,
+		ClientID:    "client-1",
+		RedirectURL: "http://localhost/callback",
+		AuthURL:     srv.URL + "/login/oauth/authorize",
+		TokenURL:    srv.URL + "/login/oauth/access_token",
+		APIURL:      srv.URL,
+	})
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", "", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, _ := url.Parse(authURL)
+	if got := u.Query().Get("code_challenge"); got != o_at.CodeChallengeAtom(verifier) {
+		t.Errorf("code_challenge = %q, want the S256 of the verifier", got)
+	}
+
+	claims, err := p.Identify(context.Background(), "code-1", &d.FlowClaims{Verifier: verifier})
+	if err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+
+	if claims.Subject != "42" || claims.Email != "octocat@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := p.Identify(context.Background(), "code-1", &d.FlowClaims{Verifier: "wrong"}); err == nil {
+		t.Errorf("Identify accepted a wrong verifier")
+	}
+}
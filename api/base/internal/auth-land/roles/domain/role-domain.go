@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	RoleUser    = "USER"
+	RoleCreator = "CREATOR"
+	RoleAdmin   = "ADMIN"
+)
+
+const (
+	CreatorRequestPending  = "PENDING"
+	CreatorRequestApproved = "APPROVED"
+	CreatorRequestRejected = "REJECTED"
+)
+
+// RoleChange is an admin assigning Role to the authentication AuthUUID.
+type RoleChange struct {
+	AuthUUID  string `json:"auth_uuid"`
+	Role      string `json:"role"`
+	ChangedBy string `json:"-"`
+}
+
+// CreatorRequest is a USER asking to be promoted to CREATOR so they can
+// publish agents.
+type CreatorRequest struct {
+	RequestUUID string    `json:"request_uuid"`
+	AuthUUID    string    `json:"auth_uuid"`
+	Email       string    `json:"email,omitempty"`
+	Message     string    `json:"message"`
+	Status      string    `json:"status"`
+	ReviewedBy  string    `json:"reviewed_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ReviewedAt  time.Time `json:"reviewed_at"`
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	d "aigents-base/internal/auth-land/roles/domain"
+	rlitf "aigents-base/internal/auth-land/roles/interfaces"
+	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RoleHandler struct {
+	s rlitf.RoleServiceITF
+}
+
+func NewRoleHandler(sv rlitf.RoleServiceITF) *RoleHandler {
+	return &RoleHandler{s: sv}
+}
+
+// Create lets the logged USER ask to become a creator.
+func (h *RoleHandler) Create(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		Message string `json:"message" binding:"max=1000"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	request := &d.CreatorRequest{AuthUUID: authUUID, Message: req.Message}
+	if err := h.s.Create(gctx.Request.Context(), request); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.CreatorRequest](gctx, http.StatusCreated, "(*) Creator request sent.", *request)
+}
+
+// Delete withdraws a pending creator request of the logged auth.
+func (h *RoleHandler) Delete(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	requestUUID, ok := uuidFromParam(gctx, "request_uuid")
+	if !ok {
+		return
+	}
+
+	err := h.s.Delete(gctx.Request.Context(), &d.CreatorRequest{RequestUUID: requestUUID, AuthUUID: authUUID})
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Creator request withdrawn.", nil)
+}
+
+func (h *RoleHandler) Fetch(gctx *gin.Context) {
+	var req struct {
+		Status   string `form:"status" binding:"omitempty,oneof=PENDING APPROVED REJECTED"`
+		Page     uint64 `form:"page" binding:"omitempty,max=10000"`
+		PageSize uint64 `form:"page_size" binding:"omitempty,min=1,max=100"`
+	}
+
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid query parameters.",
+			"Invalid query parameters.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	requests, err := h.s.FetchByStatus(gctx.Request.Context(), req.Status, req.PageSize, req.Page*req.PageSize)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[[]d.CreatorRequest](gctx, http.StatusOK, "(*) Data retrivied", requests)
+}
+
+func (h *RoleHandler) Approve(gctx *gin.Context) {
+	h.review(gctx, d.CreatorRequestApproved)
+}
+
+func (h *RoleHandler) Reject(gctx *gin.Context) {
+	h.review(gctx, d.CreatorRequestRejected)
+}
+
+func (h *RoleHandler) review(gctx *gin.Context, status string) {
+	adminUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	requestUUID, ok := uuidFromParam(gctx, "request_uuid")
+	if !ok {
+		return
+	}
+
+	request := &d.CreatorRequest{RequestUUID: requestUUID, Status: status, ReviewedBy: adminUUID}
+	if err := h.s.Update(gctx.Request.Context(), request); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.CreatorRequest](gctx, http.StatusOK, "(*) Creator request reviewed.", *request)
+}
+
+// UpdateRole lets an admin set the role of any other authentication. Its
+// sessions are revoked, so the user signs in again under the new role.
+func (h *RoleHandler) UpdateRole(gctx *gin.Context) {
+	adminUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	authUUID, ok := uuidFromParam(gctx, "auth_uuid")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required,oneof=USER CREATOR ADMIN"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	change := &d.RoleChange{AuthUUID: authUUID, Role: req.Role, ChangedBy: adminUUID}
+	if err := h.s.UpdateRole(gctx.Request.Context(), change); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.RoleChange](gctx, http.StatusOK, "(*) Role updated.", *change)
+}
+
+func uuidFromParam(gctx *gin.Context, param string) (string, bool) {
+	value := gctx.Param(param)
+	if _, err := uuid.Parse(value); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid UUID param.", "param", param)
+		c_at.AbortErrAtom(gctx, err)
+		return "", false
+	}
+
+	return value, true
+}
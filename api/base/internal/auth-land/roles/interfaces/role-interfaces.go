@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	citf "aigents-base/internal/common/interfaces"
+	d "aigents-base/internal/auth-land/roles/domain"
+
+	"context"
+)
+
+type RoleServiceITF interface {
+	citf.Common[d.CreatorRequest]
+	FetchByStatus(ctx context.Context, status string, limit, offset uint64) ([]d.CreatorRequest, error)
+	UpdateRole(ctx context.Context, data *d.RoleChange) error
+}
+
+type RoleRepositoryITF interface {
+	citf.Common[d.CreatorRequest]
+	FetchByStatus(ctx context.Context, status string, limit, offset uint64) ([]d.CreatorRequest, error)
+	UpdateRole(ctx context.Context, data *d.RoleChange) error
+}
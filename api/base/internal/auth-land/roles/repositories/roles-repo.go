@@ -0,0 +1,323 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/roles/domain"
+	rlitf "aigents-base/internal/auth-land/roles/interfaces"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type RoleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) rlitf.RoleRepositoryITF {
+	return &RoleRepository{db: db}
+}
+
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *RoleRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
+// Create files a creator request. Only active USER authentications are
+// eligible; anyone else gets a conflict.
+func (r *RoleRepository) Create(ctx context.Context, data *d.CreatorRequest) error {
+	query := `
+	INSERT INTO creator_requests (auth_uuid, message)
+	SELECT auth_uuid, $2
+	FROM auths
+	WHERE auth_uuid = $1 AND role = 'USER' AND deleted_at IS NULL
+	RETURNING request_uuid, status, created_at;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.AuthUUID, data.Message).Scan(
+		&data.RequestUUID,
+		&data.Status,
+		&data.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.Conflict, "(R) Not eligible for a creator request.", "Auth not eligible for a creator request.")
+		return err
+	}
+
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			err = errs.New(
+				errs.Conflict,
+				"(R) A creator request is already pending.",
+				"Auth already has a pending creator request.", "auth_uuid", data.AuthUUID)
+			return err
+		}
+
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not create creator request.",
+			"Failed to create creator request.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *RoleRepository) GetByID(ctx context.Context, data *d.CreatorRequest) error {
+	query := `
+	SELECT
+		cr.request_uuid,
+		cr.auth_uuid,
+		a.email,
+		COALESCE(cr.message, ''),
+		cr.status,
+		COALESCE(cr.reviewed_by::text, ''),
+		cr.created_at,
+		COALESCE(cr.reviewed_at, TIMESTAMP '0001-01-01 00:00:00')
+	FROM creator_requests cr
+	INNER JOIN auths a ON cr.auth_uuid = a.auth_uuid
+	WHERE cr.request_uuid = $1;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.RequestUUID).Scan(
+		&data.RequestUUID,
+		&data.AuthUUID,
+		&data.Email,
+		&data.Message,
+		&data.Status,
+		&data.ReviewedBy,
+		&data.CreatedAt,
+		&data.ReviewedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Creator request not found.", "Creator request not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not get creator request.",
+			"Failed to get creator request.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *RoleRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.CreatorRequest, error) {
+	return r.FetchByStatus(ctx, "", limit, offset)
+}
+
+// FetchByStatus lists creator requests oldest first, so the review queue is
+// worked in arrival order. An empty status lists every request.
+func (r *RoleRepository) FetchByStatus(ctx context.Context, status string, limit, offset uint64) ([]d.CreatorRequest, error) {
+	query := `
+	SELECT
+		cr.request_uuid,
+		cr.auth_uuid,
+		a.email,
+		COALESCE(cr.message, ''),
+		cr.status,
+		COALESCE(cr.reviewed_by::text, ''),
+		cr.created_at,
+		COALESCE(cr.reviewed_at, TIMESTAMP '0001-01-01 00:00:00')
+	FROM creator_requests cr
+	INNER JOIN auths a ON cr.auth_uuid = a.auth_uuid
+	WHERE ($1 = '' OR cr.status::text = $1)
+	ORDER BY cr.created_at ASC, cr.request_uuid ASC
+	LIMIT $2 OFFSET $3;
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch creator requests.",
+			"Failed to fetch creator requests.", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []d.CreatorRequest{}
+
+	for rows.Next() {
+		var request d.CreatorRequest
+
+		err := rows.Scan(
+			&request.RequestUUID,
+			&request.AuthUUID,
+			&request.Email,
+			&request.Message,
+			&request.Status,
+			&request.ReviewedBy,
+			&request.CreatedAt,
+			&request.ReviewedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch creator requests.",
+				"Failed to scan creator request.", "error", err)
+			return nil, err
+		}
+
+		requests = append(requests, request)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch creator requests.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// Update reviews a pending request with data.Status, promoting the requester
+// to CREATOR on approval.
+func (r *RoleRepository) Update(ctx context.Context, data *d.CreatorRequest) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		reviewSQL := `
+		UPDATE creator_requests
+		SET status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE request_uuid = $1 AND status = 'PENDING'
+		RETURNING auth_uuid, reviewed_at;
+		`
+
+		err := r.conn(ctx).QueryRowContext(ctx, reviewSQL, data.RequestUUID, data.Status, data.ReviewedBy).Scan(
+			&data.AuthUUID,
+			&data.ReviewedAt,
+		)
+
+		if err == sql.ErrNoRows {
+			err = errs.New(errs.NotFound, "(R) Creator request not found.", "Creator request not found.")
+			return err
+		}
+
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not review creator request.",
+				"Failed to review creator request.", "error", err)
+			return err
+		}
+
+		if data.Status == d.CreatorRequestApproved {
+			_, err = r.conn(ctx).ExecContext(ctx, "UPDATE auths SET role = 'CREATOR' WHERE auth_uuid = $1 AND role = 'USER';", data.AuthUUID)
+			if err != nil {
+				err = errs.New(
+					errs.Internal,
+					"(R) Could not review creator request.",
+					"Failed to promote auth.", "auth_uuid", data.AuthUUID, "error", err)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Delete withdraws a pending request owned by data.AuthUUID.
+func (r *RoleRepository) Delete(ctx context.Context, data *d.CreatorRequest) error {
+	query := `
+	DELETE FROM creator_requests
+	WHERE request_uuid = $1 AND auth_uuid = $2 AND status = 'PENDING';
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, data.RequestUUID, data.AuthUUID)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not withdraw creator request.",
+			"Failed to delete creator request.", "error", err)
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not withdraw creator request.",
+			"Failed to read affected rows.", "error", err)
+		return err
+	}
+
+	if affected == 0 {
+		err = errs.New(errs.NotFound, "(R) Creator request not found.", "Creator request not found.")
+		return err
+	}
+
+	return nil
+}
+
+// UpdateRole sets the role of an authentication and revokes its sessions, so
+// tokens minted with the old role stop refreshing. Promoting someone settles
+// their pending creator request, if any, as approved by the same admin.
+func (r *RoleRepository) UpdateRole(ctx context.Context, data *d.RoleChange) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		res, err := r.conn(ctx).ExecContext(ctx,
+			"UPDATE auths SET role = $2 WHERE auth_uuid = $1 AND deleted_at IS NULL;",
+			data.AuthUUID,
+			data.Role,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update role.",
+				"Failed to update role of auth.", "auth_uuid", data.AuthUUID, "error", err)
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update role.",
+				"Failed to read affected rows.", "error", err)
+			return err
+		}
+
+		if affected == 0 {
+			err = errs.New(errs.NotFound, "(R) Authentication not found.", "Auth not found.")
+			return err
+		}
+
+		if data.Role != d.RoleUser {
+			settleSQL := `
+			UPDATE creator_requests
+			SET status = 'APPROVED', reviewed_by = $2, reviewed_at = NOW()
+			WHERE auth_uuid = $1 AND status = 'PENDING';
+			`
+
+			_, err = r.conn(ctx).ExecContext(ctx, settleSQL, data.AuthUUID, data.ChangedBy)
+			if err != nil {
+				err = errs.New(
+					errs.Internal,
+					"(R) Could not update role.",
+					"Failed to settle creator request of auth.", "auth_uuid", data.AuthUUID, "error", err)
+				return err
+			}
+		}
+
+		_, err = r.conn(ctx).ExecContext(ctx,
+			"UPDATE auth_sessions SET revoked_at = NOW() WHERE auth_uuid = $1 AND revoked_at IS NULL;",
+			data.AuthUUID,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update role.",
+				"Failed to revoke sessions of auth.", "auth_uuid", data.AuthUUID, "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
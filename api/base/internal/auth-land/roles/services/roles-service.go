@@ -0,0 +1,104 @@
+package services
+
+import (
+	d "aigents-base/internal/auth-land/roles/domain"
+	rlitf "aigents-base/internal/auth-land/roles/interfaces"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+)
+
+type RoleService struct {
+	r rlitf.RoleRepositoryITF
+}
+
+func NewRoleService(repo rlitf.RoleRepositoryITF) rlitf.RoleServiceITF {
+	return &RoleService{r: repo}
+}
+
+func (s *RoleService) Create(ctx context.Context, data *d.CreatorRequest) error {
+	err := s.r.Create(ctx, data)
+	if errors.Is(err, errs.Conflict) {
+		err = errs.New(
+			errs.Conflict,
+			"(S) Only users can request to become creators.",
+			"Auth is not eligible for a creator request.", "auth_uuid", data.AuthUUID)
+	}
+
+	return err
+}
+
+func (s *RoleService) GetByID(ctx context.Context, data *d.CreatorRequest) error {
+	err := s.r.GetByID(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errRequestNotFound(data.RequestUUID)
+	}
+
+	return err
+}
+
+func (s *RoleService) Fetch(ctx context.Context, limit, offset uint64) ([]d.CreatorRequest, error) {
+	return s.r.Fetch(ctx, limit, offset)
+}
+
+func (s *RoleService) FetchByStatus(ctx context.Context, status string, limit, offset uint64) ([]d.CreatorRequest, error) {
+	return s.r.FetchByStatus(ctx, status, limit, offset)
+}
+
+// Update reviews a pending creator request; data.Status must be APPROVED or
+// REJECTED and data.ReviewedBy the reviewing admin.
+func (s *RoleService) Update(ctx context.Context, data *d.CreatorRequest) error {
+	if data.Status != d.CreatorRequestApproved && data.Status != d.CreatorRequestRejected {
+		err := errs.New(
+			errs.Validation,
+			"(S) Invalid review status.",
+			"Invalid review status.", "status", data.Status)
+		return err
+	}
+
+	err := s.r.Update(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errRequestNotFound(data.RequestUUID)
+	}
+
+	return err
+}
+
+func (s *RoleService) Delete(ctx context.Context, data *d.CreatorRequest) error {
+	err := s.r.Delete(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errRequestNotFound(data.RequestUUID)
+	}
+
+	return err
+}
+
+// UpdateRole changes the role of another authentication. Admins can't change
+// their own role, so the last admin can't lock everyone out by accident.
+func (s *RoleService) UpdateRole(ctx context.Context, data *d.RoleChange) error {
+	if data.AuthUUID == data.ChangedBy {
+		err := errs.New(
+			errs.Forbidden,
+			"(S) Admins cannot change their own role.",
+			"Auth tried to change its own role.", "auth_uuid", data.ChangedBy)
+		return err
+	}
+
+	err := s.r.UpdateRole(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.NotFound,
+			"(S) Authentication not found.",
+			"Auth not found.", "auth_uuid", data.AuthUUID)
+	}
+
+	return err
+}
+
+func errRequestNotFound(requestUUID string) error {
+	return errs.New(
+		errs.NotFound,
+		"(S) Creator request not found.",
+		"Creator request not found.", "request_uuid", requestUUID)
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRefreshReused marks a refresh token presented after it was rotated out,
+// which revokes its whole session.
+var ErrRefreshReused = errors.New("refresh token reused")
+
+// Session is a refresh token family: one login, followed by every refresh
+// token rotated out of it. RefreshJTI is the jti of the token currently valid.
+type Session struct {
+	SessionUUID string    `json:"session_uuid"`
+	AuthUUID    string    `json:"auth_uuid"`
+	Role        string    `json:"-"`
+	RefreshJTI  string    `json:"-"`
+	ClientIP    string    `json:"client_ip"`
+	UserAgent   string    `json:"user_agent"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	RevokedAt   time.Time `json:"revoked_at"`
+	Current     bool      `json:"current"`
+}
+
+type RevokedSessions struct {
+	Revoked int64 `json:"revoked"`
+}
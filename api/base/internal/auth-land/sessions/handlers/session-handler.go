@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	m "aigents-base/internal/auth-land/auth-signature/middleware"
+	d "aigents-base/internal/auth-land/sessions/domain"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
+	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SessionHandler struct {
+	s ssitf.SessionServiceITF
+}
+
+func NewSessionHandler(sv ssitf.SessionServiceITF) *SessionHandler {
+	return &SessionHandler{s: sv}
+}
+
+func (h *SessionHandler) Fetch(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	sessionUUID, _ := m.GetSessionUUID(gctx)
+
+	sessions, err := h.s.FetchByAuth(gctx.Request.Context(), authUUID, sessionUUID)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[[]d.Session](gctx, http.StatusOK, "(*) Sessions found.", sessions)
+}
+
+// Delete revokes one session of the logged auth, including the current one.
+func (h *SessionHandler) Delete(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	sessionUUID := gctx.Param("session_uuid")
+	if _, err := uuid.Parse(sessionUUID); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid session UUID.",
+			"Invalid session_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	err := h.s.Delete(gctx.Request.Context(), &d.Session{SessionUUID: sessionUUID, AuthUUID: authUUID})
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if current, _ := m.GetSessionUUID(gctx); current == sessionUUID {
+		m.ClearAuthCookies(gctx)
+	}
+
+	c_at.RespAtom[*struct{}](gctx, http.StatusOK, "(*) Session revoked.", nil)
+}
+
+// DeleteOthers revokes every session of the logged auth but the current one.
+func (h *SessionHandler) DeleteOthers(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	sessionUUID, ok := m.GetSessionUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid session_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	revoked, err := h.s.RevokeOthers(gctx.Request.Context(), &d.Session{SessionUUID: sessionUUID, AuthUUID: authUUID})
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[d.RevokedSessions](gctx, http.StatusOK, "(*) Other sessions revoked.", d.RevokedSessions{Revoked: revoked})
+}
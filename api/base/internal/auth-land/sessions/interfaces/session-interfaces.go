@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	citf "aigents-base/internal/common/interfaces"
+	d "aigents-base/internal/auth-land/sessions/domain"
+
+	"context"
+)
+
+type SessionServiceITF interface {
+	citf.Common[d.Session]
+	Rotate(ctx context.Context, data *d.Session, presentedJTI string) error
+	Revoke(ctx context.Context, data *d.Session) error
+	FetchByAuth(ctx context.Context, authUUID, currentSessionUUID string) ([]d.Session, error)
+	RevokeOthers(ctx context.Context, data *d.Session) (int64, error)
+	IsActive(ctx context.Context, sessionUUID string) (bool, error)
+}
+
+type SessionRepositoryITF interface {
+	citf.Common[d.Session]
+	Rotate(ctx context.Context, data *d.Session, presentedJTI string) error
+	FetchByAuth(ctx context.Context, authUUID string) ([]d.Session, error)
+	RevokeOthers(ctx context.Context, data *d.Session) (int64, error)
+	IsActive(ctx context.Context, sessionUUID string) (bool, error)
+}
@@ -0,0 +1,451 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/sessions/domain"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) ssitf.SessionRepositoryITF {
+	return &SessionRepository{db: db}
+}
+
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *SessionRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
+// Create opens a new token family and registers its first refresh token.
+func (r *SessionRepository) Create(ctx context.Context, data *d.Session) error {
+	query := `
+	WITH ins_session AS (
+		INSERT INTO auth_sessions (auth_uuid, client_ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING session_uuid, created_at, last_used_at
+	),
+	ins_token AS (
+		INSERT INTO refresh_tokens (jti, session_uuid, expires_at)
+		VALUES ($5, (SELECT session_uuid FROM ins_session), $4)
+	)
+	SELECT session_uuid, created_at, last_used_at FROM ins_session;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx,
+		query,
+		data.AuthUUID,   // $1
+		data.ClientIP,   // $2
+		data.UserAgent,  // $3
+		data.ExpiresAt,  // $4
+		data.RefreshJTI, // $5
+	).Scan(
+		&data.SessionUUID,
+		&data.CreatedAt,
+		&data.LastUsedAt,
+	)
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not create session.",
+			"Failed to create session.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *SessionRepository) GetByID(ctx context.Context, data *d.Session) error {
+	query := `
+	SELECT
+		session_uuid,
+		auth_uuid,
+		COALESCE(client_ip, ''),
+		COALESCE(user_agent, ''),
+		created_at,
+		last_used_at,
+		expires_at,
+		COALESCE(revoked_at, TIMESTAMP '0001-01-01 00:00:00')
+	FROM auth_sessions
+	WHERE session_uuid = $1;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.SessionUUID).Scan(
+		&data.SessionUUID,
+		&data.AuthUUID,
+		&data.ClientIP,
+		&data.UserAgent,
+		&data.CreatedAt,
+		&data.LastUsedAt,
+		&data.ExpiresAt,
+		&data.RevokedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Session not found.", "Session not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not get session.",
+			"Failed to get session.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *SessionRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Session, error) {
+	query := `
+	SELECT
+		session_uuid,
+		auth_uuid,
+		COALESCE(client_ip, ''),
+		COALESCE(user_agent, ''),
+		created_at,
+		last_used_at,
+		expires_at,
+		COALESCE(revoked_at, TIMESTAMP '0001-01-01 00:00:00')
+	FROM auth_sessions
+	WHERE revoked_at IS NULL
+	ORDER BY last_used_at DESC
+	LIMIT $1 OFFSET $2;
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch sessions.",
+			"Failed to fetch sessions.", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []d.Session
+
+	for rows.Next() {
+		var session d.Session
+
+		err := rows.Scan(
+			&session.SessionUUID,
+			&session.AuthUUID,
+			&session.ClientIP,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+			&session.RevokedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch sessions.",
+				"Failed to scan session.", "error", err)
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch sessions.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Update records the client the session was last used from.
+func (r *SessionRepository) Update(ctx context.Context, data *d.Session) error {
+	query := `
+	UPDATE auth_sessions
+	SET last_used_at = NOW(), client_ip = $2, user_agent = $3
+	WHERE session_uuid = $1 AND revoked_at IS NULL
+	RETURNING last_used_at;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.SessionUUID, data.ClientIP, data.UserAgent).Scan(&data.LastUsedAt)
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Session not found.", "Session not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not update session.",
+			"Failed to update session.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete revokes the whole token family of a session owned by data.AuthUUID.
+func (r *SessionRepository) Delete(ctx context.Context, data *d.Session) error {
+	query := `
+	UPDATE auth_sessions
+	SET revoked_at = NOW()
+	WHERE session_uuid = $1 AND auth_uuid = $2 AND revoked_at IS NULL
+	RETURNING revoked_at;
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.SessionUUID, data.AuthUUID).Scan(&data.RevokedAt)
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Session not found.", "Session not found.")
+		return err
+	}
+
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not revoke session.",
+			"Failed to revoke session.", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Rotate swaps presentedJTI for data.RefreshJTI inside the family
+// data.SessionUUID and loads the current role of its owner. Presenting a jti
+// that was already rotated out means the token leaked, so the whole family is
+// revoked and d.ErrRefreshReused is returned.
+func (r *SessionRepository) Rotate(ctx context.Context, data *d.Session, presentedJTI string) error {
+	var authUUID, role string
+	var reused bool
+
+	err := c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		lookupSQL := `
+		SELECT rt.rotated_at IS NOT NULL, s.revoked_at IS NOT NULL OR a.deleted_at IS NOT NULL, s.auth_uuid, a.role
+		FROM refresh_tokens rt
+		INNER JOIN auth_sessions s ON rt.session_uuid = s.session_uuid
+		INNER JOIN auths a ON s.auth_uuid = a.auth_uuid
+		WHERE rt.jti = $1 AND rt.session_uuid = $2
+		FOR UPDATE OF rt, s;
+		`
+
+		var rotated, revoked bool
+
+		err := r.conn(ctx).QueryRowContext(ctx, lookupSQL, presentedJTI, data.SessionUUID).Scan(&rotated, &revoked, &authUUID, &role)
+		if err == sql.ErrNoRows {
+			err = errs.New(errs.NotFound, "(R) Session not found.", "Session not found.")
+			return err
+		}
+
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not rotate refresh token.",
+				"Failed to look up refresh token.", "error", err)
+			return err
+		}
+
+		if revoked {
+			err = errs.New(errs.Unauthorized, "(R) Session revoked.", "Session revoked.")
+			return err
+		}
+
+		// the revocation has to commit, so reuse is reported once the
+		// transaction is over
+		if rotated {
+			_, err = r.conn(ctx).ExecContext(ctx, "UPDATE auth_sessions SET revoked_at = NOW() WHERE session_uuid = $1 AND revoked_at IS NULL;", data.SessionUUID)
+			if err != nil {
+				err = errs.New(
+					errs.Internal,
+					"(R) Could not rotate refresh token.",
+					"Failed to revoke reused token family.", "error", err)
+				return err
+			}
+
+			reused = true
+			return nil
+		}
+
+		rotateSQL := `
+		WITH old_token AS (
+			UPDATE refresh_tokens
+			SET rotated_at = NOW()
+			WHERE jti = $1
+		)
+		INSERT INTO refresh_tokens (jti, session_uuid, expires_at)
+		VALUES ($2, $3, $4);
+		`
+
+		_, err = r.conn(ctx).ExecContext(ctx, rotateSQL, presentedJTI, data.RefreshJTI, data.SessionUUID, data.ExpiresAt)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not rotate refresh token.",
+				"Failed to rotate refresh token.", "error", err)
+			return err
+		}
+
+		sessionSQL := `
+		UPDATE auth_sessions
+		SET last_used_at = NOW(), expires_at = $2, client_ip = $3, user_agent = $4
+		WHERE session_uuid = $1
+		RETURNING created_at, last_used_at;
+		`
+
+		err = r.conn(ctx).QueryRowContext(ctx,
+			sessionSQL,
+			data.SessionUUID,
+			data.ExpiresAt,
+			data.ClientIP,
+			data.UserAgent,
+		).Scan(
+			&data.CreatedAt,
+			&data.LastUsedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not rotate refresh token.",
+				"Failed to update session.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		err = errs.New(errs.Unauthorized, "(R) Refresh token reused.", "Refresh token reused, session revoked.", "error", d.ErrRefreshReused)
+		return err
+	}
+
+	data.AuthUUID = authUUID
+	data.Role = role
+
+	return nil
+}
+
+// FetchByAuth lists the sessions of authUUID that can still be refreshed.
+func (r *SessionRepository) FetchByAuth(ctx context.Context, authUUID string) ([]d.Session, error) {
+	query := `
+	SELECT
+		session_uuid,
+		auth_uuid,
+		COALESCE(client_ip, ''),
+		COALESCE(user_agent, ''),
+		created_at,
+		last_used_at,
+		expires_at
+	FROM auth_sessions
+	WHERE auth_uuid = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	ORDER BY last_used_at DESC;
+	`
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, authUUID)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch sessions.",
+			"Failed to fetch sessions of auth.", "auth_uuid", authUUID, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []d.Session{}
+
+	for rows.Next() {
+		var session d.Session
+
+		err := rows.Scan(
+			&session.SessionUUID,
+			&session.AuthUUID,
+			&session.ClientIP,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch sessions.",
+				"Failed to scan session.", "error", err)
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch sessions.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeOthers revokes every session of data.AuthUUID except data.SessionUUID.
+func (r *SessionRepository) RevokeOthers(ctx context.Context, data *d.Session) (int64, error) {
+	query := `
+	UPDATE auth_sessions
+	SET revoked_at = NOW()
+	WHERE auth_uuid = $1 AND session_uuid <> $2 AND revoked_at IS NULL;
+	`
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, data.AuthUUID, data.SessionUUID)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not revoke sessions.",
+			"Failed to revoke sessions of auth.", "auth_uuid", data.AuthUUID, "error", err)
+		return 0, err
+	}
+
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not revoke sessions.",
+			"Failed to read affected rows.", "error", err)
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+func (r *SessionRepository) IsActive(ctx context.Context, sessionUUID string) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM auth_sessions
+		WHERE session_uuid = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	);
+	`
+
+	var active bool
+	err := r.conn(ctx).QueryRowContext(ctx, query, sessionUUID).Scan(&active)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not check session.",
+			"Failed to check session.", "session_uuid", sessionUUID, "error", err)
+		return false, err
+	}
+
+	return active, nil
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	d "aigents-base/internal/auth-land/sessions/domain"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openTestDB connects to the database in TEST_DB_URL and migrates it; these
+// tests run real SQL, so they are skipped when it isn't set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := c_db.NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return conn
+}
+
+// newSession registers a fresh authentication and opens a session for it
+// whose first refresh token is the returned jti.
+func newSession(t *testing.T, conn *sql.DB, r *SessionRepository) (*d.Session, string) {
+	t.Helper()
+
+	var authUUID string
+	err := conn.QueryRow(
+		"INSERT INTO auths (email, password) VALUES ($1, '') RETURNING auth_uuid;",
+		uuid.NewString()+"@sessions.test",
+	).Scan(&authUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := &d.Session{
+		AuthUUID:   authUUID,
+		RefreshJTI: uuid.NewString(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	if err := r.Create(context.Background(), session); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	return session, session.RefreshJTI
+}
+
+// rotate presents jti for the family of session and returns the jti handed
+// out in its place.
+func rotate(r *SessionRepository, session *d.Session, jti string) (string, error) {
+	data := &d.Session{
+		SessionUUID: session.SessionUUID,
+		RefreshJTI:  uuid.NewString(),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	if err := r.Rotate(context.Background(), data, jti); err != nil {
+		return "", err
+	}
+
+	return data.RefreshJTI, nil
+}
+
+func TestRotateHandsOutNewJTI(t *testing.T) {
+	conn := openTestDB(t)
+	r := &SessionRepository{db: conn}
+	session, first := newSession(t, conn, r)
+
+	data := &d.Session{
+		SessionUUID: session.SessionUUID,
+		RefreshJTI:  uuid.NewString(),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	if err := r.Rotate(context.Background(), data, first); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	if data.AuthUUID != session.AuthUUID || data.Role != "USER" {
+		t.Errorf("rotated session = %s/%s, want %s/USER", data.AuthUUID, data.Role, session.AuthUUID)
+	}
+
+	// the new jti is the one that refreshes next
+	if _, err := rotate(r, session, data.RefreshJTI); err != nil {
+		t.Fatalf("Rotate with the new jti: %v", err)
+	}
+}
+
+func TestRotateReusedJTIRevokesFamily(t *testing.T) {
+	conn := openTestDB(t)
+	r := &SessionRepository{db: conn}
+	session, first := newSession(t, conn, r)
+
+	second, err := rotate(r, session, first)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	_, err = rotate(r, session, first)
+	if !errors.Is(err, d.ErrRefreshReused) || !errors.Is(err, errs.Unauthorized) {
+		t.Fatalf("reused jti: error = %v, want Unauthorized ErrRefreshReused", err)
+	}
+
+	active, err := r.IsActive(context.Background(), session.SessionUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active {
+		t.Error("session still active after a reused jti")
+	}
+
+	// the jti legitimately rotated in dies with the family
+	if _, err := rotate(r, session, second); !errors.Is(err, errs.Unauthorized) {
+		t.Errorf("current jti after reuse: error = %v, want Unauthorized", err)
+	}
+}
+
+func TestRotateRejects(t *testing.T) {
+	conn := openTestDB(t)
+	r := &SessionRepository{db: conn}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, session *d.Session)
+		want  errs.Kind
+	}{
+		{
+			name: "revoked session",
+			setup: func(t *testing.T, session *d.Session) {
+				if err := r.Delete(context.Background(), session); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: errs.Unauthorized,
+		},
+		{
+			name: "deleted auth",
+			setup: func(t *testing.T, session *d.Session) {
+				if _, err := conn.Exec("UPDATE auths SET deleted_at = NOW() WHERE auth_uuid = $1;", session.AuthUUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: errs.Unauthorized,
+		},
+		{
+			name: "jti of another family",
+			setup: func(t *testing.T, session *d.Session) {
+				other, _ := newSession(t, conn, r)
+				session.SessionUUID = other.SessionUUID
+			},
+			want: errs.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, jti := newSession(t, conn, r)
+			tt.setup(t, session)
+
+			if _, err := rotate(r, session, jti); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
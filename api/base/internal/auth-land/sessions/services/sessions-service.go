@@ -0,0 +1,131 @@
+package services
+
+import (
+	d "aigents-base/internal/auth-land/sessions/domain"
+	ssitf "aigents-base/internal/auth-land/sessions/interfaces"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionService struct {
+	r          ssitf.SessionRepositoryITF
+	refreshTTL time.Duration
+}
+
+func NewSessionService(repo ssitf.SessionRepositoryITF, refreshTTL time.Duration) ssitf.SessionServiceITF {
+	return &SessionService{r: repo, refreshTTL: refreshTTL}
+}
+
+// Create opens a session for data.AuthUUID and sets data.RefreshJTI to the
+// jti its first refresh token must carry.
+func (s *SessionService) Create(ctx context.Context, data *d.Session) error {
+	data.RefreshJTI = uuid.New().String()
+	data.ExpiresAt = time.Now().Add(s.refreshTTL)
+
+	return s.r.Create(ctx, data)
+}
+
+// Rotate exchanges presentedJTI for a fresh jti, stored in data.RefreshJTI.
+func (s *SessionService) Rotate(ctx context.Context, data *d.Session, presentedJTI string) error {
+	data.RefreshJTI = uuid.New().String()
+	data.ExpiresAt = time.Now().Add(s.refreshTTL)
+
+	err := s.r.Rotate(ctx, data, presentedJTI)
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, d.ErrRefreshReused):
+		err = errs.New(
+			errs.Unauthorized,
+			"(S) Invalid refresh token.",
+			"Refresh token reused, session revoked.", "presented_jti", presentedJTI, "session_uuid", data.SessionUUID)
+	case errors.Is(err, errs.NotFound), errors.Is(err, errs.Unauthorized):
+		err = errs.New(
+			errs.Unauthorized,
+			"(S) Invalid refresh token.",
+			"Refresh token of an inactive session.", "presented_jti", presentedJTI, "session_uuid", data.SessionUUID)
+	}
+
+	return err
+}
+
+// Revoke ends a session the way logout needs it: revoking one that is
+// already gone is not an error.
+func (s *SessionService) Revoke(ctx context.Context, data *d.Session) error {
+	err := s.r.Delete(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *SessionService) GetByID(ctx context.Context, data *d.Session) error {
+	err := s.r.GetByID(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.NotFound,
+			"(S) Session not found.",
+			"Session not found.", "session_uuid", data.SessionUUID)
+	}
+
+	return err
+}
+
+func (s *SessionService) Fetch(ctx context.Context, limit, offset uint64) ([]d.Session, error) {
+	return s.r.Fetch(ctx, limit, offset)
+}
+
+func (s *SessionService) Update(ctx context.Context, data *d.Session) error {
+	err := s.r.Update(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.NotFound,
+			"(S) Session not found.",
+			"Session not found.", "session_uuid", data.SessionUUID)
+	}
+
+	return err
+}
+
+func (s *SessionService) Delete(ctx context.Context, data *d.Session) error {
+	err := s.r.Delete(ctx, data)
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.NotFound,
+			"(S) Session not found.",
+			"Session not found for auth.", "session_uuid", data.SessionUUID, "auth_uuid", data.AuthUUID)
+	}
+
+	return err
+}
+
+// FetchByAuth lists the active sessions of authUUID, flagging the one the
+// request was made from.
+func (s *SessionService) FetchByAuth(ctx context.Context, authUUID, currentSessionUUID string) ([]d.Session, error) {
+	sessions, err := s.r.FetchByAuth(ctx, authUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionUUID == currentSessionUUID
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionService) RevokeOthers(ctx context.Context, data *d.Session) (int64, error) {
+	return s.r.RevokeOthers(ctx, data)
+}
+
+func (s *SessionService) IsActive(ctx context.Context, sessionUUID string) (bool, error) {
+	return s.r.IsActive(ctx, sessionUUID)
+}
@@ -0,0 +1,40 @@
+package atoms
+
+import (
+	d "aigents-base/internal/chat/domain"
+
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func EncodeCursorAtom(at time.Time, rowUUID string) string {
+	raw := at.UTC().Format(time.RFC3339Nano) + "|" + rowUUID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursorAtom(cursor string) (*d.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	at, rowUUID, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor format")
+	}
+
+	parsedAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+
+	if _, err := uuid.Parse(rowUUID); err != nil {
+		return nil, fmt.Errorf("invalid cursor uuid: %w", err)
+	}
+
+	return &d.Cursor{At: parsedAt, UUID: rowUUID}, nil
+}
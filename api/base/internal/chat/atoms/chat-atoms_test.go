@@ -0,0 +1,42 @@
+package atoms
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorAtomRoundTrip(t *testing.T) {
+	at := time.Date(2025, 3, 9, 14, 30, 15, 123456789, time.FixedZone("BRT", -3*60*60))
+	rowUUID := "2b1f2c3e-8f0a-4d5e-9a6b-7c8d9e0f1a2b"
+
+	cursor, err := DecodeCursorAtom(EncodeCursorAtom(at, rowUUID))
+	if err != nil {
+		t.Fatalf("DecodeCursorAtom: %v", err)
+	}
+
+	// nanoseconds survive, so rows created in the same second still page
+	if !cursor.At.Equal(at) || cursor.UUID != rowUUID {
+		t.Errorf("round trip = %v/%s, want %v/%s", cursor.At, cursor.UUID, at, rowUUID)
+	}
+}
+
+func TestDecodeCursorAtomRejects(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	tests := map[string]string{
+		"empty":             "",
+		"not base64":        "not a cursor!",
+		"no separator":      encode("2025-03-09T14:30:15Z"),
+		"bad timestamp":     encode("yesterday|2b1f2c3e-8f0a-4d5e-9a6b-7c8d9e0f1a2b"),
+		"bad uuid":          encode("2025-03-09T14:30:15Z|42"),
+		"sql in uuid":       encode("2025-03-09T14:30:15Z|' OR 1=1 --"),
+		"extra field glued": encode("2025-03-09T14:30:15Z|2b1f2c3e-8f0a-4d5e-9a6b-7c8d9e0f1a2b|x"),
+	}
+
+	for name, cursor := range tests {
+		if _, err := DecodeCursorAtom(cursor); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
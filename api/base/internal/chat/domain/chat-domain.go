@@ -4,10 +4,18 @@ import (
 	"time"
 )
 
+const (
+	MessagesBefore = "before"
+	MessagesAfter  = "after"
+)
+
 type Chat struct {
 	ChatUUID  string `json:"chat_uuid"`
 	AgentUUID string `json:"agent_uuid"`
 	AuthUUID  string `json:"auth_uuid"`
+	AgentName string `json:"agent_name,omitempty"`
+	LastMessagePreview string `json:"last_message_preview,omitempty"`
+	Archived  bool `json:"archived"`
 	History   []Message `json:"history,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -29,3 +37,21 @@ type MessageContent struct {
 	MessageContentUUID string  `json:"message_content_uuid"`
 	Content            string     `json:"content"`
 }
+
+// Cursor is a keyset position: a timestamp plus the row UUID breaking ties.
+type Cursor struct {
+	At   time.Time
+	UUID string
+}
+
+type ChatPage struct {
+	Chats      []Chat `json:"chats"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type MessagePage struct {
+	Messages     []Message `json:"messages"`
+	BeforeCursor string    `json:"before_cursor,omitempty"`
+	AfterCursor  string    `json:"after_cursor,omitempty"`
+	HasMore      bool      `json:"has_more"`
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	ch_at "aigents-base/internal/chat/atoms"
 	d "aigents-base/internal/chat/domain"
 	chitf "aigents-base/internal/chat/interfaces"
 	m "aigents-base/internal/auth-land/auth-signature/middleware"
 	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
 	"net/http"
 	"time"
 	"github.com/google/uuid"
@@ -22,12 +24,11 @@ func NewChatHandler(sv chitf.ChatServiceITF) *ChatHandler {
 func (h *ChatHandler) Create(gctx *gin.Context) {
 	authUUID, ok := m.GetAuthUUID(gctx)
 	if !ok {
-		err := c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+		err := errs.New(
+			errs.Unauthorized,
 			"(H) Invalid context values.",
 			"Invalid auth_uuid in context!")
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -37,32 +38,24 @@ func (h *ChatHandler) Create(gctx *gin.Context) {
 	}
 
 	if err := gctx.ShouldBindJSON(&req); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
-	// Set up SSE headers for streaming
-	gctx.Header("Content-Type", "text/event-stream")
-	gctx.Header("Cache-Control", "no-cache")
-	gctx.Header("Connection", "keep-alive")
-	gctx.Header("Transfer-Encoding", "chunked")
-	gctx.Header("X-Accel-Buffering", "no")
-
 	flusher, ok := gctx.Writer.(http.Flusher)
 	if !ok {
-		err := c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err := errs.New(
+			errs.Internal,
 			"(H) Streaming not supported.",
-			"Streaming not supported")
-		c_at.FeedErrLogToFile(err)
+			"Streaming not supported.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
+	stream := &sseStream{gctx: gctx, flusher: flusher}
 
 	// Create chat with initial message
 	chatUUID := uuid.New().String()
@@ -87,39 +80,33 @@ func (h *ChatHandler) Create(gctx *gin.Context) {
 		},
 	}
 
-	// Send initial test event
-	gctx.SSEvent("test", "connection established")
-	flusher.Flush()
-
 	// Stream callback function
 	streamCallback := func(chunk string) {
-		gctx.SSEvent("message", chunk)
-		flusher.Flush()
+		stream.event("message", chunk)
 	}
 
 	// Call service with streaming
-	err := h.s.InitChat(gctx, chat, streamCallback)
+	err := h.s.InitChat(gctx.Request.Context(), chat, streamCallback)
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
-		gctx.SSEvent("error", "(SSE) Could not initialize chat.")
-		flusher.Flush()
+		c_at.AbortErrAtom(gctx, err)
+		if stream.started {
+			stream.event("error", "(SSE) Could not initialize chat.")
+		}
 		return
 	}
 
 	// Send final message with complete chat
-	gctx.SSEvent("done", chat)
-	flusher.Flush()
+	stream.event("done", chat)
 }
 
 func (h *ChatHandler) SendMessage(gctx *gin.Context) {
 	authUUID, ok := m.GetAuthUUID(gctx)
 	if !ok {
-		err := c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusUnauthorized,
+		err := errs.New(
+			errs.Unauthorized,
 			"(H) Invalid context values.",
 			"Invalid auth_uuid in context!")
-		c_at.FeedErrLogToFile(err)
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -129,12 +116,11 @@ func (h *ChatHandler) SendMessage(gctx *gin.Context) {
 	}
 
 	if err := gctx.ShouldBindJSON(&req); err != nil {
-		err = c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusBadRequest,
+		err = errs.New(
+			errs.Validation,
 			"(H) Invalid body request or values.",
-			"Invalid body request")
-		c_at.FeedErrLogToFile(err)
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
 
@@ -151,44 +137,278 @@ func (h *ChatHandler) SendMessage(gctx *gin.Context) {
 		CreatedAt: time.Now(),
 	}
 
-	// Set up SSE headers for streaming
-	gctx.Header("Content-Type", "text/event-stream")
-	gctx.Header("Cache-Control", "no-cache")
-	gctx.Header("Connection", "keep-alive")
-	gctx.Header("Transfer-Encoding", "chunked")
-	gctx.Header("X-Accel-Buffering", "no")
-
 	flusher, ok := gctx.Writer.(http.Flusher)
 	if !ok {
-		err := c_at.AbortAndBuildErrLogAtom(
-			gctx,
-			http.StatusInternalServerError,
+		err := errs.New(
+			errs.Internal,
 			"(H) Streaming not supported.",
-			"Streaming not supported")
-		c_at.FeedErrLogToFile(err)
+			"Streaming not supported.")
+		c_at.AbortErrAtom(gctx, err)
 		return
 	}
-
-	// Send initial test event
-	gctx.SSEvent("test", "connection established")
-	flusher.Flush()
+	stream := &sseStream{gctx: gctx, flusher: flusher}
 
 	// Stream callback function
 	streamCallback := func(chunk string) {
-		gctx.SSEvent("message", chunk)
-		flusher.Flush()
+		stream.event("message", chunk)
 	}
 
 	// Call service with streaming
-	err := h.s.SendMessage(gctx, userMessage, authUUID, streamCallback)
+	err := h.s.SendMessage(gctx.Request.Context(), userMessage, authUUID, streamCallback)
 	if err != nil {
-		c_at.FeedErrLogToFile(err)
-		gctx.SSEvent("error", "(SSE) Could not send message.")
-		flusher.Flush()
+		c_at.AbortErrAtom(gctx, err)
+		if stream.started {
+			stream.event("error", "(SSE) Could not send message.")
+		}
 		return
 	}
 
 	// Send final message with complete response
-	gctx.SSEvent("done", userMessage)
-	flusher.Flush()
+	stream.event("done", userMessage)
+}
+
+func (h *ChatHandler) Fetch(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		Cursor   string `form:"cursor"`
+		Limit    uint64 `form:"limit" binding:"omitempty,min=1,max=100"`
+		Archived bool   `form:"archived"`
+	}
+
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid query parameters.",
+			"Invalid query parameters.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+
+	var cursor *d.Cursor
+	if req.Cursor != "" {
+		var err error
+		cursor, err = ch_at.DecodeCursorAtom(req.Cursor)
+		if err != nil {
+			err = errs.New(
+				errs.Validation,
+				"(H) Invalid cursor.",
+				"Invalid cursor.", "error", err)
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+	}
+
+	data, err := h.s.FetchByAuth(gctx.Request.Context(), authUUID, req.Archived, cursor, req.Limit)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*d.ChatPage](gctx,
+		http.StatusOK,
+		"(*) Data retrivied",
+		data)
+}
+
+func (h *ChatHandler) FetchMessages(gctx *gin.Context) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	chatUUID, err := uuid.Parse(gctx.Param("chat_uuid"))
+	if err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid chat_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	var req struct {
+		Cursor    string `form:"cursor"`
+		Direction string `form:"direction" binding:"omitempty,oneof=before after"`
+		Limit     uint64 `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+
+	if err := gctx.ShouldBindQuery(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid query parameters.",
+			"Invalid query parameters.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	if req.Direction == "" {
+		req.Direction = d.MessagesBefore
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	var cursor *d.Cursor
+	if req.Cursor != "" {
+		cursor, err = ch_at.DecodeCursorAtom(req.Cursor)
+		if err != nil {
+			err = errs.New(
+				errs.Validation,
+				"(H) Invalid cursor.",
+				"Invalid cursor.", "error", err)
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+	}
+
+	chat := &d.Chat{ChatUUID: chatUUID.String(), AuthUUID: authUUID}
+	data, err := h.s.FetchMessages(gctx.Request.Context(), chat, cursor, req.Direction, req.Limit)
+	if err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*d.MessagePage](gctx,
+		http.StatusOK,
+		"(*) Data retrivied",
+		data)
+}
+
+func (h *ChatHandler) Update(gctx *gin.Context) {
+	chat, ok := h.ownedChatFromParam(gctx)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Archived *bool `json:"archived" binding:"required"`
+	}
+
+	if err := gctx.ShouldBindJSON(&req); err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid body request or values.",
+			"Invalid body request.")
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	chat.Archived = *req.Archived
+	if err := h.s.Update(gctx.Request.Context(), chat); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx,
+		http.StatusOK,
+		"(*) Chat updated.",
+		nil)
+}
+
+func (h *ChatHandler) Delete(gctx *gin.Context) {
+	chat, ok := h.ownedChatFromParam(gctx)
+	if !ok {
+		return
+	}
+
+	if err := h.s.Delete(gctx.Request.Context(), chat); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*struct{}](gctx,
+		http.StatusOK,
+		"(*) Chat deleted.",
+		nil)
+}
+
+func (h *ChatHandler) Restore(gctx *gin.Context) {
+	chat, ok := h.ownedChatFromParam(gctx)
+	if !ok {
+		return
+	}
+
+	if err := h.s.Restore(gctx.Request.Context(), chat); err != nil {
+		c_at.AbortErrAtom(gctx, err)
+		return
+	}
+
+	c_at.RespAtom[*d.Chat](gctx,
+		http.StatusOK,
+		"(*) Chat restored.",
+		chat)
+}
+
+// sseStream opens the event stream on its first event, so errors raised
+// before the AI service answers, such as a chat the caller doesn't own,
+// still get a plain JSON response with their status.
+type sseStream struct {
+	gctx    *gin.Context
+	flusher http.Flusher
+	started bool
+}
+
+func (w *sseStream) event(name string, data any) {
+	if !w.started {
+		w.started = true
+
+		w.gctx.Header("Content-Type", "text/event-stream")
+		w.gctx.Header("Cache-Control", "no-cache")
+		w.gctx.Header("Connection", "keep-alive")
+		w.gctx.Header("Transfer-Encoding", "chunked")
+		w.gctx.Header("X-Accel-Buffering", "no")
+
+		// The stream may outlive the server's WriteTimeout; the AI service's
+		// read deadline bounds it instead
+		http.NewResponseController(w.gctx.Writer).SetWriteDeadline(time.Time{})
+
+		w.gctx.SSEvent("test", "connection established")
+	}
+
+	w.gctx.SSEvent(name, data)
+	w.flusher.Flush()
+}
+
+// ownedChatFromParam builds a chat from the :chat_uuid param and the caller's
+// auth_uuid, aborting the request when either is invalid.
+func (h *ChatHandler) ownedChatFromParam(gctx *gin.Context) (*d.Chat, bool) {
+	authUUID, ok := m.GetAuthUUID(gctx)
+	if !ok {
+		err := errs.New(
+			errs.Unauthorized,
+			"(H) Invalid context values.",
+			"Invalid auth_uuid in context!")
+		c_at.AbortErrAtom(gctx, err)
+		return nil, false
+	}
+
+	chatUUID, err := uuid.Parse(gctx.Param("chat_uuid"))
+	if err != nil {
+		err = errs.New(
+			errs.Validation,
+			"(H) Invalid URL parameter.",
+			"Invalid chat_uuid param.")
+		c_at.AbortErrAtom(gctx, err)
+		return nil, false
+	}
+
+	return &d.Chat{ChatUUID: chatUUID.String(), AuthUUID: authUUID}, true
 }
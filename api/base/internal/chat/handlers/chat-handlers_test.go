@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	errs "aigents-base/internal/common/errs"
+
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestMalformedCursorIsValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// the service is never reached with a cursor that doesn't decode
+	h := NewChatHandler(nil)
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		params  gin.Params
+	}{
+		{name: "Fetch", handler: h.Fetch},
+		{
+			name:    "FetchMessages",
+			handler: h.FetchMessages,
+			params:  gin.Params{{Key: "chat_uuid", Value: uuid.NewString()}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			gctx.Request = httptest.NewRequest(http.MethodGet, "/?cursor=bm90LWEtY3Vyc29y", nil)
+			gctx.Params = tt.params
+			gctx.Set("auth_uuid", uuid.NewString())
+
+			tt.handler(gctx)
+
+			if len(gctx.Errors) == 0 || !errors.Is(gctx.Errors.Last().Err, errs.Validation) {
+				t.Fatalf("errors = %v, want Validation", gctx.Errors)
+			}
+		})
+	}
+}
@@ -1,22 +1,31 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 	citf "aigents-base/internal/common/interfaces"
 	d "aigents-base/internal/chat/domain"
-	"github.com/gin-gonic/gin"
 )
 
 type ChatServiceITF interface {
 	citf.Common[d.Chat]
-	SendMessage(gctx *gin.Context, data *d.Message, authUUID string, streamCallback func(chunk string)) error
-	InitChat(gctx *gin.Context, data *d.Chat, streamCallback func(chunk string)) error
+	SendMessage(ctx context.Context, data *d.Message, authUUID string, streamCallback func(chunk string)) error
+	InitChat(ctx context.Context, data *d.Chat, streamCallback func(chunk string)) error
+	FetchByAuth(ctx context.Context, authUUID string, archived bool, cursor *d.Cursor, limit uint64) (*d.ChatPage, error)
+	Restore(ctx context.Context, data *d.Chat) error
+	FetchMessages(ctx context.Context, data *d.Chat, cursor *d.Cursor, direction string, limit uint64) (*d.MessagePage, error)
+	Ping(ctx context.Context) error
+	Drain(ctx context.Context) error
 	Cleanup()
 }
 
 type ChatRepositoryITF interface {
 	citf.Common[d.Chat]
-	AttachMessage(gctx *gin.Context, msg *d.Message) error
-	GetChatHistory(gctx *gin.Context, chatUUID string, limit uint64) ([]d.Message, error)
-	GetRecentMessages(gctx *gin.Context, chatUUID string, since time.Time, limit uint64) ([]d.Message, error)
+	AttachMessage(ctx context.Context, msg *d.Message) error
+	GetChatHistory(ctx context.Context, chatUUID string, limit uint64) ([]d.Message, error)
+	GetRecentMessages(ctx context.Context, chatUUID string, since time.Time, limit uint64) ([]d.Message, error)
+	FetchByAuth(ctx context.Context, authUUID string, archived bool, cursor *d.Cursor, limit uint64) ([]d.Chat, error)
+	Restore(ctx context.Context, data *d.Chat, retention time.Duration) error
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+	FetchMessages(ctx context.Context, chatUUID string, cursor *d.Cursor, direction string, limit uint64) ([]d.Message, error)
 }
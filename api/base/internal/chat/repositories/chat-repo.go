@@ -3,12 +3,14 @@ package repositories
 import (
 	d "aigents-base/internal/chat/domain"
 	chitf "aigents-base/internal/chat/interfaces"
-	c_at "aigents-base/internal/common/atoms"
+	c_db "aigents-base/internal/common/db"
+	errs "aigents-base/internal/common/errs"
+	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 )
 
@@ -20,36 +22,45 @@ func NewChatRepository(db *sql.DB) chitf.ChatRepositoryITF {
 	return &ChatRepository{db: db}
 }
 
-func (r *ChatRepository) Create(gctx *gin.Context, data *d.Chat) error {
+// conn is the transaction of the unit of work ctx belongs to, or the pool.
+func (r *ChatRepository) conn(ctx context.Context) c_db.Querier {
+	return c_db.Conn(ctx, r.db)
+}
+
+func (r *ChatRepository) Create(ctx context.Context, data *d.Chat) error {
 	var agentExists, authExists bool
 
-	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM agents WHERE agent_uuid = $1 AND deleted_at IS NULL)", data.AgentUUID).Scan(&agentExists)
+	err := r.conn(ctx).QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM agents WHERE agent_uuid = $1 AND deleted_at IS NULL)", data.AgentUUID).Scan(&agentExists)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not verify agent existence. Failed to check agent existence: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not verify agent existence.",
+			"Failed to check agent existence.", "error", err)
 		return err
 	}
 
-	err = r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM auths WHERE auth_uuid = $1 AND deleted_at IS NULL)", data.AuthUUID).Scan(&authExists)
+	err = r.conn(ctx).QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM auths WHERE auth_uuid = $1 AND deleted_at IS NULL)", data.AuthUUID).Scan(&authExists)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not verify auth existence. Failed to check auth existence: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not verify auth existence.",
+			"Failed to check auth existence.", "error", err)
 		return err
 	}
 
 	if !agentExists {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Agent not found. Agent with UUID %s does not exist", data.AgentUUID))
+		err = errs.New(
+			errs.NotFound,
+			"(R) Agent not found.",
+			"Agent does not exist.", "agent_uuid", data.AgentUUID)
 		return err
 	}
 
 	if !authExists {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Authentication not found. Auth with UUID %s does not exist", data.AuthUUID))
+		err = errs.New(
+			errs.NotFound,
+			"(R) Authentication not found.",
+			"Auth does not exist.", "auth_uuid", data.AuthUUID)
 		return err
 	}
 
@@ -61,7 +72,7 @@ func (r *ChatRepository) Create(gctx *gin.Context, data *d.Chat) error {
 	`
 
 	var returnedUUID string
-	err = r.db.QueryRow(query,
+	err = r.conn(ctx).QueryRowContext(ctx, query,
 		data.ChatUUID,
 		data.AgentUUID,
 		data.AuthUUID,
@@ -72,152 +83,428 @@ func (r *ChatRepository) Create(gctx *gin.Context, data *d.Chat) error {
 	if err != nil {
 		if err == sql.ErrNoRows {
 			var existingChatUUID string
-			err = r.db.QueryRow("SELECT chat_uuid FROM chats WHERE chat_uuid = $1", data.ChatUUID).Scan(&existingChatUUID)
+			err = r.conn(ctx).QueryRowContext(ctx, "SELECT chat_uuid FROM chats WHERE chat_uuid = $1 AND auth_uuid = $2", data.ChatUUID, data.AuthUUID).Scan(&existingChatUUID)
+			if err == sql.ErrNoRows {
+				err = errs.New(
+					errs.NotFound,
+					"(R) Chat not found.",
+					"Chat already belongs to another auth.", "chat_uuid", data.ChatUUID, "auth_uuid", data.AuthUUID)
+				return err
+			}
 			if err != nil {
-				err = c_at.BuildErrLogAtom(
-					gctx,
-					fmt.Sprintf("(R) Could not create chat. Chat conflict but doesn't exist: %s", err.Error()))
+				err = errs.New(
+					errs.Internal,
+					"(R) Could not create chat.",
+					"Chat conflict but doesn't exist.", "error", err)
 				return err
 			}
 			return nil
 		}
 
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not create chat. Failed to create chat: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not create chat.",
+			"Failed to create chat.", "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func (r *ChatRepository) GetByID(gctx *gin.Context, data *d.Chat) error {
+// GetByID only matches chats owned by data.AuthUUID, so a chat that exists
+// under another auth is indistinguishable from a missing one.
+func (r *ChatRepository) GetByID(ctx context.Context, data *d.Chat) error {
 	query := `
-		SELECT chat_uuid, agent_uuid, auth_uuid, created_at, updated_at
+		SELECT chat_uuid, agent_uuid, auth_uuid, archived_at IS NOT NULL, created_at, updated_at
 		FROM chats
-		WHERE chat_uuid = $1 AND deleted_at IS NULL
+		WHERE chat_uuid = $1 AND auth_uuid = $2 AND deleted_at IS NULL
 	`
 
-	err := r.db.QueryRow(query, data.ChatUUID).Scan(
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.ChatUUID, data.AuthUUID).Scan(
 		&data.ChatUUID,
 		&data.AgentUUID,
 		&data.AuthUUID,
+		&data.Archived,
 		&data.CreatedAt,
 		&data.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Chat not found. Chat with UUID %s not found", data.ChatUUID))
+		err = errs.New(errs.NotFound, "(R) Chat not found.", "Chat not found.")
 		return err
 	}
 
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not get chat. Failed to get chat: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not get chat.",
+			"Failed to get chat.", "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func (r *ChatRepository) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Chat, error) {
-	return nil, nil
-}
+func (r *ChatRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Chat, error) {
+	query := `
+		SELECT chat_uuid, agent_uuid, auth_uuid, created_at, updated_at
+		FROM chats
+		WHERE deleted_at IS NULL
+		ORDER BY updated_at DESC, chat_uuid DESC
+		LIMIT $1 OFFSET $2
+	`
 
-func (r *ChatRepository) Update(gctx *gin.Context, data *d.Chat) error {
-	return nil
-}
+	rows, err := r.conn(ctx).QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch chats.",
+			"Failed to query chats.", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
 
-func (r *ChatRepository) Delete(gctx *gin.Context, data *d.Chat) error {
-	return nil
+	var chats []d.Chat
+	for rows.Next() {
+		var chat d.Chat
+		err := rows.Scan(
+			&chat.ChatUUID,
+			&chat.AgentUUID,
+			&chat.AuthUUID,
+			&chat.CreatedAt,
+			&chat.UpdatedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch chats.",
+				"Failed to scan chat.", "error", err)
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch chats.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	return chats, nil
 }
 
-func (r *ChatRepository) AttachMessage(gctx *gin.Context, msg *d.Message) error {
-	var chatExists bool
-	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM chats WHERE chat_uuid = $1)", msg.ChatUUID).Scan(&chatExists)
+// FetchByAuth lists either the archived or the active chats of authUUID, most
+// recently active first. When cursor is set only chats strictly older than it
+// (by updated_at, chat_uuid) are returned.
+func (r *ChatRepository) FetchByAuth(ctx context.Context, authUUID string, archived bool, cursor *d.Cursor, limit uint64) ([]d.Chat, error) {
+	query := `
+		SELECT
+			c.chat_uuid,
+			c.agent_uuid,
+			c.auth_uuid,
+			a.name,
+			COALESCE(LEFT(lm.message_content, 120), ''),
+			c.archived_at IS NOT NULL,
+			c.created_at,
+			c.updated_at
+		FROM chats c
+		INNER JOIN agents a ON c.agent_uuid = a.agent_uuid
+		LEFT JOIN LATERAL (
+			SELECT mc.message_content
+			FROM messages m
+			INNER JOIN message_contents mc ON m.message_content_uuid = mc.message_content_uuid
+			WHERE m.chat_uuid = c.chat_uuid
+			ORDER BY m.created_at DESC, m.message_uuid DESC
+			LIMIT 1
+		) lm ON TRUE
+		WHERE c.auth_uuid = $1
+		  AND c.deleted_at IS NULL
+		  AND (c.archived_at IS NOT NULL) = $5
+		  AND ($2::timestamp IS NULL OR (c.updated_at, c.chat_uuid) < ($2::timestamp, $3::uuid))
+		ORDER BY c.updated_at DESC, c.chat_uuid DESC
+		LIMIT $4
+	`
+
+	var cursorAt sql.NullTime
+	var cursorUUID sql.NullString
+	if cursor != nil {
+		cursorAt = sql.NullTime{Time: cursor.At, Valid: true}
+		cursorUUID = sql.NullString{String: cursor.UUID, Valid: true}
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, authUUID, cursorAt, cursorUUID, limit, archived)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not verify chat existence. Failed to check chat existence: %s", err.Error()))
-		return err
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch chats.",
+			"Failed to query chats of auth.", "auth_uuid", authUUID, "error", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	if !chatExists {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Chat not found. Chat with UUID %s does not exist", msg.ChatUUID))
+	var chats []d.Chat
+	for rows.Next() {
+		var chat d.Chat
+		err := rows.Scan(
+			&chat.ChatUUID,
+			&chat.AgentUUID,
+			&chat.AuthUUID,
+			&chat.AgentName,
+			&chat.LastMessagePreview,
+			&chat.Archived,
+			&chat.CreatedAt,
+			&chat.UpdatedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch chats.",
+				"Failed to scan chat.", "error", err)
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch chats.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	return chats, nil
+}
+
+// Update persists the archive state of a chat owned by data.AuthUUID. Like
+// Delete and Restore it leaves updated_at alone, which only moves with new
+// messages.
+func (r *ChatRepository) Update(ctx context.Context, data *d.Chat) error {
+	query := `
+		UPDATE chats
+		SET archived_at = CASE WHEN $3 THEN COALESCE(archived_at, NOW()) ELSE NULL END
+		WHERE chat_uuid = $1 AND auth_uuid = $2 AND deleted_at IS NULL
+		RETURNING updated_at
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.ChatUUID, data.AuthUUID, data.Archived).Scan(&data.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Chat not found.", "Chat not found.")
 		return err
 	}
 
-	tx, err := r.db.Begin()
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not begin transaction. Failed to begin transaction: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not update chat.",
+			"Failed to update chat.", "error", err)
 		return err
 	}
-	defer tx.Rollback()
 
-	insertSQL := `
-		WITH inserted_content AS (
-			INSERT INTO message_contents (message_content_uuid, message_content)
-			VALUES ($1, $2)
-			RETURNING message_content_uuid
-		)
-		INSERT INTO messages (
-			message_uuid, sender_uuid, sender_type, receiver_uuid, receiver_type,
-			chat_uuid, message_content_uuid, created_at
-		)
-		SELECT $3, $4, $5, $6, $7, $8, message_content_uuid, $9
-		FROM inserted_content
+	return nil
+}
+
+// Delete soft-deletes a chat owned by data.AuthUUID. The rows stay around
+// until PurgeDeleted removes them.
+func (r *ChatRepository) Delete(ctx context.Context, data *d.Chat) error {
+	query := `
+		UPDATE chats
+		SET deleted_at = NOW()
+		WHERE chat_uuid = $1 AND auth_uuid = $2 AND deleted_at IS NULL
 	`
 
-	_, err = tx.Exec(insertSQL,
-		msg.MessageContent.MessageContentUUID,
-		msg.MessageContent.Content,
-		msg.MessageUUID,
-		msg.SenderUUID,
-		msg.SenderType,
-		msg.ReceiverUUID,
-		msg.ReceiverType,
-		msg.ChatUUID,
-		msg.CreatedAt,
-	)
+	res, err := r.conn(ctx).ExecContext(ctx, query, data.ChatUUID, data.AuthUUID)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not delete chat.",
+			"Failed to delete chat.", "error", err)
+		return err
+	}
+
+	affected, err := res.RowsAffected()
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not attach message. Failed to insert message: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not delete chat.",
+			"Failed to read affected rows.", "error", err)
+		return err
+	}
+
+	if affected == 0 {
+		err = errs.New(errs.NotFound, "(R) Chat not found.", "Chat not found.")
 		return err
 	}
 
-	updateSQL := `
+	return nil
+}
+
+// Restore undoes Delete for a chat owned by data.AuthUUID, as long as it was
+// deleted less than retention ago.
+func (r *ChatRepository) Restore(ctx context.Context, data *d.Chat, retention time.Duration) error {
+	query := `
 		UPDATE chats
-		SET updated_at = NOW()
+		SET deleted_at = NULL
 		WHERE chat_uuid = $1
+		  AND auth_uuid = $2
+		  AND deleted_at IS NOT NULL
+		  AND deleted_at > NOW() - ($3 * INTERVAL '1 second')
+		RETURNING agent_uuid, archived_at IS NOT NULL, created_at, updated_at
 	`
 
-	_, err = tx.Exec(updateSQL, msg.ChatUUID)
-	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not update chat timestamp. Failed to update chat timestamp: %s", err.Error()))
+	err := r.conn(ctx).QueryRowContext(ctx, query, data.ChatUUID, data.AuthUUID, retention.Seconds()).Scan(
+		&data.AgentUUID,
+		&data.Archived,
+		&data.CreatedAt,
+		&data.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		err = errs.New(errs.NotFound, "(R) Chat not found.", "Chat not found.")
 		return err
 	}
 
-	if err = tx.Commit(); err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("(R) Could not commit transaction. Failed to commit transaction: %s", err.Error()))
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not restore chat.",
+			"Failed to restore chat.", "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func (r *ChatRepository) GetChatHistory(gctx *gin.Context, chatUUID string, limit uint64) ([]d.Message, error) {
+// PurgeDeleted hard-deletes chats soft-deleted more than retention ago.
+// message_contents is the parent side of the messages FK, so it is purged
+// first (cascading to messages) before the chats themselves go.
+func (r *ChatRepository) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	var purged int64
+
+	err := c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		contentsSQL := `
+			DELETE FROM message_contents mc
+			USING messages m, chats c
+			WHERE mc.message_content_uuid = m.message_content_uuid
+			  AND m.chat_uuid = c.chat_uuid
+			  AND c.deleted_at IS NOT NULL
+			  AND c.deleted_at <= NOW() - ($1 * INTERVAL '1 second')
+		`
+
+		if _, err := r.conn(ctx).ExecContext(ctx, contentsSQL, retention.Seconds()); err != nil {
+			return errs.New(
+				errs.Internal,
+				"(R) Could not purge deleted chats.",
+				"Failed to purge message contents.", "error", err)
+		}
+
+		chatsSQL := `
+			DELETE FROM chats
+			WHERE deleted_at IS NOT NULL
+			  AND deleted_at <= NOW() - ($1 * INTERVAL '1 second')
+		`
+
+		res, err := r.conn(ctx).ExecContext(ctx, chatsSQL, retention.Seconds())
+		if err != nil {
+			return errs.New(
+				errs.Internal,
+				"(R) Could not purge deleted chats.",
+				"Failed to purge chats.", "error", err)
+		}
+
+		purged, err = res.RowsAffected()
+		if err != nil {
+			return errs.New(
+				errs.Internal,
+				"(R) Could not purge deleted chats.",
+				"Failed to read affected rows.", "error", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// AttachMessage stores msg and bumps the chat's updated_at, both or neither.
+func (r *ChatRepository) AttachMessage(ctx context.Context, msg *d.Message) error {
+	return c_db.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		var chatExists bool
+		err := r.conn(ctx).QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM chats WHERE chat_uuid = $1 AND deleted_at IS NULL)", msg.ChatUUID).Scan(&chatExists)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not verify chat existence.",
+				"Failed to check chat existence.", "error", err)
+			return err
+		}
+
+		if !chatExists {
+			err = errs.New(
+				errs.NotFound,
+				"(R) Chat not found.",
+				"Chat does not exist.", "chat_uuid", msg.ChatUUID)
+			return err
+		}
+
+		insertSQL := `
+			WITH inserted_content AS (
+				INSERT INTO message_contents (message_content_uuid, message_content)
+				VALUES ($1, $2)
+				RETURNING message_content_uuid
+			)
+			INSERT INTO messages (
+				message_uuid, sender_uuid, sender_type, receiver_uuid, receiver_type,
+				chat_uuid, message_content_uuid, created_at
+			)
+			SELECT $3, $4, $5, $6, $7, $8, message_content_uuid, $9
+			FROM inserted_content
+		`
+
+		_, err = r.conn(ctx).ExecContext(ctx, insertSQL,
+			msg.MessageContent.MessageContentUUID,
+			msg.MessageContent.Content,
+			msg.MessageUUID,
+			msg.SenderUUID,
+			msg.SenderType,
+			msg.ReceiverUUID,
+			msg.ReceiverType,
+			msg.ChatUUID,
+			msg.CreatedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not attach message.",
+				"Failed to insert message.", "error", err)
+			return err
+		}
+
+		updateSQL := `
+			UPDATE chats
+			SET updated_at = NOW()
+			WHERE chat_uuid = $1
+		`
+
+		_, err = r.conn(ctx).ExecContext(ctx, updateSQL, msg.ChatUUID)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not update chat timestamp.",
+				"Failed to update chat timestamp.", "error", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (r *ChatRepository) GetChatHistory(ctx context.Context, chatUUID string, limit uint64) ([]d.Message, error) {
 	query := `
 		SELECT 
 			m.message_uuid,
@@ -240,11 +527,12 @@ func (r *ChatRepository) GetChatHistory(gctx *gin.Context, chatUUID string, limi
 		ORDER BY m.created_at ASC
 	`
 
-	rows, err := r.db.Query(query, chatUUID, limit)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, chatUUID, limit)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("Could not fetch chat history. Failed to query chat history: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch chat history.",
+			"Failed to query chat history.", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -264,25 +552,27 @@ func (r *ChatRepository) GetChatHistory(gctx *gin.Context, chatUUID string, limi
 			&msg.CreatedAt,
 		)
 		if err != nil {
-			err = c_at.BuildErrLogAtom(
-				gctx,
-				fmt.Sprintf("Could not fetch chat history. Failed to scan message: %s", err.Error()))
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch chat history.",
+				"Failed to scan message.", "error", err)
 			return nil, err
 		}
 		msgs = append(msgs, msg)
 	}
 
 	if err = rows.Err(); err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("Could not fetch chat history. Row iteration failed: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch chat history.",
+			"Row iteration failed.", "error", err)
 		return nil, err
 	}
 
 	return msgs, nil
 }
 
-func (r *ChatRepository) GetRecentMessages(gctx *gin.Context, chatUUID string, since time.Time, limit uint64) ([]d.Message, error) {
+func (r *ChatRepository) GetRecentMessages(ctx context.Context, chatUUID string, since time.Time, limit uint64) ([]d.Message, error) {
 	query := `
 		SELECT 
 			m.message_uuid,
@@ -301,11 +591,12 @@ func (r *ChatRepository) GetRecentMessages(gctx *gin.Context, chatUUID string, s
 		LIMIT $3
 	`
 
-	rows, err := r.db.Query(query, chatUUID, since, limit)
+	rows, err := r.conn(ctx).QueryContext(ctx, query, chatUUID, since, limit)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("Could not fetch recent messages. Failed to query recent messages: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch recent messages.",
+			"Failed to query recent messages.", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -325,20 +616,107 @@ func (r *ChatRepository) GetRecentMessages(gctx *gin.Context, chatUUID string, s
 			&msg.CreatedAt,
 		)
 		if err != nil {
-			err = c_at.BuildErrLogAtom(
-				gctx,
-				fmt.Sprintf("Could not fetch recent messages. Failed to scan message: %s", err.Error()))
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch recent messages.",
+				"Failed to scan message.", "error", err)
 			return nil, err
 		}
 		msgs = append(msgs, msg)
 	}
 
 	if err = rows.Err(); err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("Could not fetch recent messages. Row iteration failed: %s", err.Error()))
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch recent messages.",
+			"Row iteration failed.", "error", err)
 		return nil, err
 	}
 
 	return msgs, nil
 }
+
+// FetchMessages pages through a chat by (created_at, message_uuid). With
+// direction MessagesBefore it walks back from cursor (or from the newest
+// message), with MessagesAfter it walks forward from cursor (or from the
+// oldest one). Messages are always returned in chronological order.
+func (r *ChatRepository) FetchMessages(ctx context.Context, chatUUID string, cursor *d.Cursor, direction string, limit uint64) ([]d.Message, error) {
+	cmp, order := "<", "DESC"
+	if direction == d.MessagesAfter {
+		cmp, order = ">", "ASC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			m.message_uuid,
+			m.sender_uuid,
+			m.sender_type,
+			m.receiver_uuid,
+			m.receiver_type,
+			m.chat_uuid,
+			m.message_content_uuid,
+			mc.message_content,
+			m.created_at
+		FROM messages m
+		INNER JOIN message_contents mc ON m.message_content_uuid = mc.message_content_uuid
+		WHERE m.chat_uuid = $1
+		  AND ($2::timestamp IS NULL OR (m.created_at, m.message_uuid) %s ($2::timestamp, $3::uuid))
+		ORDER BY m.created_at %s, m.message_uuid %s
+		LIMIT $4
+	`, cmp, order, order)
+
+	var cursorAt sql.NullTime
+	var cursorUUID sql.NullString
+	if cursor != nil {
+		cursorAt = sql.NullTime{Time: cursor.At, Valid: true}
+		cursorUUID = sql.NullString{String: cursor.UUID, Valid: true}
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, chatUUID, cursorAt, cursorUUID, limit)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch messages.",
+			"Failed to query messages.", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []d.Message
+	for rows.Next() {
+		var msg d.Message
+		err := rows.Scan(
+			&msg.MessageUUID,
+			&msg.SenderUUID,
+			&msg.SenderType,
+			&msg.ReceiverUUID,
+			&msg.ReceiverType,
+			&msg.ChatUUID,
+			&msg.MessageContent.MessageContentUUID,
+			&msg.MessageContent.Content,
+			&msg.CreatedAt,
+		)
+		if err != nil {
+			err = errs.New(
+				errs.Internal,
+				"(R) Could not fetch messages.",
+				"Failed to scan message.", "error", err)
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not fetch messages.",
+			"Row iteration failed.", "error", err)
+		return nil, err
+	}
+
+	if direction != d.MessagesAfter {
+		slices.Reverse(msgs)
+	}
+
+	return msgs, nil
+}
@@ -1,16 +1,21 @@
 package services
 
 import (
+	ch_at "aigents-base/internal/chat/atoms"
 	d "aigents-base/internal/chat/domain"
 	chitf "aigents-base/internal/chat/interfaces"
+	ag_at "aigents-base/internal/agents/atoms"
+	agd "aigents-base/internal/agents/domain"
 	agitf "aigents-base/internal/agents/interfaces"
-	c_at "aigents-base/internal/common/atoms"
+	citf "aigents-base/internal/common/interfaces"
+	errs "aigents-base/internal/common/errs"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	ws "github.com/gorilla/websocket"
 )
 
@@ -27,6 +32,11 @@ type PythonLLMRequest struct {
 	AgentDescription string      `json:"agent_description"`
 	CategoryID       uint64      `json:"category_id"`
 	SystemPrompt     string      `json:"system_prompt"`
+	Temperature      *float64    `json:"temperature,omitempty"`
+	MaxTokens        *uint64     `json:"max_tokens,omitempty"`
+	Persona          string      `json:"persona,omitempty"`
+	StopSequences    []string    `json:"stop_sequences,omitempty"`
+	ResponseLanguage string      `json:"response_language,omitempty"`
 	ChatHistory      []d.Message `json:"chat_history,omitempty"`
 	SyncMode         string      `json:"sync_mode"`
 	AuthUUID         string      `json:"auth_uuid,omitempty"`
@@ -89,12 +99,16 @@ func NewConnectionPool(wsURL string, size int) *ConnectionPool {
 }
 
 func (p *ConnectionPool) Get(authUUID string) (*PooledConnection, error) {
-	if p.closed {
+	if p.isClosed() {
 		return nil, fmt.Errorf("connection pool is closed")
 	}
 
 	select {
-	case pooledConn := <-p.pool:
+	case pooledConn, ok := <-p.pool:
+		if !ok {
+			return nil, fmt.Errorf("connection pool is closed")
+		}
+
 		if p.isHealthy(pooledConn) {
 			pooledConn.LastUsed = time.Now()
 			pooledConn.UseCount++
@@ -116,7 +130,11 @@ func (p *ConnectionPool) Get(authUUID string) (*PooledConnection, error) {
 	if currentCount >= p.maxConns {
 		p.mu.Unlock()
 		select {
-		case pooledConn := <-p.pool:
+		case pooledConn, ok := <-p.pool:
+			if !ok {
+				return nil, fmt.Errorf("connection pool is closed")
+			}
+
 			if p.isHealthy(pooledConn) {
 				pooledConn.LastUsed = time.Now()
 				pooledConn.UseCount++
@@ -162,8 +180,13 @@ func (p *ConnectionPool) createConnection(authUUID string) (*PooledConnection, e
 	}
 
 	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		conn.Close()
+		return nil, fmt.Errorf("connection pool is closed")
+	}
 	p.activeConns[connectionID] = pooledConn
-	p.mu.Unlock()
 
 	return pooledConn, nil
 }
@@ -214,7 +237,7 @@ func (p *ConnectionPool) isHealthy(pooledConn *PooledConnection) bool {
 }
 
 func (p *ConnectionPool) Put(pooledConn *PooledConnection) {
-	if pooledConn == nil || p.closed {
+	if pooledConn == nil {
 		return
 	}
 
@@ -227,11 +250,32 @@ func (p *ConnectionPool) Put(pooledConn *PooledConnection) {
 		return
 	}
 
-	select {
-	case p.pool <- pooledConn:
-	default:
-		pooledConn.Conn.Close()
+	p.offer(pooledConn)
+}
+
+// offer hands an idle connection back to the pool, closing it when the pool
+// is full or closed. The send happens under mu, which Close holds while
+// closing the channel, so it never races with it.
+func (p *ConnectionPool) offer(pooledConn *PooledConnection) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.closed {
+		select {
+		case p.pool <- pooledConn:
+			return
+		default:
+		}
 	}
+
+	pooledConn.Conn.Close()
+}
+
+func (p *ConnectionPool) isClosed() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.closed
 }
 
 func (p *ConnectionPool) Discard(pooledConn *PooledConnection) {
@@ -275,18 +319,17 @@ func (p *ConnectionPool) cleanupStaleConnections() {
 			poolSize := len(p.pool)
 			for i := 0; i < poolSize; i++ {
 				select {
-				case conn := <-p.pool:
+				case conn, ok := <-p.pool:
+					if !ok {
+						return
+					}
+
 					if !p.isHealthy(conn) {
 						conn.Conn.Close()
 					} else {
-						select {
-						case p.pool <- conn:
-						default:
-							conn.Conn.Close()
-						}
+						p.offer(conn)
 					}
 				default:
-					break
 				}
 			}
 		}
@@ -300,23 +343,57 @@ func (p *ConnectionPool) Close() {
 		return
 	}
 	p.closed = true
-	p.mu.Unlock()
-
-	p.cancel()
 
-	p.mu.Lock()
 	for id, conn := range p.activeConns {
 		conn.Conn.Close()
 		delete(p.activeConns, id)
 	}
-	p.mu.Unlock()
 
 	close(p.pool)
+	p.mu.Unlock()
+
+	p.cancel()
+
 	for conn := range p.pool {
 		conn.Conn.Close()
 	}
 }
 
+// Ping asks the AI service for its stats over a throwaway connection, so
+// probes neither take a pooled connection nor wait behind busy ones.
+func (p *ConnectionPool) Ping(ctx context.Context) error {
+	if p.isClosed() {
+		return fmt.Errorf("connection pool is closed")
+	}
+
+	dialer := ws.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, _, err := dialer.DialContext(ctx, p.wsURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to dial: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+		conn.SetReadDeadline(deadline)
+	}
+
+	if err := conn.WriteJSON(PythonLLMRequest{Command: "stats"}); err != nil {
+		return fmt.Errorf("failed to send stats: %w", err)
+	}
+
+	var response PythonLLMResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		return fmt.Errorf("failed to read stats response: %w", err)
+	}
+
+	if response.Type != "stats" {
+		return fmt.Errorf("unexpected response type: %s", response.Type)
+	}
+
+	return nil
+}
+
 func (p *ConnectionPool) GetStats() map[string]interface{} {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -332,25 +409,95 @@ func (p *ConnectionPool) GetStats() map[string]interface{} {
 type ChatService struct {
 	r             chitf.ChatRepositoryITF
 	agr           agitf.AgentRepositoryITF
+	tx            citf.TxManagerITF
 	lastMsgsLimit uint64
 	connPool      *ConnectionPool
+	retention     time.Duration
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	// streams counts SendMessage and InitChat calls in flight; once
+	// closing is set no new ones start, so waiting on it is safe.
+	streamsMu sync.Mutex
+	closing   bool
+	streams   sync.WaitGroup
 }
 
-func NewChatService(repo chitf.ChatRepositoryITF, agrepo agitf.AgentRepositoryITF, wsURL string, lastMsgsLimit uint64, poolSize int) chitf.ChatServiceITF {
-	return &ChatService{
+func NewChatService(repo chitf.ChatRepositoryITF, agrepo agitf.AgentRepositoryITF, tx citf.TxManagerITF, wsURL string, lastMsgsLimit uint64, poolSize int, retention, purgeInterval time.Duration) chitf.ChatServiceITF {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ChatService{
 		r:             repo,
 		agr:           agrepo,
+		tx:            tx,
 		lastMsgsLimit: lastMsgsLimit,
 		connPool:      NewConnectionPool(wsURL, poolSize),
+		retention:     retention,
+		ctx:           ctx,
+		cancel:        cancel,
 	}
+
+	if purgeInterval > 0 {
+		go s.purgeDeletedChats(purgeInterval)
+	} else {
+		slog.Warn("Chat purge disabled, deleted chats are kept", "job", "chat-purge", "interval", purgeInterval.String())
+	}
+
+	return s
 }
 
-func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID string, streamCallback func(chunk string)) error {
+// purgeDeletedChats hard-deletes chats whose retention window has expired,
+// once per interval, until Cleanup is called. interval must be positive.
+func (s *ChatService) purgeDeletedChats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.r.PurgeDeleted(s.ctx, s.retention); err != nil {
+				slog.Error("Could not purge deleted chats.", "job", "chat-purge", "error", err)
+			}
+		}
+	}
+}
+
+// authorizeChat is the ownership policy every chat read/write goes through.
+// The chat is loaded scoped to authUUID, so one owned by another auth comes
+// back as not found, exactly like a missing one.
+func (s *ChatService) authorizeChat(ctx context.Context, chat *d.Chat, authUUID string) error {
+	if authUUID == "" {
+		return errs.New(errs.NotFound, "(R) Chat not found.", "Chat not found.")
+	}
+
+	chat.AuthUUID = authUUID
+	return s.r.GetByID(ctx, chat)
+}
+
+func (s *ChatService) SendMessage(ctx context.Context, data *d.Message, authUUID string, streamCallback func(chunk string)) error {
+	if err := s.beginStream(); err != nil {
+		return err
+	}
+	defer s.streams.Done()
+
+	chat := &d.Chat{ChatUUID: data.ChatUUID}
+	if err := s.authorizeChat(ctx, chat, authUUID); err != nil {
+		if errors.Is(err, errs.NotFound) {
+			err = errs.New(
+				errs.NotFound,
+				"(S) Chat not found.",
+				"Chat is not available to auth.", "chat_uuid", data.ChatUUID, "auth_uuid", authUUID)
+		}
+		return err
+	}
+
 	pooledConn, err := s.connPool.Get(authUUID)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("Could not connect to AI service. Failed to get connection: %s", err.Error()))
+		err = errs.New(
+			errs.Unavailable,
+			"(S) Could not connect to AI service.",
+			"Failed to get AI service connection.", "error", err)
 		return err
 	}
 
@@ -363,12 +510,7 @@ func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID s
 		}
 	}()
 
-	chat := &d.Chat{ChatUUID: data.ChatUUID}
-	if err := s.r.GetByID(gctx, chat); err != nil {
-		return err
-	}
-
-	agent, err := s.agr.GetAgentByUUID(gctx, chat.AgentUUID)
+	agent, err := s.agr.GetAgentByUUID(ctx, chat.AgentUUID)
 	if err != nil {
 		return err
 	}
@@ -376,31 +518,13 @@ func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID s
 	data.ReceiverUUID = agent.AgentUUID
 	data.ReceiverType = "AGENT"
 
-	if err := s.r.AttachMessage(gctx, data); err != nil {
-		return err
-	}
-
-	chat.History, err = s.r.GetChatHistory(gctx, chat.ChatUUID, s.lastMsgsLimit+1)
+	historyForPython, err := s.r.GetChatHistory(ctx, chat.ChatUUID, s.lastMsgsLimit)
 	if err != nil {
 		return err
 	}
 
-	historyForPython := make([]d.Message, 0, len(chat.History))
-	for _, msg := range chat.History {
-		if msg.MessageUUID != data.MessageUUID {
-			historyForPython = append(historyForPython, msg)
-		}
-	}
-
 	syncMode := s.determineChatHistoryStrategy(chat, uint64(len(historyForPython)))
 
-	systemPrompt := "You are a helpful assistant."
-	if agent.AgentConfig.AgentSystem.SystemPreset != nil {
-		if prompt, ok := agent.AgentConfig.AgentSystem.SystemPreset["system_prompt"].(string); ok {
-			systemPrompt = prompt
-		}
-	}
-
 	request := PythonLLMRequest{
 		ChatUUID:         data.ChatUUID,
 		Content:          data.MessageContent.Content,
@@ -411,18 +535,20 @@ func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID s
 		AgentUUID:        agent.AgentUUID,
 		AgentName:        agent.Name,
 		AgentDescription: agent.Description,
-		CategoryID:       1,
-		SystemPrompt:     systemPrompt,
+		CategoryID:       agent.AgentConfig.Category.CategoryID,
 		ChatHistory:      historyForPython,
 		SyncMode:         syncMode,
 	}
 
+	applySystemPreset(&request, agent)
+
 	pooledConn.Conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
 	if err := pooledConn.Conn.WriteJSON(request); err != nil {
 		shouldReturn = false
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("AI service is unavailable. Failed to send request to Python service: %s", err.Error()))
+		err = errs.New(
+			errs.Unavailable,
+			"(S) AI service is unavailable.",
+			"Failed to send request to AI service.", "error", err)
 		return err
 	}
 	pooledConn.Conn.SetWriteDeadline(time.Time{})
@@ -437,16 +563,18 @@ func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID s
 		err = pooledConn.Conn.ReadJSON(&response)
 		if err != nil {
 			shouldReturn = false
-			err = c_at.BuildErrLogAtom(
-				gctx,
-				fmt.Sprintf("Failed to receive AI response. Failed to read response from Python service: %s", err.Error()))
+			err = errs.New(
+				errs.Unavailable,
+				"(S) Failed to receive AI response.",
+				"Failed to read response from AI service.", "error", err)
 			return err
 		}
 
 		if response.Error != "" {
-			err = c_at.BuildErrLogAtom(
-				gctx,
-				fmt.Sprintf("AI service encountered an error. Python service error: %s", response.Error))
+			err = errs.New(
+				errs.Unavailable,
+				"(S) AI service encountered an error.",
+				"AI service answered with an error.", "error", response.Error)
 			return err
 		}
 
@@ -475,7 +603,15 @@ func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID s
 		CreatedAt:      time.Now(),
 	}
 
-	if err := s.r.AttachMessage(gctx, agentMsg); err != nil {
+	// The prompt and its reply are stored together once the reply is
+	// complete, even if the client went away while it streamed.
+	err = s.tx.WithinTx(context.WithoutCancel(ctx), func(ctx context.Context) error {
+		if err := s.r.AttachMessage(ctx, data); err != nil {
+			return err
+		}
+		return s.r.AttachMessage(ctx, agentMsg)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -483,19 +619,31 @@ func (s *ChatService) SendMessage(gctx *gin.Context, data *d.Message, authUUID s
 	return nil
 }
 
-func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback func(chunk string)) error {
+func (s *ChatService) InitChat(ctx context.Context, data *d.Chat, streamCallback func(chunk string)) error {
+	if err := s.beginStream(); err != nil {
+		return err
+	}
+	defer s.streams.Done()
+
 	if len(data.History) == 0 {
-		err := c_at.BuildErrLogAtom(
-			gctx,
+		err := errs.New(
+			errs.Validation,
+			"(S) At least one message is required.",
 			"At least one message is required.")
 		return err
 	}
 
+	agent, err := s.agr.GetAgentByUUID(ctx, data.AgentUUID)
+	if err != nil {
+		return err
+	}
+
 	pooledConn, err := s.connPool.Get(data.AuthUUID)
 	if err != nil {
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("Could not connect to AI service. Failed to get connection: %s", err.Error()))
+		err = errs.New(
+			errs.Unavailable,
+			"(S) Could not connect to AI service.",
+			"Failed to get AI service connection.", "error", err)
 		return err
 	}
 
@@ -516,15 +664,6 @@ func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback f
 		data.UpdatedAt = now
 	}
 
-	if err := s.r.Create(gctx, data); err != nil {
-		return err
-	}
-
-	agent, err := s.agr.GetAgentByUUID(gctx, data.AgentUUID)
-	if err != nil {
-		return err
-	}
-
 	userMessage := data.History[0]
 	userMessage.ChatUUID = data.ChatUUID
 
@@ -532,17 +671,6 @@ func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback f
 		userMessage.CreatedAt = now
 	}
 
-	if err := s.r.AttachMessage(gctx, &userMessage); err != nil {
-		return err
-	}
-
-	systemPrompt := "You are a helpful assistant."
-	if agent.AgentConfig.AgentSystem.SystemPreset != nil {
-		if prompt, ok := agent.AgentConfig.AgentSystem.SystemPreset["system_prompt"].(string); ok {
-			systemPrompt = prompt
-		}
-	}
-
 	request := PythonLLMRequest{
 		ChatUUID:         data.ChatUUID,
 		Content:          userMessage.MessageContent.Content,
@@ -553,18 +681,20 @@ func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback f
 		AgentUUID:        agent.AgentUUID,
 		AgentName:        agent.Name,
 		AgentDescription: agent.Description,
-		CategoryID:       1,
-		SystemPrompt:     systemPrompt,
+		CategoryID:       agent.AgentConfig.Category.CategoryID,
 		ChatHistory:      []d.Message{},
 		SyncMode:         "auto",
 	}
 
+	applySystemPreset(&request, agent)
+
 	pooledConn.Conn.SetWriteDeadline(time.Now().Add(15 * time.Second))
 	if err := pooledConn.Conn.WriteJSON(request); err != nil {
 		shouldReturn = false
-		err = c_at.BuildErrLogAtom(
-			gctx,
-			fmt.Sprintf("AI service is unavailable. Failed to send request to Python service: %s", err.Error()))
+		err = errs.New(
+			errs.Unavailable,
+			"(S) AI service is unavailable.",
+			"Failed to send request to AI service.", "error", err)
 		return err
 	}
 	pooledConn.Conn.SetWriteDeadline(time.Time{})
@@ -579,16 +709,18 @@ func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback f
 		err = pooledConn.Conn.ReadJSON(&response)
 		if err != nil {
 			shouldReturn = false
-			err = c_at.BuildErrLogAtom(
-				gctx,
-				fmt.Sprintf("Failed to receive AI response. Failed to read response from Python service: %s", err.Error()))
+			err = errs.New(
+				errs.Unavailable,
+				"(S) Failed to receive AI response.",
+				"Failed to read response from AI service.", "error", err)
 			return err
 		}
 
 		if response.Error != "" {
-			err = c_at.BuildErrLogAtom(
-				gctx,
-				fmt.Sprintf("AI service encountered an error. Python service error: %s", response.Error))
+			err = errs.New(
+				errs.Unavailable,
+				"(S) AI service encountered an error.",
+				"AI service answered with an error.", "error", response.Error)
 			return err
 		}
 
@@ -617,7 +749,19 @@ func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback f
 		CreatedAt:      time.Now(),
 	}
 
-	if err := s.r.AttachMessage(gctx, agentMsg); err != nil {
+	// The chat only comes to exist with its first exchange complete, so a
+	// failed stream leaves no empty chat behind; a finished one is stored
+	// even if the client went away meanwhile.
+	err = s.tx.WithinTx(context.WithoutCancel(ctx), func(ctx context.Context) error {
+		if err := s.r.Create(ctx, data); err != nil {
+			return err
+		}
+		if err := s.r.AttachMessage(ctx, &userMessage); err != nil {
+			return err
+		}
+		return s.r.AttachMessage(ctx, agentMsg)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -626,6 +770,26 @@ func (s *ChatService) InitChat(gctx *gin.Context, data *d.Chat, streamCallback f
 	return nil
 }
 
+// applySystemPreset forwards the agent's system preset to the AI service,
+// layered over its category preset when the agent opted into it.
+func applySystemPreset(req *PythonLLMRequest, agent *agd.Agent) {
+	preset := agent.AgentConfig.AgentSystem.SystemPreset
+	if agent.AgentConfig.CategoryPresetEnabled {
+		preset = ag_at.MergeSystemPresetAtom(agent.AgentConfig.Category.AgentSystemPreset.SystemPreset, preset)
+	}
+
+	req.SystemPrompt = preset.SystemPrompt
+	if req.SystemPrompt == "" {
+		req.SystemPrompt = "You are a helpful assistant."
+	}
+
+	req.Temperature = preset.Temperature
+	req.MaxTokens = preset.MaxTokens
+	req.Persona = preset.Persona
+	req.StopSequences = preset.StopSequences
+	req.ResponseLanguage = preset.ResponseLanguage
+}
+
 func (s *ChatService) determineChatHistoryStrategy(data *d.Chat, msgsLen uint64) string {
 	if time.Since(data.UpdatedAt) < 5*time.Minute {
 		return "auto"
@@ -636,26 +800,157 @@ func (s *ChatService) determineChatHistoryStrategy(data *d.Chat, msgsLen uint64)
 	return "auto"
 }
 
-func (s *ChatService) Create(gctx *gin.Context, data *d.Chat) error {
+func (s *ChatService) Create(ctx context.Context, data *d.Chat) error {
 	return nil
 }
 
-func (s *ChatService) GetByID(gctx *gin.Context, data *d.Chat) error {
-	return s.r.GetByID(gctx, data)
+// GetByID expects data.AuthUUID to hold the requesting auth and answers 404
+// both for missing chats and for chats owned by someone else.
+func (s *ChatService) GetByID(ctx context.Context, data *d.Chat) error {
+	return ownedChatErr(data, s.authorizeChat(ctx, data, data.AuthUUID))
 }
 
-func (s *ChatService) Fetch(gctx *gin.Context, limit, offset uint64) ([]d.Chat, error) {
-	return s.r.Fetch(gctx, limit, offset)
+func (s *ChatService) FetchByAuth(ctx context.Context, authUUID string, archived bool, cursor *d.Cursor, limit uint64) (*d.ChatPage, error) {
+	chats, err := s.r.FetchByAuth(ctx, authUUID, archived, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &d.ChatPage{Chats: chats}
+	if uint64(len(chats)) > limit {
+		page.Chats = chats[:limit]
+		last := page.Chats[len(page.Chats)-1]
+		page.NextCursor = ch_at.EncodeCursorAtom(last.UpdatedAt, last.ChatUUID)
+	}
+
+	if page.Chats == nil {
+		page.Chats = []d.Chat{}
+	}
+
+	return page, nil
 }
 
-func (s *ChatService) Update(gctx *gin.Context, data *d.Chat) error {
-	return s.r.Update(gctx, data)
+// FetchMessages pages through the history of a chat owned by data.AuthUUID.
+// HasMore tells whether more messages exist past the page in the requested
+// direction; BeforeCursor/AfterCursor point at its oldest/newest message.
+func (s *ChatService) FetchMessages(ctx context.Context, data *d.Chat, cursor *d.Cursor, direction string, limit uint64) (*d.MessagePage, error) {
+	if err := s.GetByID(ctx, data); err != nil {
+		return nil, err
+	}
+
+	msgs, err := s.r.FetchMessages(ctx, data.ChatUUID, cursor, direction, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &d.MessagePage{}
+	if uint64(len(msgs)) > limit {
+		page.HasMore = true
+		if direction == d.MessagesAfter {
+			msgs = msgs[:limit]
+		} else {
+			msgs = msgs[1:]
+		}
+	}
+
+	if len(msgs) > 0 {
+		first, last := msgs[0], msgs[len(msgs)-1]
+		page.BeforeCursor = ch_at.EncodeCursorAtom(first.CreatedAt, first.MessageUUID)
+		page.AfterCursor = ch_at.EncodeCursorAtom(last.CreatedAt, last.MessageUUID)
+	} else {
+		msgs = []d.Message{}
+	}
+
+	page.Messages = msgs
+	return page, nil
 }
 
-func (s *ChatService) Delete(gctx *gin.Context, data *d.Chat) error {
-	return s.r.Delete(gctx, data)
+func (s *ChatService) Fetch(ctx context.Context, limit, offset uint64) ([]d.Chat, error) {
+	return s.r.Fetch(ctx, limit, offset)
+}
+
+// Update changes the archive state of a chat owned by data.AuthUUID.
+func (s *ChatService) Update(ctx context.Context, data *d.Chat) error {
+	return ownedChatErr(data, s.r.Update(ctx, data))
+}
+
+// Delete soft-deletes a chat owned by data.AuthUUID; it can be restored until
+// the retention window passes and the purge job removes it for good.
+func (s *ChatService) Delete(ctx context.Context, data *d.Chat) error {
+	return ownedChatErr(data, s.r.Delete(ctx, data))
+}
+
+func (s *ChatService) Restore(ctx context.Context, data *d.Chat) error {
+	return ownedChatErr(data, s.r.Restore(ctx, data, s.retention))
+}
+
+// ownedChatErr narrows a failure on an owned chat: a scoped query that
+// matched nothing becomes a not found for the requesting auth.
+func ownedChatErr(data *d.Chat, err error) error {
+	if errors.Is(err, errs.NotFound) {
+		err = errs.New(
+			errs.NotFound,
+			"(S) Chat not found.",
+			"Chat is not available to auth.", "chat_uuid", data.ChatUUID, "auth_uuid", data.AuthUUID)
+	}
+
+	return err
+}
+
+// Ping tells whether the AI service is reachable and answering.
+func (s *ChatService) Ping(ctx context.Context) error {
+	return s.connPool.Ping(ctx)
+}
+
+// beginStream registers a stream in flight, refusing new ones once the
+// service is shutting down.
+func (s *ChatService) beginStream() error {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	if s.closing {
+		err := errs.New(
+			errs.Unavailable,
+			"(S) Server is shutting down, try again shortly.",
+			"Stream refused during shutdown.")
+		return err
+	}
+
+	s.streams.Add(1)
+	return nil
+}
+
+func (s *ChatService) stopStreams() {
+	s.streamsMu.Lock()
+	s.closing = true
+	s.streamsMu.Unlock()
+}
+
+// Drain refuses new streams and waits for the ones in flight to finish,
+// saving their replies, until ctx is done.
+func (s *ChatService) Drain(ctx context.Context) error {
+	s.stopStreams()
+
+	done := make(chan struct{})
+	go func() {
+		s.streams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
+// Cleanup stops the purge job and closes the AI connection pool, then waits
+// for any stream still running: with its connection gone it fails at the
+// next read, so the caller can close the database safely afterwards.
 func (s *ChatService) Cleanup() {
+	s.stopStreams()
+	s.cancel()
 	s.connPool.Close()
+	s.streams.Wait()
 }
@@ -0,0 +1,262 @@
+package services
+
+import (
+	ch_at "aigents-base/internal/chat/atoms"
+	d "aigents-base/internal/chat/domain"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeChatRepository holds chats by uuid and scopes every lookup and write
+// to data.AuthUUID, the way the SQL filters on auth_uuid. Listings hand back
+// list and messages in the order the SQL sorts them, at most limit rows.
+type fakeChatRepository struct {
+	chats    map[string]d.Chat
+	list     []d.Chat
+	messages []d.Message
+}
+
+func (r *fakeChatRepository) owned(data *d.Chat) (d.Chat, error) {
+	chat, ok := r.chats[data.ChatUUID]
+	if !ok || chat.AuthUUID != data.AuthUUID {
+		return d.Chat{}, errs.New(errs.NotFound, "(R) Chat not found.", "Chat not found.")
+	}
+
+	return chat, nil
+}
+
+func (r *fakeChatRepository) Create(ctx context.Context, data *d.Chat) error {
+	return nil
+}
+
+func (r *fakeChatRepository) GetByID(ctx context.Context, data *d.Chat) error {
+	chat, err := r.owned(data)
+	if err != nil {
+		return err
+	}
+
+	*data = chat
+	return nil
+}
+
+func (r *fakeChatRepository) Fetch(ctx context.Context, limit, offset uint64) ([]d.Chat, error) {
+	return nil, nil
+}
+
+func (r *fakeChatRepository) Update(ctx context.Context, data *d.Chat) error {
+	_, err := r.owned(data)
+	return err
+}
+
+func (r *fakeChatRepository) Delete(ctx context.Context, data *d.Chat) error {
+	_, err := r.owned(data)
+	return err
+}
+
+func (r *fakeChatRepository) AttachMessage(ctx context.Context, msg *d.Message) error {
+	return nil
+}
+
+func (r *fakeChatRepository) GetChatHistory(ctx context.Context, chatUUID string, limit uint64) ([]d.Message, error) {
+	return nil, nil
+}
+
+func (r *fakeChatRepository) GetRecentMessages(ctx context.Context, chatUUID string, since time.Time, limit uint64) ([]d.Message, error) {
+	return nil, nil
+}
+
+func (r *fakeChatRepository) FetchByAuth(ctx context.Context, authUUID string, archived bool, cursor *d.Cursor, limit uint64) ([]d.Chat, error) {
+	if uint64(len(r.list)) > limit {
+		return r.list[:limit], nil
+	}
+	return r.list, nil
+}
+
+func (r *fakeChatRepository) Restore(ctx context.Context, data *d.Chat, retention time.Duration) error {
+	_, err := r.owned(data)
+	return err
+}
+
+func (r *fakeChatRepository) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+// FetchMessages returns the newest limit messages going before and the
+// oldest going after, in chronological order either way.
+func (r *fakeChatRepository) FetchMessages(ctx context.Context, chatUUID string, cursor *d.Cursor, direction string, limit uint64) ([]d.Message, error) {
+	if uint64(len(r.messages)) <= limit {
+		return r.messages, nil
+	}
+
+	if direction == d.MessagesAfter {
+		return r.messages[:limit], nil
+	}
+	return r.messages[uint64(len(r.messages))-limit:], nil
+}
+
+func TestForeignChatLooksMissing(t *testing.T) {
+	repo := &fakeChatRepository{chats: map[string]d.Chat{
+		"chat-1": {ChatUUID: "chat-1", AuthUUID: "owner"},
+	}}
+	sv := &ChatService{r: repo}
+	ctx := context.Background()
+
+	calls := map[string]func(chatUUID string) error{
+		"SendMessage": func(chatUUID string) error {
+			return sv.SendMessage(ctx, &d.Message{ChatUUID: chatUUID}, "intruder", nil)
+		},
+		"GetByID": func(chatUUID string) error {
+			return sv.GetByID(ctx, &d.Chat{ChatUUID: chatUUID, AuthUUID: "intruder"})
+		},
+		"FetchMessages": func(chatUUID string) error {
+			_, err := sv.FetchMessages(ctx, &d.Chat{ChatUUID: chatUUID, AuthUUID: "intruder"}, nil, d.MessagesBefore, 10)
+			return err
+		},
+		"Update": func(chatUUID string) error {
+			return sv.Update(ctx, &d.Chat{ChatUUID: chatUUID, AuthUUID: "intruder"})
+		},
+		"Delete": func(chatUUID string) error {
+			return sv.Delete(ctx, &d.Chat{ChatUUID: chatUUID, AuthUUID: "intruder"})
+		},
+		"Restore": func(chatUUID string) error {
+			return sv.Restore(ctx, &d.Chat{ChatUUID: chatUUID, AuthUUID: "intruder"})
+		},
+	}
+
+	for name, call := range calls {
+		foreign, missing := call("chat-1"), call("chat-2")
+
+		if !errors.Is(foreign, errs.NotFound) || !errors.Is(missing, errs.NotFound) {
+			t.Errorf("%s: foreign = %v, missing = %v, want NotFound for both", name, foreign, missing)
+			continue
+		}
+
+		fe, _ := errs.As(foreign)
+		me, _ := errs.As(missing)
+		if fe.Msg != me.Msg {
+			t.Errorf("%s: foreign says %q, missing says %q", name, fe.Msg, me.Msg)
+		}
+	}
+
+	if chat := repo.chats["chat-1"]; chat.AuthUUID != "owner" {
+		t.Errorf("chat-1 owner = %q after the calls", chat.AuthUUID)
+	}
+}
+
+func TestSendMessageWithoutAuth(t *testing.T) {
+	sv := &ChatService{r: &fakeChatRepository{chats: map[string]d.Chat{
+		"chat-1": {ChatUUID: "chat-1", AuthUUID: ""},
+	}}}
+
+	// an empty auth must not match chats whose owner column is empty
+	err := sv.SendMessage(context.Background(), &d.Message{ChatUUID: "chat-1"}, "", nil)
+	if !errors.Is(err, errs.NotFound) {
+		t.Fatalf("error = %v, want NotFound", err)
+	}
+}
+
+func TestFetchByAuthNextCursor(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var chats []d.Chat
+	for i, id := range []string{"chat-a", "chat-b", "chat-c"} {
+		chats = append(chats, d.Chat{ChatUUID: id, UpdatedAt: base.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name       string
+		list       []d.Chat
+		limit      uint64
+		wantChats  int
+		wantCursor string
+	}{
+		{"more than a page", chats, 2, 2, ch_at.EncodeCursorAtom(chats[1].UpdatedAt, "chat-b")},
+		{"exactly a page", chats, 3, 3, ""},
+		{"less than a page", chats, 5, 3, ""},
+		{"no chats", nil, 5, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &ChatService{r: &fakeChatRepository{list: tt.list}}
+
+			page, err := sv.FetchByAuth(context.Background(), "owner", false, nil, tt.limit)
+			if err != nil {
+				t.Fatalf("FetchByAuth: %v", err)
+			}
+
+			if len(page.Chats) != tt.wantChats || page.NextCursor != tt.wantCursor {
+				t.Errorf("page = %d chats, cursor %q; want %d, %q", len(page.Chats), page.NextCursor, tt.wantChats, tt.wantCursor)
+			}
+
+			// an empty page still encodes as [] rather than null
+			if page.Chats == nil {
+				t.Error("Chats is nil")
+			}
+		})
+	}
+}
+
+func TestFetchMessagesHasMore(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var msgs []d.Message
+	for i, id := range []string{"msg-1", "msg-2", "msg-3"} {
+		msgs = append(msgs, d.Message{MessageUUID: id, CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name      string
+		direction string
+		limit     uint64
+		wantFirst string
+		wantLast  string
+		wantMore  bool
+	}{
+		{"older page left", d.MessagesBefore, 2, "msg-2", "msg-3", true},
+		{"newer page left", d.MessagesAfter, 2, "msg-1", "msg-2", true},
+		{"last page before", d.MessagesBefore, 3, "msg-1", "msg-3", false},
+		{"last page after", d.MessagesAfter, 5, "msg-1", "msg-3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeChatRepository{
+				chats:    map[string]d.Chat{"chat-1": {ChatUUID: "chat-1", AuthUUID: "owner"}},
+				messages: msgs,
+			}
+			sv := &ChatService{r: repo}
+
+			chat := &d.Chat{ChatUUID: "chat-1", AuthUUID: "owner"}
+			page, err := sv.FetchMessages(context.Background(), chat, nil, tt.direction, tt.limit)
+			if err != nil {
+				t.Fatalf("FetchMessages: %v", err)
+			}
+
+			first, last := page.Messages[0], page.Messages[len(page.Messages)-1]
+			if first.MessageUUID != tt.wantFirst || last.MessageUUID != tt.wantLast || page.HasMore != tt.wantMore {
+				t.Errorf("page = %s..%s, has more %v; want %s..%s, %v",
+					first.MessageUUID, last.MessageUUID, page.HasMore, tt.wantFirst, tt.wantLast, tt.wantMore)
+			}
+
+			if page.BeforeCursor != ch_at.EncodeCursorAtom(first.CreatedAt, first.MessageUUID) ||
+				page.AfterCursor != ch_at.EncodeCursorAtom(last.CreatedAt, last.MessageUUID) {
+				t.Errorf("cursors don't point at the ends of the page")
+			}
+		})
+	}
+
+	sv := &ChatService{r: &fakeChatRepository{chats: map[string]d.Chat{"chat-1": {ChatUUID: "chat-1", AuthUUID: "owner"}}}}
+	page, err := sv.FetchMessages(context.Background(), &d.Chat{ChatUUID: "chat-1", AuthUUID: "owner"}, nil, d.MessagesBefore, 2)
+	if err != nil {
+		t.Fatalf("FetchMessages: %v", err)
+	}
+
+	if page.Messages == nil || page.HasMore || page.BeforeCursor != "" || page.AfterCursor != "" {
+		t.Errorf("empty chat page = %+v", page)
+	}
+}
@@ -3,7 +3,6 @@ package atoms
 import (
 	"os"
 	"log"
-	"fmt"
 	"time"
 	"strconv"
 	"github.com/gin-gonic/gin"
@@ -30,27 +29,15 @@ func RespAtom[T any](
 	gctx.JSON(code, resp)
 }
 
-func AbortAndBuildErrLogAtom(gctx *gin.Context, code int, abortMsg, logMsg string) error {
-	err := BuildErrLogAtom(gctx, logMsg)
-	AbortRespAtom(gctx, code, abortMsg)
-	return err
-}
-
-func AbortRespAtom(gctx *gin.Context, code int, msg string) {
-	gctx.AbortWithStatusJSON(code, map[string]any{
-		"status": code,
-		"error": msg,
-	})
-}
+// AbortErrAtom stops the handler chain with err. The error middleware
+// answers the request and logs it.
+func AbortErrAtom(gctx *gin.Context, err error) {
+	if err == nil {
+		return
+	}
 
-func BuildErrLogAtom(gctx *gin.Context, msg string) error {
-	return fmt.Errorf(
-		"[ERR LOG] %s | IP: %s | UA: %s | P: %s -> %s",
-		time.Now().Format("2006-01-02 15:04:05"),
-		gctx.ClientIP(),
-		gctx.Request.UserAgent(),
-		gctx.FullPath(),
-	msg)
+	gctx.Error(err)
+	gctx.Abort()
 }
 
 func ParseEnvMinutesAtom(eVar string, fallback int) time.Duration {
@@ -67,26 +54,30 @@ func ParseEnvMinutesAtom(eVar string, fallback int) time.Duration {
 	return time.Duration(val) * time.Minute
 }
 
-func FeedErrLogToFile(err error) {
-	if err == nil {
-		return
+func ParseEnvSecondsAtom(eVar string, fallback int) time.Duration {
+	valStr := os.Getenv(eVar)
+	if valStr == "" {
+		return time.Duration(fallback) * time.Second
 	}
 
-	spath := os.Getenv("ERR_LOG_FPATH")
-	if spath == "" {
-		spath = "ERR_LOG"
+	val, err := strconv.Atoi(valStr)
+	if err != nil {
+		log.Fatalf("Invalid seconds value for %s: %v", eVar, err)
 	}
 
-	f, openErr := os.OpenFile(spath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if openErr != nil {
-		log.Fatalf("failed to open error log file: %w", openErr)
-		return
+	return time.Duration(val) * time.Second
+}
+
+func ParseEnvIntAtom(eVar string, fallback int) int {
+	valStr := os.Getenv(eVar)
+	if valStr == "" {
+		return fallback
 	}
-	defer f.Close()
 
-	_, writeErr := f.WriteString(err.Error() + "\n")
-	if writeErr != nil {
-		log.Fatalf("failed to write to error log file: %w", writeErr)
-		return
+	val, err := strconv.Atoi(valStr)
+	if err != nil {
+		log.Fatalf("Invalid integer value for %s: %v", eVar, err)
 	}
+
+	return val
 }
@@ -1,43 +1,210 @@
 package db
 
 import (
+	c_at "aigents-base/internal/common/atoms"
+
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-var DB *sql.DB
+// Config is how the API reaches Postgres. DSN, a postgres:// URL or a
+// key=value string, wins over the discrete Host..SSLMode fields.
+type Config struct {
+	DSN     string
+	Host    string
+	Port    string
+	User    string
+	Pass    string
+	Name    string
+	SSLMode string
+
+	// ApplicationName shows up in pg_stat_activity; StatementTimeout makes
+	// Postgres cancel any single statement running longer. Zero values keep
+	// the server defaults.
+	ApplicationName  string
+	StatementTimeout time.Duration
 
-func Init() {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
-	name := os.Getenv("DB_NAME")
-	sslmode := os.Getenv("DB_SSLMODE")
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 
-	if sslmode == "" {
-		sslmode = "disable"
+	// ConnectAttempts is how many pings Open tries before giving up, waiting
+	// ConnectBackoff after the first failure and doubling it each time.
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+}
+
+// PoolStats is a snapshot of the connection pool, shaped for metrics.
+type PoolStats struct {
+	MaxOpenConns      int     `json:"max_open_conns"`
+	OpenConns         int     `json:"open_conns"`
+	InUse             int     `json:"in_use"`
+	Idle              int     `json:"idle"`
+	WaitCount         int64   `json:"wait_count"`
+	WaitDurationMs    float64 `json:"wait_duration_ms"`
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
+
+// maxConnectBackoff caps the wait between connection attempts.
+const maxConnectBackoff = 30 * time.Second
+
+func ConfigFromEnv() Config {
+	return Config{
+		DSN:              os.Getenv("DB_URL"),
+		Host:             os.Getenv("DB_HOST"),
+		Port:             os.Getenv("DB_PORT"),
+		User:             os.Getenv("DB_USER"),
+		Pass:             os.Getenv("DB_PASS"),
+		Name:             os.Getenv("DB_NAME"),
+		SSLMode:          os.Getenv("DB_SSLMODE"),
+		ApplicationName:  os.Getenv("DB_APP_NAME"),
+		StatementTimeout: c_at.ParseEnvSecondsAtom("DB_STATEMENT_TIMEOUT", 30),
+		MaxOpenConns:     c_at.ParseEnvIntAtom("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:     c_at.ParseEnvIntAtom("DB_MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime:  c_at.ParseEnvMinutesAtom("DB_CONN_MAX_LIFETIME", 30),
+		ConnMaxIdleTime:  c_at.ParseEnvMinutesAtom("DB_CONN_MAX_IDLE_TIME", 5),
+		ConnectAttempts:  c_at.ParseEnvIntAtom("DB_CONNECT_ATTEMPTS", 10),
+		ConnectBackoff:   time.Second,
 	}
+}
 
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, pass, name, sslmode,
-	)
+// Open builds the pool described by cfg and waits for Postgres to answer,
+// retrying with exponential backoff so the API can start alongside its
+// database. The returned handle is meant to be passed to every repository.
+func Open(ctx context.Context, cfg Config) (*sql.DB, error) {
+	dsn, err := cfg.connString()
+	if err != nil {
+		return nil, err
+	}
 
-	var err error
-	DB, err = sql.Open("postgres", dsn)
+	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	if err = DB.Ping(); err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+	conn.SetMaxOpenConns(cfg.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	attempts := max(cfg.ConnectAttempts, 1)
+	backoff := cfg.ConnectBackoff
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = conn.PingContext(pingCtx)
+		cancel()
+
+		if err == nil {
+			break
+		}
+
+		if attempt >= attempts {
+			conn.Close()
+			return nil, fmt.Errorf("connect to database after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("Database not reachable, retrying", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
 
-	log.Println("Connected to PostgreSQL database")
+	// cfg.Host and cfg.Name are empty when DB_URL is used, so the server
+	// says where we landed; a socket connection has no server address
+	var dbname, addr string
+	err = conn.QueryRowContext(ctx, "SELECT current_database(), COALESCE(host(inet_server_addr()), 'socket');").Scan(&dbname, &addr)
+	if err != nil {
+		slog.Warn("Could not read connection target", "error", err)
+	}
+
+	slog.Info("Connected to PostgreSQL database",
+		"server_addr", addr,
+		"dbname", dbname,
+		"max_open_conns", cfg.MaxOpenConns,
+		"max_idle_conns", cfg.MaxIdleConns)
+
+	return conn, nil
 }
 
+// Stats reports the current state of the pool behind conn.
+func Stats(conn *sql.DB) PoolStats {
+	st := conn.Stats()
+
+	return PoolStats{
+		MaxOpenConns:      st.MaxOpenConnections,
+		OpenConns:         st.OpenConnections,
+		InUse:             st.InUse,
+		Idle:              st.Idle,
+		WaitCount:         st.WaitCount,
+		WaitDurationMs:    float64(st.WaitDuration.Microseconds()) / 1000,
+		MaxIdleClosed:     st.MaxIdleClosed,
+		MaxIdleTimeClosed: st.MaxIdleTimeClosed,
+		MaxLifetimeClosed: st.MaxLifetimeClosed,
+	}
+}
+
+// connString turns cfg into the key=value form lib/pq takes. Keys pq doesn't
+// know itself, such as statement_timeout, are sent to the server as session
+// settings.
+func (cfg Config) connString() (string, error) {
+	var parts []string
+
+	if cfg.DSN != "" {
+		dsn := cfg.DSN
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			var err error
+			dsn, err = pq.ParseURL(dsn)
+			if err != nil {
+				return "", fmt.Errorf("parse DB_URL: %w", err)
+			}
+		}
+		parts = append(parts, dsn)
+	} else {
+		sslmode := cfg.SSLMode
+		if sslmode == "" {
+			sslmode = "disable"
+		}
+
+		parts = append(parts,
+			connParam("host", cfg.Host),
+			connParam("port", cfg.Port),
+			connParam("user", cfg.User),
+			connParam("password", cfg.Pass),
+			connParam("dbname", cfg.Name),
+			connParam("sslmode", sslmode),
+		)
+	}
+
+	if cfg.ApplicationName != "" {
+		parts = append(parts, connParam("application_name", cfg.ApplicationName))
+	}
+	if cfg.StatementTimeout > 0 {
+		parts = append(parts, connParam("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)))
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+func connParam(key, value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return key + "='" + value + "'"
+}
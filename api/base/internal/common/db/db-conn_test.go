@@ -0,0 +1,81 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnString(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "discrete fields",
+			cfg:  Config{Host: "db", Port: "5432", User: "api", Pass: "secret", Name: "aigents"},
+			want: "host='db' port='5432' user='api' password='secret' dbname='aigents' sslmode='disable'",
+		},
+		{
+			name: "explicit sslmode",
+			cfg:  Config{Host: "db", SSLMode: "verify-full"},
+			want: "host='db' port='' user='' password='' dbname='' sslmode='verify-full'",
+		},
+		{
+			name: "quotes and backslashes are escaped",
+			cfg:  Config{Host: "db", Pass: `it's a\secret`, SSLMode: "disable"},
+			want: `host='db' port='' user='' password='it\'s a\\secret' dbname='' sslmode='disable'`,
+		},
+		{
+			name: "postgres url",
+			cfg:  Config{DSN: "postgres://api:p%27w@db:5432/aigents?sslmode=require", Host: "ignored"},
+			want: `dbname='aigents' host='db' password='p\'w' port='5432' sslmode='require' user='api'`,
+		},
+		{
+			name: "postgresql url",
+			cfg:  Config{DSN: "postgresql://db/aigents"},
+			want: "dbname='aigents' host='db'",
+		},
+		{
+			name: "key value dsn is passed through",
+			cfg:  Config{DSN: "host=db dbname=aigents"},
+			want: "host=db dbname=aigents",
+		},
+		{
+			name: "session settings",
+			cfg:  Config{DSN: "host=db", ApplicationName: "aigents-api", StatementTimeout: 30 * time.Second},
+			want: "host=db application_name='aigents-api' statement_timeout='30000'",
+		},
+		{
+			name: "sub second statement timeout",
+			cfg:  Config{DSN: "host=db", StatementTimeout: 1500 * time.Millisecond},
+			want: "host=db statement_timeout='1500'",
+		},
+		{
+			name:    "malformed url",
+			cfg:     Config{DSN: "postgres://%zz"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.connString()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("connString = %q, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("connString: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("connString =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	c_at "aigents-base/internal/common/atoms"
+	db "aigents-base/internal/common/db"
+
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DBStatsHandler struct {
+	conn *sql.DB
+}
+
+func NewDBStatsHandler(conn *sql.DB) *DBStatsHandler {
+	return &DBStatsHandler{conn: conn}
+}
+
+// Get reports the connection pool counters, for dashboards and alerting on
+// pool exhaustion.
+func (h *DBStatsHandler) Get(gctx *gin.Context) {
+	c_at.RespAtom[db.PoolStats](gctx,
+		http.StatusOK,
+		"(*) Data retrivied",
+		db.Stats(h.conn))
+}
@@ -0,0 +1,312 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockKey is the pg_advisory_lock key every runner takes, so two
+// instances starting at once don't apply the same migration twice.
+const migrationLockKey int64 = 0x616967656e7473
+
+// migrationFile matches <version>_<name>.<up|down>.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one schema version. Up and Down each run in a transaction
+// together with the schema_migrations bookkeeping, so a failed migration
+// leaves nothing behind.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a known migration and when it was applied, zero if
+// still pending.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator builds a runner over the migrations embedded in the binary.
+func NewMigrator(conn *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: conn, migrations: migrations}, nil
+}
+
+// LoadMigrations reads the migrations in dir, ordered by version. Every
+// version needs both its up and its down file.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.<up|down>.sql", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d: named both %s and %s", version, mig.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// AutoMigrateFromEnv tells whether DB_AUTO_MIGRATE asks for pending
+// migrations to be applied on startup.
+func AutoMigrateFromEnv() bool {
+	auto, _ := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE"))
+	return auto
+}
+
+// Up applies every pending migration in version order and returns how many
+// ran.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, mig.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2);", mig.Version, mig.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+
+			slog.Info("Applied migration", "version", mig.Version, "name", mig.Name)
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, mig.Down,
+				"DELETE FROM schema_migrations WHERE version = $1;", mig.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+
+			slog.Info("Reverted migration", "version", mig.Version, "name", mig.Name)
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases created by hand before migrations existed: version 1 is
+// the original db/scheme.sql, and each later version matches the schema
+// after the change it ships.
+func (m *Migrator) Baseline(ctx context.Context, version int64) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+
+			res, err := conn.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING;",
+				mig.Version, mig.Name)
+			if err != nil {
+				return fmt.Errorf("baseline migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			if n, _ := res.RowsAffected(); n > 0 {
+				slog.Info("Marked migration as applied", "version", mig.Version, "name", mig.Name)
+				count++
+			}
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Status lists every embedded migration with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]MigrationStatus, 0, len(m.migrations))
+		for _, mig := range m.migrations {
+			statuses = append(statuses, MigrationStatus{Migration: mig, AppliedAt: applied[mig.Version]})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock, creating schema_migrations first if needed. The lock is released
+// even when ctx was cancelled midway.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Waiting for another runner and long migrations must not trip the
+	// pool's statement_timeout.
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0;"); err != nil {
+		return fmt.Errorf("disable statement timeout: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "RESET statement_timeout;")
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1);", migrationLockKey); err != nil {
+		return fmt.Errorf("take migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1);", migrationLockKey); err != nil {
+			slog.Error("Failed to release migration lock.", "error", err)
+		}
+	}()
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations;")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigration executes script and the bookkeeping statement in one
+// transaction.
+func runMigration(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	for i, mig := range migrations {
+		if want := int64(i + 1); mig.Version != want {
+			t.Errorf("migration %d_%s: version gap, want %d", mig.Version, mig.Name, want)
+		}
+	}
+
+	if !strings.Contains(migrations[0].Up, "CREATE TYPE role_enum AS ENUM ('USER', 'CREATOR');") {
+		t.Error("0001 is not the original baseline schema")
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"m/0010_later.up.sql":   file("up 10"),
+				"m/0010_later.down.sql": file("down 10"),
+				"m/0002_first.up.sql":   file("up 2"),
+				"m/0002_first.down.sql": file("down 2"),
+			},
+			want: []Migration{
+				{Version: 2, Name: "first", Up: "up 2", Down: "down 2"},
+				{Version: 10, Name: "later", Up: "up 10", Down: "down 10"},
+			},
+		},
+		{
+			name: "missing down",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql": file("up"),
+			},
+			wantErr: "needs both an up and a down file",
+		},
+		{
+			name: "bad file name",
+			files: fstest.MapFS{
+				"m/0001-init.up.sql": file("up"),
+			},
+			wantErr: "name must be",
+		},
+		{
+			name: "one version, two names",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql":    file("up"),
+				"m/0001_other.down.sql": file("down"),
+			},
+			wantErr: "named both",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadMigrations(tt.files, "m")
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadMigrations: %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d migrations, want %d", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("migration %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
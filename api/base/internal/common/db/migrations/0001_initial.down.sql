@@ -0,0 +1,16 @@
+-- ============================================================
+-- Remove todo o esquema inicial, na ordem inversa das dependências
+-- ============================================================
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS message_contents;
+DROP TABLE IF EXISTS chats;
+DROP TABLE IF EXISTS agents;
+DROP TABLE IF EXISTS agents_config;
+DROP TABLE IF EXISTS agent_categories;
+DROP TABLE IF EXISTS agent_systems;
+DROP TABLE IF EXISTS auths;
+
+DROP FUNCTION IF EXISTS update_timestamp();
+
+DROP TYPE IF EXISTS entity_type_enum;
+DROP TYPE IF EXISTS role_enum;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_chats_deleted_at;
+
+ALTER TABLE chats DROP COLUMN IF EXISTS archived_at;
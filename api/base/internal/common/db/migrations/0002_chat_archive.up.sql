@@ -0,0 +1,6 @@
+-- ============================================================
+-- Arquivamento de chats e índice para a purga dos excluídos
+-- ============================================================
+ALTER TABLE chats ADD COLUMN archived_at TIMESTAMP DEFAULT NULL;
+
+CREATE INDEX idx_chats_deleted_at ON chats(deleted_at) WHERE deleted_at IS NOT NULL;
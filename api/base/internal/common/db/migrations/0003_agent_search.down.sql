@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_agents_search_vector;
+
+ALTER TABLE agents DROP COLUMN IF EXISTS search_vector;
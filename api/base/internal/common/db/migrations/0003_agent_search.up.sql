@@ -0,0 +1,8 @@
+-- ============================================================
+-- Busca textual de agentes por nome e descrição
+-- ============================================================
+ALTER TABLE agents ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+  to_tsvector('simple', COALESCE(name, '') || ' ' || COALESCE(description, ''))
+) STORED;
+
+CREATE INDEX idx_agents_search_vector ON agents USING GIN(search_vector);
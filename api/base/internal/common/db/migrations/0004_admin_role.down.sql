@@ -0,0 +1,14 @@
+ALTER TABLE agent_categories DROP COLUMN IF EXISTS updated_at;
+
+-- Postgres não remove valores de um ENUM: o tipo é recriado sem ADMIN
+-- e os administradores voltam a ser USER.
+UPDATE auths SET role = 'USER' WHERE role = 'ADMIN';
+
+ALTER TYPE role_enum RENAME TO role_enum_old;
+CREATE TYPE role_enum AS ENUM ('USER', 'CREATOR');
+
+ALTER TABLE auths ALTER COLUMN role DROP DEFAULT;
+ALTER TABLE auths ALTER COLUMN role TYPE role_enum USING role::text::role_enum;
+ALTER TABLE auths ALTER COLUMN role SET DEFAULT 'USER';
+
+DROP TYPE role_enum_old;
@@ -0,0 +1,9 @@
+-- ============================================================
+-- Papel de administrador e updated_at das categorias, que o
+-- trigger trg_agent_categories_updated já esperava
+-- ============================================================
+-- Postgres 12+ aceita ADD VALUE dentro da transação da migração;
+-- o valor só pode ser usado depois do commit.
+ALTER TYPE role_enum ADD VALUE IF NOT EXISTS 'ADMIN';
+
+ALTER TABLE agent_categories ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,29 @@
+-- ============================================================
+-- Tabela de sessões (famílias de refresh tokens)
+-- ============================================================
+CREATE TABLE auth_sessions (
+  session_uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  auth_uuid UUID NOT NULL,
+  client_ip VARCHAR(64),
+  user_agent VARCHAR(512),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE CASCADE
+);
+
+-- ============================================================
+-- Tabela de refresh tokens emitidos por sessão
+-- ============================================================
+CREATE TABLE refresh_tokens (
+  jti UUID PRIMARY KEY,
+  session_uuid UUID NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  rotated_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (session_uuid) REFERENCES auth_sessions(session_uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_auth_sessions_auth_uuid ON auth_sessions(auth_uuid);
+CREATE INDEX idx_refresh_tokens_session_uuid ON refresh_tokens(session_uuid);
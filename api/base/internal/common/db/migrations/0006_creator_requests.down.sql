@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS creator_requests;
+
+DROP TYPE IF EXISTS creator_request_status_enum;
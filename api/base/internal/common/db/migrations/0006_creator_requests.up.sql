@@ -0,0 +1,22 @@
+-- ============================================================
+-- ENUM para status das solicitações de criador
+-- ============================================================
+CREATE TYPE creator_request_status_enum AS ENUM ('PENDING', 'APPROVED', 'REJECTED');
+
+-- ============================================================
+-- Tabela de solicitações para se tornar criador
+-- ============================================================
+CREATE TABLE creator_requests (
+  request_uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  auth_uuid UUID NOT NULL,
+  message VARCHAR(1000),
+  status creator_request_status_enum NOT NULL DEFAULT 'PENDING',
+  reviewed_by UUID DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  reviewed_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE CASCADE,
+  FOREIGN KEY (reviewed_by) REFERENCES auths(auth_uuid) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_creator_requests_pending ON creator_requests(auth_uuid) WHERE status = 'PENDING';
+CREATE INDEX idx_creator_requests_status ON creator_requests(status, created_at);
@@ -0,0 +1 @@
+ALTER TABLE auths DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,7 @@
+-- ============================================================
+-- Verificação de email das autenticações
+-- ============================================================
+ALTER TABLE auths ADD COLUMN email_verified_at TIMESTAMP DEFAULT NULL;
+
+-- Contas anteriores à verificação continuam podendo entrar.
+UPDATE auths SET email_verified_at = created_at WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS auth_tokens;
+
+DROP TYPE IF EXISTS auth_token_purpose_enum;
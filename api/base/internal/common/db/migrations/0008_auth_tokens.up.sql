@@ -0,0 +1,20 @@
+-- ============================================================
+-- ENUM para finalidade dos tokens enviados por email
+-- ============================================================
+CREATE TYPE auth_token_purpose_enum AS ENUM ('VERIFY_EMAIL', 'RESET_PASSWORD');
+
+-- ============================================================
+-- Tabela de tokens de verificação de email e redefinição de senha
+-- ============================================================
+CREATE TABLE auth_tokens (
+  token_hash CHAR(64) PRIMARY KEY, -- sha256 do token enviado por email
+  auth_uuid UUID NOT NULL,
+  purpose auth_token_purpose_enum NOT NULL,
+  email VARCHAR(255) NOT NULL,     -- endereço para onde o token foi enviado
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_auth_tokens_auth_purpose ON auth_tokens(auth_uuid, purpose) WHERE used_at IS NULL;
@@ -0,0 +1,28 @@
+-- ============================================================
+-- Tabela de tentativas de login falhas (por IP e por email)
+-- ============================================================
+CREATE TABLE login_throttles (
+  scope VARCHAR(16) NOT NULL,          -- 'IP' ou 'EMAIL'
+  throttle_key VARCHAR(255) NOT NULL,  -- endereço IP ou email normalizado
+  failures INT NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until TIMESTAMP DEFAULT NULL,
+  PRIMARY KEY (scope, throttle_key)
+);
+
+-- ============================================================
+-- Tabela de auditoria de eventos de autenticação
+-- ============================================================
+CREATE TABLE auth_audit_log (
+  audit_id BIGSERIAL PRIMARY KEY,
+  event VARCHAR(64) NOT NULL,
+  auth_uuid UUID DEFAULT NULL,
+  email VARCHAR(255),
+  client_ip VARCHAR(64),
+  user_agent VARCHAR(512),
+  detail TEXT,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_auth_audit_log_created_at ON auth_audit_log(created_at);
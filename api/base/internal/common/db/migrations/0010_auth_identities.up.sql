@@ -0,0 +1,16 @@
+-- ============================================================
+-- Tabela de identidades externas (login social via OIDC)
+-- ============================================================
+CREATE TABLE auth_identities (
+  identity_uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  auth_uuid UUID NOT NULL,
+  provider VARCHAR(64) NOT NULL,  -- nome do provedor configurado (ex: google)
+  subject VARCHAR(255) NOT NULL,  -- claim sub do ID token
+  email VARCHAR(255),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  last_login_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (provider, subject),
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_auth_identities_auth_uuid ON auth_identities(auth_uuid);
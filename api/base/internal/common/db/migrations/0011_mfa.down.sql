@@ -0,0 +1,8 @@
+DELETE FROM login_throttles WHERE scope = 'MFA';
+
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+ALTER TABLE auths
+  DROP COLUMN IF EXISTS totp_last_step,
+  DROP COLUMN IF EXISTS totp_enabled_at,
+  DROP COLUMN IF EXISTS totp_secret_enc;
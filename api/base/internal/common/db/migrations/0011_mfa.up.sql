@@ -0,0 +1,20 @@
+-- ============================================================
+-- Autenticação em dois fatores (TOTP)
+-- ============================================================
+ALTER TABLE auths
+  ADD COLUMN totp_secret_enc TEXT DEFAULT NULL,      -- segredo TOTP cifrado (AES-GCM)
+  ADD COLUMN totp_enabled_at TIMESTAMP DEFAULT NULL,
+  ADD COLUMN totp_last_step BIGINT DEFAULT NULL;     -- último passo aceito, evita reuso do código
+
+-- ============================================================
+-- Tabela de códigos de recuperação do 2FA
+-- ============================================================
+CREATE TABLE mfa_recovery_codes (
+  code_hash CHAR(64) PRIMARY KEY, -- sha256 do código
+  auth_uuid UUID NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  used_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_mfa_recovery_codes_auth_uuid ON mfa_recovery_codes(auth_uuid);
@@ -0,0 +1,18 @@
+-- ============================================================
+-- Tabela de chaves de API pessoais
+-- ============================================================
+CREATE TABLE api_keys (
+  api_key_uuid UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  auth_uuid UUID NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  prefix VARCHAR(16) NOT NULL,         -- início visível da chave, para identificação
+  key_hash CHAR(64) NOT NULL UNIQUE,   -- sha256 da chave completa
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP DEFAULT NULL,
+  last_used_at TIMESTAMP DEFAULT NULL,
+  revoked_at TIMESTAMP DEFAULT NULL,
+  FOREIGN KEY (auth_uuid) REFERENCES auths(auth_uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_api_keys_auth_uuid ON api_keys(auth_uuid);
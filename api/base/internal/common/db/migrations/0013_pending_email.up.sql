@@ -0,0 +1,6 @@
+-- ============================================================
+-- Troca de email pendente de verificação
+-- ============================================================
+-- O novo endereço fica aqui até ser verificado; o email atual e sua
+-- verificação continuam valendo enquanto isso.
+ALTER TABLE auths ADD COLUMN pending_email VARCHAR(255) DEFAULT NULL;
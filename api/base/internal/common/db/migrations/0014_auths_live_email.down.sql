@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_auths_live_email;
+
+ALTER TABLE auths ADD CONSTRAINT auths_email_key UNIQUE (email);
@@ -0,0 +1,8 @@
+-- ============================================================
+-- Email único só entre contas ativas, sem diferenciar maiúsculas
+-- ============================================================
+-- Contas removidas (soft delete) mantêm a linha e o email; com a restrição
+-- antiga o endereço nunca mais podia ser cadastrado.
+ALTER TABLE auths DROP CONSTRAINT IF EXISTS auths_email_key;
+
+CREATE UNIQUE INDEX idx_auths_live_email ON auths (lower(email)) WHERE deleted_at IS NULL;
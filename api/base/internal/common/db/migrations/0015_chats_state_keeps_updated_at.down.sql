@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trg_chats_updated ON chats;
+
+CREATE TRIGGER trg_chats_updated
+BEFORE UPDATE ON chats
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
@@ -0,0 +1,14 @@
+-- ============================================================
+-- Arquivar, excluir ou restaurar um chat não mexe no updated_at
+-- ============================================================
+-- A listagem de chats é ordenada por updated_at, que deve refletir a última
+-- mensagem; mudar só o estado do chat não pode reordená-la. Ao anexar uma
+-- mensagem o repositório define updated_at explicitamente.
+DROP TRIGGER IF EXISTS trg_chats_updated ON chats;
+
+CREATE TRIGGER trg_chats_updated
+BEFORE UPDATE ON chats
+FOR EACH ROW
+WHEN (OLD.archived_at IS NOT DISTINCT FROM NEW.archived_at
+  AND OLD.deleted_at IS NOT DISTINCT FROM NEW.deleted_at)
+EXECUTE FUNCTION update_timestamp();
@@ -0,0 +1,75 @@
+package db
+
+import (
+	errs "aigents-base/internal/common/errs"
+	citf "aigents-base/internal/common/interfaces"
+
+	"context"
+	"database/sql"
+)
+
+// Querier is what repositories run statements on: the pool, or the
+// transaction of the unit of work they were called in.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+type TxManager struct {
+	db *sql.DB
+}
+
+func NewTxManager(conn *sql.DB) citf.TxManagerITF {
+	return &TxManager{db: conn}
+}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTx(ctx, m.db, fn)
+}
+
+// Conn returns the transaction carried by ctx, falling back to pool when
+// the call isn't part of a unit of work.
+func Conn(ctx context.Context, pool *sql.DB) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return pool
+}
+
+// RunInTx runs fn in a transaction handed down through the ctx it gets. If
+// ctx already carries one, fn simply joins it and the outermost call decides;
+// otherwise a new transaction is committed when fn succeeds and rolled back
+// when it fails or panics.
+func RunInTx(ctx context.Context, pool *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not begin transaction.",
+			"Failed to begin transaction.", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		err = errs.New(
+			errs.Internal,
+			"(R) Could not commit transaction.",
+			"Failed to commit transaction.", "error", err)
+		return err
+	}
+
+	return nil
+}
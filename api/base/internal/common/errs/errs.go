@@ -0,0 +1,122 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kind classifies an Error and decides the HTTP status it is answered with.
+// Kinds are errors themselves, so errors.Is(err, errs.NotFound) tells
+// whether err, or anything it wraps, is a not-found Error.
+type Kind int
+
+const (
+	Internal Kind = iota
+	Validation
+	Unauthorized
+	Forbidden
+	NotFound
+	Conflict
+	TooManyRequests
+	Unavailable
+)
+
+var kindNames = map[Kind]string{
+	Internal:        "internal",
+	Validation:      "validation",
+	Unauthorized:    "unauthorized",
+	Forbidden:       "forbidden",
+	NotFound:        "not found",
+	Conflict:        "conflict",
+	TooManyRequests: "too many requests",
+	Unavailable:     "upstream unavailable",
+}
+
+var kindStatus = map[Kind]int{
+	Internal:        http.StatusInternalServerError,
+	Validation:      http.StatusBadRequest,
+	Unauthorized:    http.StatusUnauthorized,
+	Forbidden:       http.StatusForbidden,
+	NotFound:        http.StatusNotFound,
+	Conflict:        http.StatusConflict,
+	TooManyRequests: http.StatusTooManyRequests,
+	Unavailable:     http.StatusServiceUnavailable,
+}
+
+func (k Kind) Error() string {
+	return kindNames[k]
+}
+
+func (k Kind) Status() int {
+	return kindStatus[k]
+}
+
+// Error is what repositories, services and handlers return on failure. Msg
+// is shown to the client, Log and Attrs (key/value pairs) only go to the
+// log, and Err is the cause, if any.
+type Error struct {
+	Kind       Kind
+	Msg        string
+	Log        string
+	Attrs      []any
+	Err        error
+	RetryAfter time.Duration
+}
+
+// New builds an Error. An "error" attribute holding an error becomes its
+// cause, so errors.Is/As see through to it.
+func New(kind Kind, msg, logMsg string, attrs ...any) *Error {
+	e := &Error{Kind: kind, Msg: msg, Log: logMsg, Attrs: attrs}
+
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if key, _ := attrs[i].(string); key == "error" {
+			if cause, ok := attrs[i+1].(error); ok {
+				e.Err = cause
+				break
+			}
+		}
+	}
+
+	return e
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Kind.Error())
+	b.WriteString(": ")
+	b.WriteString(e.Log)
+
+	for i := 0; i+1 < len(e.Attrs); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", e.Attrs[i], e.Attrs[i+1])
+	}
+
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the Kind of e, so callers can test for a class of failure
+// without caring which layer produced it.
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(Kind)
+	return ok && kind == e.Kind
+}
+
+// WithRetryAfter tells the client when to try again; meant for
+// TooManyRequests and Unavailable.
+func (e *Error) WithRetryAfter(wait time.Duration) *Error {
+	e.RetryAfter = wait
+	return e
+}
+
+// As returns the outermost Error in err's chain.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	c_at "aigents-base/internal/common/atoms"
+	health "aigents-base/internal/common/health"
+
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	checker  *health.Checker
+	draining func() bool
+}
+
+// NewHealthHandler serves probes from checker; draining tells when a
+// shutdown has started, so traffic is moved away before the server stops.
+func NewHealthHandler(checker *health.Checker, draining func() bool) *HealthHandler {
+	return &HealthHandler{checker: checker, draining: draining}
+}
+
+// Live answers as long as the process is serving, without touching any
+// dependency, so an outage downstream doesn't get the API restarted.
+func (h *HealthHandler) Live(gctx *gin.Context) {
+	c_at.RespAtom[health.Report](gctx,
+		http.StatusOK,
+		"(*) Alive",
+		health.Report{Status: health.StatusUp})
+}
+
+// Ready probes Postgres and the AI service, reusing a report for up to a
+// second, and answers 503 with the per dependency breakdown unless all of
+// them are up.
+func (h *HealthHandler) Ready(gctx *gin.Context) {
+	report := h.checker.Run(gctx.Request.Context())
+	if h.draining() {
+		report.Status = health.StatusDraining
+	}
+
+	if report.Status != health.StatusUp {
+		c_at.RespAtom[health.Report](gctx,
+			http.StatusServiceUnavailable,
+			"(*) Not ready",
+			report)
+		return
+	}
+
+	c_at.RespAtom[health.Report](gctx,
+		http.StatusOK,
+		"(*) Ready",
+		report)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	health "aigents-base/internal/common/health"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		check      health.Check
+		draining   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{"up", func(ctx context.Context) error { return nil }, false, http.StatusOK, health.StatusUp},
+		{"dependency down", func(ctx context.Context) error { return errors.New("refused") }, false, http.StatusServiceUnavailable, health.StatusDown},
+		{"draining", func(ctx context.Context) error { return nil }, true, http.StatusServiceUnavailable, health.StatusDraining},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second, 0)
+			checker.Add("postgres", tt.check)
+
+			h := NewHealthHandler(checker, func() bool { return tt.draining })
+
+			rec := httptest.NewRecorder()
+			gctx, _ := gin.CreateTestContext(rec)
+			gctx.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+			h.Ready(gctx)
+
+			var resp struct {
+				Data health.Report `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body.String(), err)
+			}
+
+			if rec.Code != tt.wantCode || resp.Data.Status != tt.wantStatus {
+				t.Errorf("got %d %q, want %d %q", rec.Code, resp.Data.Status, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Check probes one dependency and should give up once ctx is done.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one Check, shaped for probes and dashboards.
+// Why a check failed is only logged, as /readyz is public.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// Report is the readiness of the API as a whole: up only when every
+// dependency answered.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type Checker struct {
+	timeout  time.Duration
+	cacheFor time.Duration
+	names    []string
+	checks   []Check
+
+	mu     sync.Mutex
+	last   Report
+	lastAt time.Time
+}
+
+// NewChecker builds a Checker giving each of its checks up to timeout. A
+// report is reused for cacheFor, so probes hammering /readyz don't turn into
+// as many pings of every dependency.
+func NewChecker(timeout, cacheFor time.Duration) *Checker {
+	return &Checker{timeout: timeout, cacheFor: cacheFor}
+}
+
+// Add registers check under name, the key it is reported with.
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+}
+
+// Run returns the last report while it is fresh; otherwise it probes every
+// dependency concurrently, so a hanging one costs at most the timeout and
+// not the sum of them. Only one probe runs at a time, callers arriving
+// meanwhile wait for its report.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lastAt.IsZero() && time.Since(c.lastAt) < c.cacheFor {
+		return c.last
+	}
+
+	// the report is shared, so one caller going away must not fail it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			results[i] = CheckResult{
+				Status:    StatusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			if err != nil {
+				results[i].Status = StatusDown
+				slog.Warn("Health check failed", "check", c.names[i], "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(results))}
+	for i, res := range results {
+		report.Checks[c.names[i]] = res
+		if res.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	c.last, c.lastAt = report, time.Now()
+
+	return report
+}
@@ -0,0 +1,147 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func up(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestRunAggregates(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks map[string]Check
+		want   string
+	}{
+		{"all up", map[string]Check{"postgres": up, "ai": up}, StatusUp},
+		{"one down", map[string]Check{"postgres": up, "ai": down}, StatusDown},
+		{"all down", map[string]Check{"postgres": down, "ai": down}, StatusDown},
+		{"no checks", nil, StatusUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(time.Second, 0)
+			for name, check := range tt.checks {
+				c.Add(name, check)
+			}
+
+			report := c.Run(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("status = %q, want %q", report.Status, tt.want)
+			}
+
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("got %d checks, want %d", len(report.Checks), len(tt.checks))
+			}
+
+			for name, res := range report.Checks {
+				want := StatusUp
+				if tt.checks[name](context.Background()) != nil {
+					want = StatusDown
+				}
+
+				if res.Status != want {
+					t.Errorf("%s = %q, want %q", name, res.Status, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCaches(t *testing.T) {
+	var calls atomic.Int32
+	counted := func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}
+
+	c := NewChecker(time.Second, time.Hour)
+	c.Add("postgres", counted)
+
+	// callers arriving together share one probe
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("probed %d times while fresh, want 1", n)
+	}
+
+	c.lastAt = time.Now().Add(-2 * time.Hour)
+	c.Run(context.Background())
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("probed %d times after the report went stale, want 2", n)
+	}
+}
+
+func TestRunProbesConcurrently(t *testing.T) {
+	// each check waits for the other to start, which only happens when
+	// they run side by side; run one after the other, both time out
+	var started sync.WaitGroup
+	started.Add(2)
+
+	rendezvous := func(ctx context.Context) error {
+		started.Done()
+
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	c := NewChecker(time.Second, 0)
+	c.Add("postgres", rendezvous)
+	c.Add("ai", rendezvous)
+
+	if report := c.Run(context.Background()); report.Status != StatusUp {
+		t.Errorf("report = %+v, want the checks to run concurrently", report)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	c := NewChecker(50*time.Millisecond, 0)
+	c.Add("postgres", hang)
+	c.Add("ai", hang)
+
+	// a caller that already went away doesn't cut the shared probe short
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	report := c.Run(ctx)
+
+	if report.Status != StatusDown {
+		t.Errorf("status = %q, want %q", report.Status, StatusDown)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("took %v, want about one timeout", elapsed)
+	}
+}
@@ -1,13 +1,25 @@
 package interfaces
 
 import (
-	"github.com/gin-gonic/gin"
+	"context"
 )
 
 type Common[T any] interface {
-	Create(gctx *gin.Context, data *T) error
-	GetByID(gctx *gin.Context, data *T) error
-	Fetch(gctx *gin.Context, limit, offset uint64) ([]T, error)
-	Update(gctx *gin.Context, data *T) error
-	Delete(gctx *gin.Context, data *T) error
+	Create(ctx context.Context, data *T) error
+	GetByID(ctx context.Context, data *T) error
+	Fetch(ctx context.Context, limit, offset uint64) ([]T, error)
+	Update(ctx context.Context, data *T) error
+	Delete(ctx context.Context, data *T) error
+}
+
+// TxManagerITF groups repository calls into one transaction: every call
+// made with the ctx handed to fn joins it, and it is rolled back if fn
+// returns an error.
+type TxManagerITF interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// MailerITF delivers plain text emails.
+type MailerITF interface {
+	Send(to, subject, body string) error
 }
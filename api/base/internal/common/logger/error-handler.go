@@ -0,0 +1,86 @@
+package logger
+
+import (
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler is the one place errors become responses: whatever a handler
+// or middleware attached with c_at.AbortErrAtom is logged, and the last one
+// is answered with the status of its errs.Kind, unless something was
+// already written (as in a stream).
+func ErrorHandler() gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		gctx.Next()
+
+		if len(gctx.Errors) == 0 {
+			return
+		}
+
+		for _, ginErr := range gctx.Errors {
+			logErr(gctx, asError(ginErr.Err))
+		}
+
+		writeErr(gctx, asError(gctx.Errors.Last().Err))
+	}
+}
+
+// asError gives errors that were never classified the generic internal
+// error shape.
+func asError(err error) *errs.Error {
+	if e, ok := errs.As(err); ok {
+		return e
+	}
+
+	return errs.New(errs.Internal, "", "Unclassified error.", "error", err)
+}
+
+func writeErr(gctx *gin.Context, e *errs.Error) {
+	if gctx.Writer.Written() {
+		return
+	}
+
+	msg := e.Msg
+	if msg == "" {
+		msg = "(M) Internal server error."
+	}
+
+	if e.RetryAfter > 0 {
+		gctx.Header("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds()+0.5)))
+	}
+
+	status := e.Kind.Status()
+	gctx.AbortWithStatusJSON(status, map[string]any{
+		"status": status,
+		"error":  msg,
+	})
+}
+
+func logErr(gctx *gin.Context, e *errs.Error) {
+	level := slog.LevelWarn
+	if e.Kind == errs.Internal || e.Kind == errs.Unavailable {
+		level = slog.LevelError
+	}
+
+	attrs := []any{
+		"request_id", gctx.GetString("request_id"),
+		"method", gctx.Request.Method,
+		"route", gctx.FullPath(),
+		"ip", gctx.ClientIP(),
+		"user_agent", gctx.Request.UserAgent(),
+	}
+
+	if authUUID := gctx.GetString("auth_uuid"); authUUID != "" {
+		attrs = append(attrs, "auth_uuid", authUUID)
+	}
+
+	attrs = append(attrs, "kind", e.Kind.Error(), "status", e.Kind.Status())
+	attrs = append(attrs, e.Attrs...)
+
+	slog.Log(context.Background(), level, e.Log, attrs...)
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	c_at "aigents-base/internal/common/atoms"
+
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Init installs the default slog logger described by the environment:
+//
+//	LOG_LEVEL        debug, info (default), warn or error
+//	LOG_FORMAT       json (default) or text
+//	LOG_FPATH        file to write to, rotated by size; stdout when empty
+//	LOG_MAX_SIZE     size in MB a file may reach before rotating (default 50)
+//	LOG_MAX_BACKUPS  rotated files kept (default 5)
+//
+// The standard log package is routed through the same logger.
+func Init() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(envOr("LOG_LEVEL", "info"))); err != nil {
+		log.Fatalf("Invalid LOG_LEVEL: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if path := os.Getenv("LOG_FPATH"); path != "" {
+		rf, err := NewRotatingFile(
+			path,
+			int64(c_at.ParseEnvIntAtom("LOG_MAX_SIZE", 50))*1024*1024,
+			c_at.ParseEnvIntAtom("LOG_MAX_BACKUPS", 5),
+		)
+		if err != nil {
+			log.Fatalf("Error opening log file: %v", err)
+		}
+		out = rf
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(envOr("LOG_FORMAT", "json")) {
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	case "text":
+		handler = slog.NewTextHandler(out, opts)
+	default:
+		log.Fatalf("Unknown LOG_FORMAT %q", os.Getenv("LOG_FORMAT"))
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+func envOr(name, fallback string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+
+	return fallback
+}
+
+// fallbackWrite reports a write the log destination refused, without taking
+// the server down with it.
+func fallbackWrite(err error, p []byte) {
+	fmt.Fprintf(os.Stderr, "log write failed: %v: %s", err, p)
+}
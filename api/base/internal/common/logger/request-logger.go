@@ -0,0 +1,78 @@
+package logger
+
+import (
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits which client supplied request IDs are trusted.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestLogger tags every request with a request ID, reusing a sane
+// X-Request-ID from the client, and logs one record per request once it is
+// done. It goes first in the chain so the ID reaches every error event.
+func RequestLogger() gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		start := time.Now()
+
+		requestID := gctx.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		gctx.Set("request_id", requestID)
+		gctx.Header(requestIDHeader, requestID)
+
+		gctx.Next()
+
+		status := gctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"request_id", requestID,
+			"method", gctx.Request.Method,
+			"route", gctx.FullPath(),
+			"path", gctx.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", gctx.Writer.Size(),
+			"ip", gctx.ClientIP(),
+		}
+
+		if authUUID := gctx.GetString("auth_uuid"); authUUID != "" {
+			attrs = append(attrs, "auth_uuid", authUUID)
+		}
+
+		slog.Log(context.Background(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panicking handler into a 500 and a logged error event
+// carrying the stack. ErrorHandler is unwound by the panic, so the error is
+// answered here.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(gctx *gin.Context, recovered any) {
+		err := errs.New(
+			errs.Internal,
+			"(M) Internal server error.",
+			"Handler panicked.", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		logErr(gctx, err)
+		writeErr(gctx, err)
+	})
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer appending to path that, once the file would
+// grow past maxSize bytes, renames it to path.1 (shifting older ones up to
+// path.<maxBackups>) and starts a new one.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.f = f
+	rf.size = info.Size()
+	return nil
+}
+
+// Write never fails: when the file cannot be written the record goes to
+// stderr instead.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			fallbackWrite(err, p)
+			return len(p), nil
+		}
+	}
+
+	if rf.f == nil {
+		if err := rf.open(); err != nil {
+			fallbackWrite(err, p)
+			return len(p), nil
+		}
+	}
+
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	if err != nil {
+		fallbackWrite(err, p)
+	}
+
+	return len(p), nil
+}
+
+func (rf *RotatingFile) rotate() error {
+	if rf.f != nil {
+		rf.f.Close()
+		rf.f = nil
+	}
+
+	if rf.maxBackups < 1 {
+		if err := os.Truncate(rf.path, 0); err != nil {
+			return err
+		}
+		return rf.open()
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.maxBackups))
+	for i := rf.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+	}
+
+	if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
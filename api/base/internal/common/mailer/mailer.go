@@ -0,0 +1,253 @@
+package mailer
+
+import (
+	c_at "aigents-base/internal/common/atoms"
+	citf "aigents-base/internal/common/interfaces"
+
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP relay, upgrading to TLS when the
+// relay offers it and authenticating with PLAIN auth when a user is
+// configured. A send gives up after timeout, dial included.
+type SMTPMailer struct {
+	host    string
+	addr    string
+	auth    smtp.Auth
+	from    string
+	timeout time.Duration
+}
+
+func NewSMTPMailer(host, port, user, pass, from string, timeout time.Duration) citf.MailerITF {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, pass, host)
+	}
+
+	return &SMTPMailer{host: host, addr: net.JoinHostPort(host, port), auth: auth, from: from, timeout: timeout}
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := m.send(to, []byte(msg)); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", to, err)
+	}
+
+	return nil
+}
+
+// send is smtp.SendMail with a deadline on the connection, which SendMail
+// has no way to set.
+func (m *SMTPMailer) send(to string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", m.addr, m.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(m.timeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// FileMailer appends every email to a file instead of delivering it, for
+// local development and tests.
+type FileMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileMailer(path string) citf.MailerITF {
+	return &FileMailer{path: path}
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open mail file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f,
+		"[MAIL] %s | TO: %s | SUBJECT: %s\n%s\n\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		to,
+		subject,
+		body)
+	if err != nil {
+		return fmt.Errorf("write mail file: %w", err)
+	}
+
+	return nil
+}
+
+// Queue delivers emails in the background so senders never wait on the
+// mailer behind it; failed deliveries are logged and dropped.
+type Queue struct {
+	next citf.MailerITF
+	jobs chan mail
+	done chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type mail struct {
+	to, subject, body string
+}
+
+// NewQueue starts a Queue holding up to size emails waiting for next.
+func NewQueue(next citf.MailerITF, size int) *Queue {
+	q := &Queue{next: next, jobs: make(chan mail, size), done: make(chan struct{})}
+
+	go q.work()
+
+	return q
+}
+
+// Send queues the email and returns at once; it fails only when the queue
+// is full or closed.
+func (q *Queue) Send(to, subject, body string) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return errors.New("mail queue is closed")
+	}
+
+	select {
+	case q.jobs <- mail{to, subject, body}:
+		return nil
+	default:
+		return errors.New("mail queue is full")
+	}
+}
+
+func (q *Queue) work() {
+	defer close(q.done)
+
+	for m := range q.jobs {
+		if err := q.next.Send(m.to, m.subject, m.body); err != nil {
+			slog.Error("Failed to deliver mail.", "to", m.to, "subject", m.subject, "error", err)
+		}
+	}
+}
+
+// Close stops taking emails and waits for the queued ones to be delivered
+// until ctx is done.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewFromEnv picks the mailer named by MAILER, "smtp" or "file". There is no
+// default so a production deploy can't end up writing emails to disk.
+func NewFromEnv() citf.MailerITF {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			log.Fatalf("MAILER is smtp but SMTP_HOST is not set")
+		}
+
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		return NewSMTPMailer(
+			host,
+			port,
+			os.Getenv("SMTP_USER"),
+			os.Getenv("SMTP_PASS"),
+			os.Getenv("MAIL_FROM"),
+			c_at.ParseEnvSecondsAtom("SMTP_TIMEOUT", 10),
+		)
+	case "file":
+		path := os.Getenv("MAIL_FPATH")
+		if path == "" {
+			path = "MAIL_LOG"
+		}
+
+		return NewFileMailer(path)
+	case "":
+		log.Fatalf("MAILER is not set, use smtp or file")
+		return nil
+	default:
+		log.Fatalf("Unknown MAILER %q", os.Getenv("MAILER"))
+		return nil
+	}
+}
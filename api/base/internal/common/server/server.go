@@ -0,0 +1,131 @@
+package server
+
+import (
+	c_at "aigents-base/internal/common/atoms"
+	errs "aigents-base/internal/common/errs"
+
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config is how the API listens. WriteTimeout bounds whole responses, so
+// streaming handlers lift it for their own request. On shutdown the server
+// keeps listening for DrainDelay while reporting itself draining, so load
+// balancers stop routing to it, then in-flight requests get ShutdownTimeout
+// to finish.
+type Config struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration
+}
+
+type Server struct {
+	cfg      Config
+	draining atomic.Bool
+}
+
+func ConfigFromEnv() Config {
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		addr = ":8000"
+	}
+
+	return Config{
+		Addr:            addr,
+		ReadTimeout:     c_at.ParseEnvSecondsAtom("HTTP_READ_TIMEOUT", 15),
+		WriteTimeout:    c_at.ParseEnvSecondsAtom("HTTP_WRITE_TIMEOUT", 30),
+		IdleTimeout:     c_at.ParseEnvSecondsAtom("HTTP_IDLE_TIMEOUT", 120),
+		DrainDelay:      c_at.ParseEnvSecondsAtom("HTTP_DRAIN_DELAY", 5),
+		ShutdownTimeout: c_at.ParseEnvSecondsAtom("HTTP_SHUTDOWN_TIMEOUT", 30),
+	}
+}
+
+func New(cfg Config) *Server {
+	return &Server{cfg: cfg}
+}
+
+// Draining tells whether a shutdown has started.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// RejectWhileDraining answers 503 once a shutdown has started, for routes
+// that open work the server may not get to finish, such as new chat
+// streams. It only has requests to reject during the DrainDelay, while the
+// listener is still open.
+func (s *Server) RejectWhileDraining() gin.HandlerFunc {
+	return func(gctx *gin.Context) {
+		if s.Draining() {
+			err := errs.New(
+				errs.Unavailable,
+				"(M) Server is shutting down, try again shortly.",
+				"Request rejected while draining.").WithRetryAfter(5 * time.Second)
+			c_at.AbortErrAtom(gctx, err)
+			return
+		}
+
+		gctx.Next()
+	}
+}
+
+// Run serves handler until ctx is cancelled, then drains: for DrainDelay new
+// requests behind RejectWhileDraining are refused while the rest are still
+// served, then the listener is closed and in-flight requests, streams
+// included, get up to ShutdownTimeout to finish before their connections
+// are cut. Handlers cut that way may still be running when Run returns.
+func (s *Server) Run(ctx context.Context, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:         s.cfg.Addr,
+		Handler:      handler,
+		ReadTimeout:  s.cfg.ReadTimeout,
+		WriteTimeout: s.cfg.WriteTimeout,
+		IdleTimeout:  s.cfg.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	slog.Info("HTTP server listening", "addr", s.cfg.Addr)
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve http: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+	if s.cfg.DrainDelay > 0 {
+		slog.Info("Shutting down, refusing new streams before closing the listener", "delay", s.cfg.DrainDelay.String())
+		time.Sleep(s.cfg.DrainDelay)
+	}
+
+	slog.Info("Draining in-flight requests", "timeout", s.cfg.ShutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Drain deadline passed, closing remaining connections", "error", err)
+		srv.Close()
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve http: %w", err)
+	}
+
+	slog.Info("HTTP server stopped")
+	return nil
+}
@@ -5,12 +5,14 @@
 -- ============================================================
 -- 1. auths
 -- ============================================================
-INSERT INTO auths (auth_uuid, email, password, role) VALUES
-('11111111-1111-1111-1111-111111111111', 'ana@example.com', 'senha123', 'USER'),
-('22222222-2222-2222-2222-222222222222', 'bruno@example.com', 'senha123', 'USER'),
-('33333333-3333-3333-3333-333333333333', 'carla@example.com', 'senha123', 'USER'),
-('44444444-4444-4444-4444-444444444444', 'diego@example.com', 'senha123', 'USER'),
-('55555555-5555-5555-5555-555555555555', 'erika@example.com', 'senha123', 'USER');
+-- Nenhum ADMIN é semeado; promova uma conta própria direto no banco:
+-- UPDATE auths SET role = 'ADMIN' WHERE email = '<seu email>';
+INSERT INTO auths (auth_uuid, email, password, role, email_verified_at) VALUES
+('11111111-1111-1111-1111-111111111111', 'ana@example.com', 'senha123', 'CREATOR', NOW()),
+('22222222-2222-2222-2222-222222222222', 'bruno@example.com', 'senha123', 'CREATOR', NOW()),
+('33333333-3333-3333-3333-333333333333', 'carla@example.com', 'senha123', 'CREATOR', NOW()),
+('44444444-4444-4444-4444-444444444444', 'diego@example.com', 'senha123', 'CREATOR', NOW()),
+('55555555-5555-5555-5555-555555555555', 'erika@example.com', 'senha123', 'CREATOR', NOW());
 
 
 -- ============================================================
@@ -34,6 +36,8 @@ INSERT INTO agent_categories (category_id, category_name, agent_system_uuid_pres
 (4, 'Educação',     'aaaaaaa4-aaaa-aaaa-aaaa-aaaaaaaaaaa4'),
 (5, 'Jurídico',     'aaaaaaa5-aaaa-aaaa-aaaa-aaaaaaaaaaa5');
 
+SELECT setval('agent_categories_category_id_seq', (SELECT MAX(category_id) FROM agent_categories));
+
 
 -- ============================================================
 -- 4. agents_config